@@ -0,0 +1,42 @@
+package centralsim
+
+// Activity cuenta las rutinas de un proceso lógico que tienen trabajo. Cada
+// rutina resta uno antes de esperar algo que solo otra puede darle, y quien se
+// lo da suma uno antes de hacerlo, de modo que la cuenta solo llega a cero
+// cuando el proceso no puede avanzar sin mensajes nuevos. La red virtual de
+// dist-sim la usa para entregar los mensajes cuando todos los procesos esperan.
+type Activity interface {
+	Add(delta int)
+}
+
+type nopActivity struct{}
+
+func (nopActivity) Add(int) {}
+
+// NopActivity devuelve una cuenta de actividad que no cuenta nada
+func NopActivity() Activity {
+	return nopActivity{}
+}
+
+// SetActivity hace que el motor lleve la cuenta de sus rutinas en activity.
+// Debe llamarse antes de entregar eventos o solicitudes al motor.
+func (se *SimulationEngine) SetActivity(activity Activity) {
+	se.activity = activity
+}
+
+// Stop detiene la simulación en curso, que termina en cuanto deja de esperar,
+// y las rutinas que atienden los eventos y LookAheads de otros procesos
+func (se *SimulationEngine) Stop() {
+	se.stopOnce.Do(func() { close(se.done) })
+	se.routines.Wait()
+}
+
+// Indica si se ha llamado a Stop
+func (se *SimulationEngine) stopped() bool {
+	select {
+	case <-se.done:
+		return true
+	default:
+		return false
+	}
+}
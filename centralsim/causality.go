@@ -0,0 +1,214 @@
+package centralsim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tipos de violación de la causalidad local
+const (
+	ViolationStraggler = "straggler" // evento entrante anterior al reloj local
+	ViolationClock     = "clock"     // el reloj local retrocede
+	ViolationLookAhead = "lookahead" // evento anterior al LookAhead que prometió el emisor
+)
+
+// Violation describe una violación de la causalidad local de un proceso
+type Violation struct {
+	Kind     string
+	Process  int       // proceso que envió el evento, -1 en las violaciones del reloj
+	Event    Event     // evento entrante que provoca la violación
+	Clock    TypeClock // reloj local al detectarla; en las del reloj, el nuevo valor
+	Previous TypeClock // reloj local anterior (ViolationClock)
+	Promised TypeClock // LookAhead prometido por el emisor (ViolationLookAhead)
+}
+
+func (v Violation) Error() string {
+	switch v.Kind {
+	case ViolationClock:
+		return fmt.Sprintf("local clock went back from %v to %v", v.Previous, v.Clock)
+	case ViolationLookAhead:
+		return fmt.Sprintf("event for transition %v at %v from PL%v is earlier than its lookahead %v",
+			v.Event.IiTransicion, v.Event.IiTiempo, v.Process, v.Promised)
+	}
+	return fmt.Sprintf("straggler event for transition %v at %v from PL%v with local clock %v",
+		v.Event.IiTransicion, v.Event.IiTiempo, v.Process, v.Clock)
+}
+
+/*
+CausalityChecker comprueba que un proceso respeta la causalidad local: el
+reloj no retrocede, no llegan eventos anteriores al reloj (rezagados) y los
+emisores no envían eventos anteriores al LookAhead que prometieron. Lo usa
+el motor en modo de comprobación y el análisis de los logs de una ejecución.
+*/
+type CausalityChecker struct {
+	clock      TypeClock
+	started    bool
+	promised   map[int]TypeClock // mayor LookAhead recibido de cada proceso
+	Violations []Violation
+}
+
+// NewCausalityChecker crea un comprobador sin violaciones
+func NewCausalityChecker() *CausalityChecker {
+	return &CausalityChecker{promised: make(map[int]TypeClock)}
+}
+
+// Reset empieza una nueva simulación desde el reloj indicado, p.ej. al restaurar un estado
+func (c *CausalityChecker) Reset(clock TypeClock) {
+	c.clock, c.started = clock, true
+	c.promised = make(map[int]TypeClock)
+}
+
+// Clock comprueba un nuevo valor del reloj local
+func (c *CausalityChecker) Clock(clock TypeClock) []Violation {
+	if !c.started {
+		c.Reset(clock)
+		return nil
+	}
+	if clock < c.clock {
+		return c.add(Violation{Kind: ViolationClock, Process: -1, Clock: clock, Previous: c.clock})
+	}
+	c.clock = clock
+	return nil
+}
+
+// LookAhead registra el LookAhead recibido de un proceso
+func (c *CausalityChecker) LookAhead(process int, lookAhead TypeClock) {
+	if lookAhead > c.promised[process] {
+		c.promised[process] = lookAhead
+	}
+}
+
+// Event comprueba un evento recibido de otro proceso antes de insertarlo
+func (c *CausalityChecker) Event(process int, event Event) []Violation {
+	violations := []Violation{}
+	if c.started && event.IiTiempo < c.clock {
+		violations = append(violations, Violation{Kind: ViolationStraggler, Process: process, Event: event, Clock: c.clock})
+	}
+	if promised, ok := c.promised[process]; ok && event.IiTiempo < promised {
+		violations = append(violations, Violation{Kind: ViolationLookAhead, Process: process, Event: event,
+			Clock: c.clock, Promised: promised})
+	}
+	return c.add(violations...)
+}
+
+func (c *CausalityChecker) add(violations ...Violation) []Violation {
+	c.Violations = append(c.Violations, violations...)
+	return violations
+}
+
+// Mensajes del motor que usa CheckLog
+const (
+	logEngineCreated  = "Motor de simulación creado"
+	logStateRestored  = "Estado restaurado"
+	logClockAdvanced  = "Avanza el tiempo"
+	logEventInserted  = "Evento insertado"
+	logLookAheadAdded = "LookAhead recibido"
+)
+
+/*
+CheckLog comprueba la causalidad local a partir del log de un proceso, en
+formato logfmt o JSON y con nivel debug o superior. Cada creación del motor
+en el fichero empieza una nueva comprobación.
+*/
+func CheckLog(r io.Reader) ([]Violation, error) {
+	checker := NewCausalityChecker()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		record, err := parseRecord(scanner.Text())
+		if err != nil {
+			return checker.Violations, fmt.Errorf("line %v: %w", line, err)
+		}
+		switch record["msg"] {
+		case logEngineCreated:
+			checker.started = false
+			checker.promised = make(map[int]TypeClock)
+		case logStateRestored:
+			checker.Reset(record.clock("clock"))
+		case logClockAdvanced:
+			checker.Clock(record.clock("clock"))
+		case logLookAheadAdded:
+			checker.LookAhead(record.int("process"), record.clock("lookahead"))
+		case logEventInserted:
+			event := Event{
+				IiTiempo:     record.clock("time"),
+				IiCte:        TypeConst(record.int("cte")),
+				IiTransicion: IndLocalTrans(record.int("transition")),
+			}
+			checker.Event(record.int("process"), event)
+		}
+	}
+	return checker.Violations, scanner.Err()
+}
+
+// Campos de un registro del log
+type logRecord map[string]string
+
+func (r logRecord) int(key string) int {
+	value, _ := strconv.Atoi(r[key])
+	return value
+}
+
+func (r logRecord) clock(key string) TypeClock {
+	value, _ := strconv.ParseInt(r[key], 10, 64)
+	return TypeClock(value)
+}
+
+// Lee un registro en formato JSON o logfmt
+func parseRecord(line string) (logRecord, error) {
+	record := logRecord{}
+	if strings.HasPrefix(line, "{") {
+		fields := map[string]interface{}{}
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.UseNumber() // los relojes grandes no se escriben en notación científica
+		if err := dec.Decode(&fields); err != nil {
+			return nil, err
+		}
+		for k, v := range fields {
+			record[k] = fmt.Sprint(v)
+		}
+		return record, nil
+	}
+
+	for rest := strings.TrimSpace(line); rest != ""; rest = strings.TrimLeft(rest, " ") {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid logfmt field %q", rest)
+		}
+		key, value := rest[:eq], rest[eq+1:]
+		if strings.HasPrefix(value, `"`) {
+			end := quotedEnd(value)
+			unquoted, err := strconv.Unquote(value[:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value for %v: %w", key, err)
+			}
+			value, rest = unquoted, value[end:]
+		} else if end := strings.IndexByte(value, ' '); end >= 0 {
+			value, rest = value[:end], value[end:]
+		} else {
+			rest = ""
+		}
+		record[key] = value
+	}
+	return record, nil
+}
+
+// Posición siguiente a la comilla que cierra el valor que empieza en s
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
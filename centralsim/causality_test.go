@@ -0,0 +1,86 @@
+package centralsim
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Motor sin transiciones, con PL1 como precedente, que registra en out
+func newCheckedEngine(t *testing.T, out io.Writer, format string) (*SimulationEngine, chan LookAhead, chan LookAhead) {
+	logger, err := NewLogger("0", LogConfig{Level: LevelDebug, Format: format, Sinks: []io.Writer{out}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests, lookAheads := make(chan LookAhead), make(chan LookAhead)
+	se := MakeSimulationEngine(Lefs{}, logger, make(chan Event), make(chan IncommingEvent), make(chan bool),
+		requests, lookAheads, make(chan LookAhead), make(chan LookAhead),
+		map[int]TypeClock{1: 0}, 1, nil)
+	se.CheckCausality()
+	if err := se.Restore(Checkpoint{Version: CheckpointVersion, Clock: 10}); err != nil {
+		t.Fatal(err)
+	}
+	return se, requests, lookAheads
+}
+
+// Entrega un evento de PL1 y espera a que esté insertado
+func deliver(se *SimulationEngine, time TypeClock) {
+	se.incomEventsCh <- IncommingEvent{Event: Event{IiTiempo: time, IiTransicion: -3, IiCte: 1}, ProcessId: 1}
+	<-se.incomEventsAckCh
+}
+
+func TestEngineDetectsCausalityViolations(t *testing.T) {
+	for _, format := range []string{FormatLogfmt, FormatJSON} {
+		var out bytes.Buffer
+		se, requests, lookAheads := newCheckedEngine(t, &out, format)
+
+		deliver(se, 12) // correcto
+		deliver(se, 5)  // rezagado: el reloj local es 10
+		go func() {
+			<-requests
+			lookAheads <- LookAhead{Process: 1, Time: 20}
+		}()
+		se.getLookAhead(1)
+		deliver(se, 15) // PL1 prometió no enviar nada antes de 20
+
+		violations := se.Violations()
+		if len(violations) != 2 {
+			t.Fatalf("%v: expected 2 violations, got %v", format, violations)
+		}
+		if v := violations[0]; v.Kind != ViolationStraggler || v.Process != 1 || v.Event.IiTiempo != 5 || v.Clock != 10 {
+			t.Errorf("%v: unexpected straggler %+v", format, v)
+		}
+		if v := violations[1]; v.Kind != ViolationLookAhead || v.Event.IiTiempo != 15 || v.Promised != 20 {
+			t.Errorf("%v: unexpected lookahead violation %+v", format, v)
+		}
+
+		// El análisis del log encuentra las mismas violaciones
+		fromLog, err := CheckLog(strings.NewReader(out.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fromLog) != len(violations) {
+			t.Fatalf("%v: log analysis found %v, expected %v", format, fromLog, violations)
+		}
+		for i := range violations {
+			if !reflect.DeepEqual(fromLog[i], violations[i]) {
+				t.Errorf("%v: log violation %+v, expected %+v", format, fromLog[i], violations[i])
+			}
+		}
+	}
+}
+
+func TestClockMustNotGoBack(t *testing.T) {
+	checker := NewCausalityChecker()
+	checker.Clock(3)
+	checker.Clock(7)
+	if v := checker.Clock(6); len(v) != 1 || v[0].Kind != ViolationClock || v[0].Previous != 7 || v[0].Clock != 6 {
+		t.Errorf("unexpected violations %+v", v)
+	}
+	checker.Reset(2) // restaurar un estado anterior no es una violación
+	if v := checker.Clock(4); len(v) != 0 || len(checker.Violations) != 1 {
+		t.Errorf("unexpected violations after reset %+v", checker.Violations)
+	}
+}
@@ -0,0 +1,191 @@
+package centralsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// CheckpointVersion identifica el formato de los ficheros de checkpoint. La
+// versión 2 añade el estado de los intervalos, los servidores, la memoria de
+// las transiciones, las fichas y los lugares, sin los que un checkpoint de la
+// versión 1 se restauraría con ese estado vacío.
+const CheckpointVersion = 2
+
+// TransitionState guarda la parte variable de una transición de la Lefs
+type TransitionState struct {
+	IiIndLocal IndLocalTrans `json:"ii_idglobal"`
+	IiValorLef TypeConst     `json:"ii_valor"`
+	IiTiempo   TypeClock     `json:"ii_tiempo"`
+	// Disparo planificado de las transiciones con intervalo o memoria
+	IiDisparo         TypeClock `json:"ii_disparo,omitempty"`
+	Planificada       bool      `json:"planificada,omitempty"`
+	Sensibilizaciones int       `json:"sensibilizaciones,omitempty"`
+	Inicio            TypeClock `json:"inicio,omitempty"`
+	Transcurrido      TypeClock `json:"transcurrido,omitempty"`
+	// Fin de los disparos en curso de las transiciones con servidores
+	FinDisparos []TypeClock `json:"fin_disparos,omitempty"`
+}
+
+// PlaceState guarda las marcas de un lugar que mantiene la subred
+type PlaceState struct {
+	IiIdGlobal int       `json:"ii_idglobal"`
+	IiMarcas   TypeConst `json:"ii_marcas"`
+	IaFichas   []Ficha   `json:"ia_fichas,omitempty"`
+}
+
+// Checkpoint es el estado de un motor de simulación entre dos pasos
+type Checkpoint struct {
+	Version     int                   `json:"version"`
+	Clock       TypeClock             `json:"reloj"`
+	Transitions []TransitionState     `json:"transiciones"`
+	Events      EventList             `json:"eventos"`
+	LookAheads  map[int]TypeClock     `json:"lookaheads"`
+	Results     []ResultadoTransition `json:"resultados"`
+	EventNumber float64               `json:"num_eventos"`
+	Places      []PlaceState          `json:"lugares,omitempty"`
+}
+
+// Clock devuelve el valor actual del reloj local
+func (se *SimulationEngine) Clock() TypeClock {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	return se.iiRelojlocal
+}
+
+// Checkpoint copia el estado del motor de simulación
+func (se *SimulationEngine) Checkpoint() Checkpoint {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	return se.checkpoint()
+}
+
+// checkpoint copia el estado del motor; se llama con el mutex bloqueado
+func (se *SimulationEngine) checkpoint() Checkpoint {
+	cp := Checkpoint{
+		Version:     CheckpointVersion,
+		Clock:       se.iiRelojlocal,
+		Transitions: make([]TransitionState, 0, len(se.ilMislefs.IaRed)),
+		Events:      append(EventList{}, se.IlEventos...),
+		LookAheads:  make(map[int]TypeClock),
+		Results:     append([]ResultadoTransition{}, se.ivTransResults...),
+		EventNumber: se.EventNumber,
+	}
+	for _, t := range se.ilMislefs.IaRed {
+		cp.Transitions = append(cp.Transitions, TransitionState{IiIndLocal: t.IiIndLocal, IiValorLef: t.IiValorLef,
+			IiTiempo: t.IiTiempo, IiDisparo: t.iiDisparo, Planificada: t.planificada, Sensibilizaciones: t.sensibilizaciones,
+			Inicio: t.iiInicio, Transcurrido: t.iiTranscurrido,
+			FinDisparos: append([]TypeClock(nil), t.finDisparos...)})
+	}
+	for _, p := range se.ilMislefs.IaLugares {
+		cp.Places = append(cp.Places, PlaceState{IiIdGlobal: p.IiIdGlobal, IiMarcas: p.IiMarcas,
+			IaFichas: append([]Ficha(nil), p.IaFichas...)})
+	}
+	for p, l := range se.lookAheads {
+		cp.LookAheads[p] = l
+	}
+	return cp
+}
+
+// Solicitud de instantánea atendida por manageIncommingEvents
+type snapshotRequest struct {
+	recorded func(Checkpoint)
+	done     chan bool
+}
+
+// Snapshot toma el estado del motor después de insertar los eventos entrantes
+// ya entregados y llama a recorded con el mutex bloqueado, de modo que el motor
+// no genera eventos nuevos hasta que recorded termina.
+func (se *SimulationEngine) Snapshot(recorded func(Checkpoint)) {
+	req := snapshotRequest{recorded: recorded, done: make(chan bool)}
+	se.activity.Add(1) // la rutina de eventos entrantes toma el estado
+	select {
+	case se.snapshotCh <- req:
+		<-req.done
+	case <-se.done:
+	}
+}
+
+// Restore carga en el motor un estado guardado con Checkpoint. La Lefs del
+// motor debe ser la misma subred con la que se generó el checkpoint.
+func (se *SimulationEngine) Restore(cp Checkpoint) error {
+	if cp.Version != CheckpointVersion {
+		return fmt.Errorf("versión de checkpoint %v no soportada", cp.Version)
+	}
+	se.mux.Lock()
+	defer se.mux.Unlock()
+
+	trList := se.ilMislefs.IaRed
+	if len(cp.Transitions) != len(trList) {
+		return fmt.Errorf("el checkpoint tiene %v transiciones y la subred %v", len(cp.Transitions), len(trList))
+	}
+	for i, ts := range cp.Transitions {
+		if trList[i].IiIndLocal != ts.IiIndLocal {
+			return fmt.Errorf("transición %v del checkpoint no corresponde con %v", ts.IiIndLocal, trList[i].IiIndLocal)
+		}
+	}
+	if len(cp.Places) != len(se.ilMislefs.IaLugares) {
+		return fmt.Errorf("el checkpoint tiene %v lugares y la subred %v", len(cp.Places), len(se.ilMislefs.IaLugares))
+	}
+	for i, ps := range cp.Places {
+		if se.ilMislefs.IaLugares[i].IiIdGlobal != ps.IiIdGlobal {
+			return fmt.Errorf("lugar %v del checkpoint no corresponde con %v", ps.IiIdGlobal, se.ilMislefs.IaLugares[i].IiIdGlobal)
+		}
+	}
+
+	for i, ts := range cp.Transitions {
+		trList[i].IiValorLef = ts.IiValorLef
+		trList[i].IiTiempo = ts.IiTiempo
+		trList[i].iiDisparo, trList[i].planificada = ts.IiDisparo, ts.Planificada
+		trList[i].sensibilizaciones = ts.Sensibilizaciones
+		trList[i].iiInicio, trList[i].iiTranscurrido = ts.Inicio, ts.Transcurrido
+		trList[i].finDisparos = append([]TypeClock(nil), ts.FinDisparos...)
+		trList[i].IiVecesDisparada = len(ts.FinDisparos)
+	}
+	for i, ps := range cp.Places {
+		se.ilMislefs.IaLugares[i].IiMarcas = ps.IiMarcas
+		se.ilMislefs.IaLugares[i].IaFichas = append([]Ficha(nil), ps.IaFichas...)
+	}
+	se.ilMislefs.IsTransSensib = MakeTransitionStack()
+	se.iiRelojlocal = cp.Clock
+	se.IlEventos = append(MakeEventList(), cp.Events...)
+	se.ivTransResults = append(make([]ResultadoTransition, 0), cp.Results...)
+	se.EventNumber = cp.EventNumber
+	for p, l := range cp.LookAheads {
+		se.lookAheads[p] = l
+	}
+
+	if se.causality != nil {
+		se.causality.Reset(se.iiRelojlocal)
+	}
+	se.Log.Info("Estado restaurado", "clock", se.iiRelojlocal)
+	return nil
+}
+
+// SaveCheckpoint escribe el checkpoint en un fichero json
+func SaveCheckpoint(filename string, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode checkpoint: %w", err)
+	}
+	if err := ioutil.WriteFile(filename, data, 0666); err != nil {
+		return fmt.Errorf("write checkpoint file: %w", err)
+	}
+	return nil
+}
+
+// LoadCheckpoint lee un checkpoint de un fichero json
+func LoadCheckpoint(filename string) (Checkpoint, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("open checkpoint file: %w", err)
+	}
+	defer file.Close()
+
+	cp := Checkpoint{}
+	if err := json.NewDecoder(file).Decode(&cp); err != nil {
+		return Checkpoint{}, fmt.Errorf("decode checkpoint file: %w", err)
+	}
+	return cp, nil
+}
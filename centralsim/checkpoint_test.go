@@ -0,0 +1,80 @@
+package centralsim
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func checkpointLefs() Lefs {
+	return Lefs{
+		IaRed: TransitionList{
+			{IiIndLocal: 0, IiValorLef: 1, IiDuracionDisparo: 1, TransConstPul: [][2]int{{1, -1}}},
+			{IiIndLocal: 1, IiValorLef: 1, IiDuracionDisparo: 1, TransConstPul: [][2]int{{-3, -1}}},
+		},
+		IsTransSensib: MakeTransitionStack(),
+	}
+}
+
+func checkpointEngine() *SimulationEngine {
+	return &SimulationEngine{
+		ilMislefs:      checkpointLefs(),
+		IlEventos:      MakeEventList(),
+		ivTransResults: make([]ResultadoTransition, 0),
+		lookAheads:     map[int]TypeClock{1: 0},
+		Log:            NopLogger("0"),
+	}
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	se := checkpointEngine()
+	se.iiRelojlocal = 7
+	se.ilMislefs.IaRed[0].IiValorLef = 0
+	se.ilMislefs.IaRed[0].IiTiempo = 7
+	se.IlEventos.inserta(Event{IiTiempo: 9, IiTransicion: 1, IiCte: -1})
+	se.IlEventos.inserta(Event{IiTiempo: 8, IiTransicion: -3, IiCte: -1})
+	se.ivTransResults = append(se.ivTransResults, ResultadoTransition{0, 6})
+	se.lookAheads[1] = 8
+	se.EventNumber = 12
+
+	filename := filepath.Join(t.TempDir(), "0.json")
+	if err := SaveCheckpoint(filename, se.Checkpoint()); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := LoadCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := checkpointEngine()
+	if err := restored.Restore(cp); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Clock() != 7 || restored.EventNumber != 12 || restored.lookAheads[1] != 8 {
+		t.Errorf("clock, event number or lookahead not restored: %+v", cp)
+	}
+	if tr := restored.ilMislefs.IaRed[0]; tr.IiValorLef != 0 || tr.IiTiempo != 7 {
+		t.Errorf("transition state not restored: %+v", tr)
+	}
+	if len(restored.IlEventos) != 2 || restored.IlEventos[0].IiTiempo != 8 {
+		t.Errorf("event list not restored: %v", restored.IlEventos)
+	}
+	if len(restored.ivTransResults) != 1 {
+		t.Errorf("results not restored: %v", restored.ivTransResults)
+	}
+}
+
+func TestRestoreRejectsOtherSubnet(t *testing.T) {
+	cp := checkpointEngine().Checkpoint()
+	cp.Transitions[1].IiIndLocal = 5
+	if err := checkpointEngine().Restore(cp); err == nil {
+		t.Error("expected error restoring a checkpoint of another subnet")
+	}
+
+	for _, version := range []int{1, CheckpointVersion + 1} {
+		cp = checkpointEngine().Checkpoint()
+		cp.Version = version
+		if err := checkpointEngine().Restore(cp); err == nil {
+			t.Errorf("expected error restoring checkpoint version %v", version)
+		}
+	}
+}
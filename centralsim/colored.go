@@ -0,0 +1,86 @@
+package centralsim
+
+import "sort"
+
+// Ficha es el valor de una marca de una red coloreada, codificado en JSON
+// para que el motor lo trate como opaco y viaje en los eventos entre procesos
+type Ficha string
+
+// Ligadura son las fichas que consume y produce un disparo, por lugar
+type Ligadura struct {
+	Consume map[int][]Ficha
+	Produce map[int][]Ficha
+}
+
+/*
+ColorRule decide los disparos de las transiciones coloreadas: Bind busca,
+con las fichas y marcas de los lugares que mantiene la subred, una ligadura
+de las variables de la transición que cumpla su guarda, y devuelve nil si no
+la hay. La búsqueda debe ser determinista para que la simulación distribuida
+elija las mismas fichas que la secuencial.
+*/
+type ColorRule interface {
+	Bind(t IndLocalTrans, lugar func(id int) ([]Ficha, TypeConst)) *Ligadura
+}
+
+// SetColorRule indica la regla de las transiciones coloreadas de la subred
+func (se *SimulationEngine) SetColorRule(rule ColorRule) {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	se.ilMislefs.Colores = rule
+}
+
+// liga busca la ligadura de una transición coloreada, nil si no puede disparar
+func (l Lefs) liga(t Transition) *Ligadura {
+	if !t.Coloreada || l.Colores == nil {
+		return nil
+	}
+	return l.Colores.Bind(t.IiIndLocal, l.fichasDe)
+}
+
+// ligable indica si una transición coloreada tiene alguna ligadura; las demás
+// solo dependen de su función de sensibilización
+func (l Lefs) ligable(t Transition) bool {
+	return !t.Coloreada || l.Colores == nil || l.liga(t) != nil
+}
+
+// fichasDe devuelve las fichas y las marcas del lugar id
+func (l Lefs) fichasDe(id int) ([]Ficha, TypeConst) {
+	i := l.findPlace(id)
+	if i < 0 {
+		return nil, 0
+	}
+	return l.IaLugares[i].IaFichas, l.IaLugares[i].IiMarcas
+}
+
+// agnadeFichas añade las fichas al multiconjunto ordenado del lugar id
+func (l *Lefs) agnadeFichas(id int, fichas []Ficha) {
+	i := l.findPlace(id)
+	if i < 0 || len(fichas) == 0 {
+		return
+	}
+	p := &l.IaLugares[i]
+	p.IaFichas = append(append([]Ficha{}, p.IaFichas...), fichas...)
+	sort.Slice(p.IaFichas, func(a, b int) bool { return p.IaFichas[a] < p.IaFichas[b] })
+}
+
+// quitaFichas retira una copia de cada ficha del multiconjunto del lugar id
+func (l *Lefs) quitaFichas(id int, fichas []Ficha) {
+	i := l.findPlace(id)
+	if i < 0 || len(fichas) == 0 {
+		return
+	}
+	quitar := map[Ficha]int{}
+	for _, f := range fichas {
+		quitar[f]++
+	}
+	resto := []Ficha{}
+	for _, f := range l.IaLugares[i].IaFichas {
+		if quitar[f] > 0 {
+			quitar[f]--
+			continue
+		}
+		resto = append(resto, f)
+	}
+	l.IaLugares[i].IaFichas = resto
+}
@@ -0,0 +1,365 @@
+/*
+Package colored extiende las redes de package petrinet con marcas que llevan
+datos, como en las redes de Petri coloreadas: cada lugar tiene un conjunto de
+colores y guarda un multiconjunto de fichas, los arcos de entrada son
+patrones que ligan variables con las fichas que consumen, las guardas
+restringen las ligaduras y los arcos de salida calculan las fichas que
+producen. La red se compila a la Lefs de su proyección sin colores, cuyos
+lugares coloreados mantienen las fichas, y el modelo compilado es la
+centralsim.ColorRule que elige las ligaduras durante la simulación, también
+repartida entre procesos.
+*/
+package colored
+
+import (
+	"centralsim"
+	"centralsim/petrinet"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Place es un lugar de la red. Con Color guarda fichas de ese conjunto, y
+// su marcado inicial son Tokens; sin él es un lugar de marcas sin valor
+type Place struct {
+	Name    string            `json:"name"`
+	Color   string            `json:"color,omitempty"`
+	Initial int               `json:"initial,omitempty"`
+	Tokens  []json.RawMessage `json:"tokens,omitempty"`
+}
+
+/*
+Arc une un lugar con una transición; el peso por defecto es 1. En un lugar
+coloreado Expr es, en los arcos de entrada, un patrón que liga variables con
+una ficha, "_" por defecto, y en los de salida la expresión de la ficha que
+produce; el arco consume o produce Weight copias de esa ficha.
+*/
+type Arc struct {
+	Place  string `json:"place"`
+	Weight int    `json:"weight,omitempty"`
+	Expr   string `json:"expr,omitempty"`
+}
+
+// Transition es una transición de petrinet.Transition con arcos coloreados y
+// una guarda, una expresión booleana de las variables de sus arcos de entrada
+type Transition struct {
+	Name       string                 `json:"name"`
+	Duration   centralsim.TypeClock   `json:"duration"`
+	Priority   int                    `json:"priority,omitempty"`
+	Interval   []centralsim.TypeClock `json:"interval,omitempty"`
+	Servers    int                    `json:"servers,omitempty"`
+	Memory     string                 `json:"memory,omitempty"`
+	Guard      string                 `json:"guard,omitempty"`
+	Inputs     []Arc                  `json:"inputs"`
+	Outputs    []Arc                  `json:"outputs"`
+	Inhibitors []petrinet.Arc         `json:"inhibitors,omitempty"`
+	Resets     []string               `json:"resets,omitempty"`
+}
+
+// Net es una red de Petri coloreada
+type Net struct {
+	Colors      []ColorSet   `json:"colors,omitempty"`
+	Places      []Place      `json:"places"`
+	Transitions []Transition `json:"transitions"`
+	Policy      string       `json:"policy,omitempty"`
+	Seed        int64        `json:"seed,omitempty"`
+}
+
+// Load lee una red coloreada en formato JSON
+func Load(filename string) (*Net, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file)
+}
+
+// Decode lee una red coloreada en formato JSON
+func Decode(r io.Reader) (*Net, error) {
+	net := &Net{}
+	if err := json.NewDecoder(r).Decode(net); err != nil {
+		return nil, fmt.Errorf("decode colored net: %w", err)
+	}
+	return net, nil
+}
+
+// Arco compilado: lugar, peso y patrón o expresión, nil si el lugar no es coloreado
+type arc struct {
+	place  int
+	weight int
+	expr   node
+}
+
+// Transición compilada
+type transition struct {
+	colored bool
+	guard   node // nil si no tiene
+	inputs  []arc
+	outputs []arc
+}
+
+// Model es una red coloreada compilada: el modelo de su proyección sin
+// colores y la regla que liga las variables de sus transiciones
+type Model struct {
+	*petrinet.Model
+	Colored     *Net
+	colors      []*Type // conjunto de colores de cada lugar, nil si no es coloreado
+	transitions []transition
+}
+
+/*
+Compile comprueba los tipos de la red y la traduce a una Lefs. Las
+transiciones con algún lugar coloreado o con guarda son coloreadas: mantienen
+el marcado de todos sus lugares de entrada y solo disparan con una ligadura.
+*/
+func Compile(net *Net) (*Model, error) {
+	types, err := net.types()
+	if err != nil {
+		return nil, err
+	}
+	m := &Model{Colored: net, colors: make([]*Type, len(net.Places))}
+	projection := &petrinet.Net{Policy: net.Policy, Seed: net.Seed}
+	tokens := make([][]centralsim.Ficha, len(net.Places))
+	for p, place := range net.Places {
+		plain := petrinet.Place{Name: place.Name, Initial: place.Initial}
+		if place.Color != "" {
+			t, ok := types[place.Color]
+			if !ok {
+				return nil, fmt.Errorf("place %v: unknown color set %v", place.Name, place.Color)
+			}
+			if place.Initial != 0 {
+				return nil, fmt.Errorf("place %v: colored places have tokens instead of an initial marking", place.Name)
+			}
+			for _, raw := range place.Tokens {
+				value, err := t.decode(centralsim.Ficha(raw))
+				if err != nil {
+					return nil, fmt.Errorf("place %v: %w", place.Name, err)
+				}
+				tokens[p] = append(tokens[p], encode(value))
+			}
+			m.colors[p] = t
+			plain.Initial = len(tokens[p])
+			plain.Tracked = true
+		} else if len(place.Tokens) > 0 {
+			return nil, fmt.Errorf("place %v: only colored places have tokens", place.Name)
+		}
+		projection.Places = append(projection.Places, plain)
+	}
+
+	m.transitions = make([]transition, len(net.Transitions))
+	for i, t := range net.Transitions {
+		tr, err := m.compileTransition(t)
+		if err != nil {
+			return nil, fmt.Errorf("transition %v: %w", t.Name, err)
+		}
+		m.transitions[i] = tr
+		plain := petrinet.Transition{Name: t.Name, Duration: t.Duration, Priority: t.Priority, Interval: t.Interval,
+			Servers: t.Servers, Memory: t.Memory, Inhibitors: t.Inhibitors, Resets: t.Resets}
+		for _, a := range t.Inputs {
+			plain.Inputs = append(plain.Inputs, petrinet.Arc{Place: a.Place, Weight: a.Weight})
+		}
+		for _, a := range t.Outputs {
+			plain.Outputs = append(plain.Outputs, petrinet.Arc{Place: a.Place, Weight: a.Weight})
+		}
+		projection.Transitions = append(projection.Transitions, plain)
+	}
+	// las transiciones coloreadas comprueban el marcado exacto de sus entradas
+	for _, tr := range m.transitions {
+		for _, a := range tr.inputs {
+			if tr.colored {
+				projection.Places[a.place].Tracked = true
+			}
+		}
+	}
+
+	m.Model, err = petrinet.Compile(projection)
+	if err != nil {
+		return nil, err
+	}
+	for i := range m.Lefs.IaLugares {
+		lugar := &m.Lefs.IaLugares[i]
+		lugar.IaFichas = append([]centralsim.Ficha(nil), tokens[lugar.IiIdGlobal]...)
+		sort.Slice(lugar.IaFichas, func(a, b int) bool { return lugar.IaFichas[a] < lugar.IaFichas[b] })
+	}
+	for i, tr := range m.transitions {
+		m.Lefs.IaRed[i].Coloreada = tr.colored
+	}
+	m.Lefs.Colores = m
+	return m, nil
+}
+
+// compileTransition analiza y comprueba los tipos de la guarda y los arcos
+func (m *Model) compileTransition(t Transition) (transition, error) {
+	tr := transition{colored: t.Guard != ""}
+	vars := map[string]*Type{}
+	compileArcs := func(arcs []Arc, input bool) ([]arc, error) {
+		compiled := []arc{}
+		for _, a := range arcs {
+			p := m.Colored.placeIndex(a.Place)
+			if p < 0 {
+				return nil, fmt.Errorf("unknown place %v", a.Place)
+			}
+			c := arc{place: p, weight: a.Weight}
+			if c.weight == 0 {
+				c.weight = 1
+			}
+			color := m.colors[p]
+			if color == nil {
+				if a.Expr != "" {
+					return nil, fmt.Errorf("expression %q on uncolored place %v", a.Expr, a.Place)
+				}
+				compiled = append(compiled, c)
+				continue
+			}
+			tr.colored = true
+			expr := a.Expr
+			if expr == "" {
+				if !input {
+					return nil, fmt.Errorf("output to colored place %v without expression", a.Place)
+				}
+				expr = "_"
+			}
+			n, err := parse(expr)
+			if err != nil {
+				return nil, err
+			}
+			if input {
+				err = pattern(n, color, vars)
+			} else {
+				var et *Type
+				if et, err = n.check(vars); err == nil && !assignable(n, et, color) {
+					err = fmt.Errorf("expression %q is %v and place %v is %v", expr, et, a.Place, color)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("arc with %v: %w", a.Place, err)
+			}
+			c.expr = n
+			compiled = append(compiled, c)
+		}
+		return compiled, nil
+	}
+	var err error
+	if tr.inputs, err = compileArcs(t.Inputs, true); err != nil {
+		return tr, err
+	}
+	if t.Guard != "" {
+		if tr.guard, err = parse(t.Guard); err != nil {
+			return tr, err
+		}
+		gt, err := tr.guard.check(vars)
+		if err != nil {
+			return tr, fmt.Errorf("guard: %w", err)
+		}
+		if gt.Kind != BoolKind {
+			return tr, fmt.Errorf("guard %q is %v", t.Guard, gt)
+		}
+	}
+	if tr.outputs, err = compileArcs(t.Outputs, false); err != nil {
+		return tr, err
+	}
+	return tr, nil
+}
+
+// placeIndex devuelve la posición del lugar con ese nombre, -1 si no existe
+func (n *Net) placeIndex(name string) int {
+	for i, p := range n.Places {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+Bind busca la primera ligadura de la transición t, en el orden de sus arcos
+de entrada y de las fichas de cada lugar, que cumple su guarda y para la que
+hay marcas suficientes en todos sus lugares de entrada. La llaman a la vez
+los motores de los procesos que comparten el modelo, por lo que no modifica
+su estado.
+*/
+func (m *Model) Bind(t centralsim.IndLocalTrans, lugar func(id int) ([]centralsim.Ficha, centralsim.TypeConst)) *centralsim.Ligadura {
+	if int(t) < 0 || int(t) >= len(m.transitions) {
+		return nil
+	}
+	tr := m.transitions[t]
+	inputs := []arc{}
+	candidates := [][]interface{}{} // valores distintos de cada arco coloreado con fichas suficientes
+	for _, a := range tr.inputs {
+		fichas, marcas := lugar(a.place)
+		if marcas < centralsim.TypeConst(a.weight) {
+			return nil
+		}
+		if a.expr == nil {
+			continue
+		}
+		values := []interface{}{}
+		for i := 0; i < len(fichas); {
+			j := i
+			for j < len(fichas) && fichas[j] == fichas[i] {
+				j++
+			}
+			if j-i >= a.weight {
+				if v, err := m.colors[a.place].decode(fichas[i]); err == nil {
+					values = append(values, v)
+				}
+			}
+			i = j
+		}
+		inputs = append(inputs, a)
+		candidates = append(candidates, values)
+	}
+
+	env := map[string]interface{}{}
+	chosen := make([]interface{}, len(inputs))
+	var search func(i int) bool
+	search = func(i int) bool {
+		if i == len(inputs) {
+			return tr.guard == nil || tr.guard.eval(env).(bool)
+		}
+		for _, v := range candidates[i] {
+			bound, ok := match(inputs[i].expr, v, env)
+			if ok && search(i+1) {
+				chosen[i] = v
+				return true
+			}
+			unbind(env, bound)
+		}
+		return false
+	}
+	if !search(0) {
+		return nil
+	}
+
+	ligadura := &centralsim.Ligadura{Consume: map[int][]centralsim.Ficha{}, Produce: map[int][]centralsim.Ficha{}}
+	for i, a := range inputs {
+		for k := 0; k < a.weight; k++ {
+			ligadura.Consume[a.place] = append(ligadura.Consume[a.place], encode(chosen[i]))
+		}
+	}
+	for _, a := range tr.outputs {
+		if a.expr == nil {
+			continue
+		}
+		ficha := encode(a.expr.eval(env))
+		for k := 0; k < a.weight; k++ {
+			ligadura.Produce[a.place] = append(ligadura.Produce[a.place], ficha)
+		}
+	}
+	return ligadura
+}
+
+// Tokens devuelve las fichas de cada lugar coloreado por nombre en un
+// checkpoint del motor
+func (m *Model) Tokens(cp centralsim.Checkpoint) map[string][]centralsim.Ficha {
+	tokens := map[string][]centralsim.Ficha{}
+	for _, p := range cp.Places {
+		if p.IiIdGlobal >= 0 && p.IiIdGlobal < len(m.colors) && m.colors[p.IiIdGlobal] != nil {
+			tokens[m.Colored.Places[p.IiIdGlobal].Name] = p.IaFichas
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,139 @@
+package colored
+
+import (
+	"centralsim"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Una máquina pinta los pedidos del producto A, o los de identificador mayor
+// que 2, y un transportista los envía renumerados
+const workflowNet = `{
+  "colors": [{"name": "Producto", "enum": ["A", "B"]}, {"name": "Pedido", "tuple": ["int", "Producto"]}],
+  "places": [
+    {"name": "pedidos", "color": "Pedido", "tokens": [[1, "A"], [2, "B"], [3, "A"]]},
+    {"name": "maquina", "initial": 1},
+    {"name": "pintados", "color": "Pedido"},
+    {"name": "enviados", "color": "Pedido"},
+    {"name": "turno", "initial": 1}
+  ],
+  "transitions": [
+    {"name": "pinta", "duration": 2, "guard": "p == \"A\" || id > 2",
+      "inputs": [{"place": "pedidos", "expr": "(id, p)"}, {"place": "maquina"}],
+      "outputs": [{"place": "pintados", "expr": "(id * 10, p)"}, {"place": "maquina"}]},
+    {"name": "envia", "duration": 1, "inputs": [{"place": "pintados", "expr": "(id, p)"}],
+      "outputs": [{"place": "enviados", "expr": "(id + 1, p)"}]},
+    {"name": "cambia", "duration": 20, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}]}
+  ]
+}`
+
+// Simula el modelo en un único motor hasta el ciclo indicado, registrando el marcado
+func simulate(model *Model, cycles centralsim.TypeClock) *centralsim.SimulationEngine {
+	se := centralsim.MakeSimulationEngine(model.Lefs, centralsim.NopLogger("0"),
+		make(chan centralsim.Event), make(chan centralsim.IncommingEvent), make(chan bool),
+		make(chan centralsim.LookAhead), make(chan centralsim.LookAhead),
+		make(chan centralsim.LookAhead), make(chan centralsim.LookAhead),
+		map[int]centralsim.TypeClock{}, 0, nil)
+	se.ObservePlaces(model.PlaceModel())
+	se.SimularPeriodo(0, cycles)
+	return se
+}
+
+func compile(t *testing.T, src string) *Model {
+	net, err := Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestWorkflow(t *testing.T) {
+	model := compile(t, workflowNet)
+	se := simulate(model, 15)
+	firings := model.Firings(se.Checkpoint().Results)
+	if firings["pinta"] != 2 || firings["envia"] != 2 {
+		t.Errorf("firings %v, expected pinta and envia twice", firings)
+	}
+	want := map[string][]centralsim.Ficha{
+		"pedidos":  {`[2,"B"]`},
+		"pintados": nil,
+		"enviados": {`[11,"A"]`, `[31,"A"]`},
+	}
+	if got := model.Tokens(se.Checkpoint()); !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens %v, expected %v", got, want)
+	}
+	if got := model.Format(se.Marking()); got != "pedidos=1 maquina=1 pintados=0 enviados=2 turno=1" {
+		t.Errorf("marking %v", got)
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	vars := map[string]*Type{"n": IntType, "s": StringType, "b": BoolType}
+	env := map[string]interface{}{"n": int64(4), "s": "ab", "b": false}
+	for src, want := range map[string]interface{}{
+		`n * 2 + 1`:              int64(9),
+		`-(n - 6) * 3`:           int64(6),
+		`s + "c"`:                "abc",
+		`s < "b" && !b`:          true,
+		`b || n >= 4`:            true,
+		`(n, s) == (4, "ab")`:    true,
+		`(n, (s, b))`:            []interface{}{int64(4), []interface{}{"ab", false}},
+		`"x\"y" != s`:            true,
+		`n - 1 - 1 == 2 && true`: true,
+	} {
+		n, err := parse(src)
+		if err != nil {
+			t.Errorf("%v: %v", src, err)
+			continue
+		}
+		if _, err := n.check(vars); err != nil {
+			t.Errorf("%v: %v", src, err)
+			continue
+		}
+		if got := n.eval(env); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v = %v, expected %v", src, got, want)
+		}
+	}
+	for _, src := range []string{`n + s`, `!n`, `b < b`, `(n, s) == (s, n)`, `x`, `n +`, `(n`, `"a`, `n % 2`} {
+		n, err := parse(src)
+		if err == nil {
+			_, err = n.check(vars)
+		}
+		if err == nil {
+			t.Errorf("%v: expected error", src)
+		}
+	}
+}
+
+func TestCompileRejectsIllTypedNets(t *testing.T) {
+	for _, tc := range []struct {
+		replace, with, err string
+	}{
+		{`"p == \"A\" || id > 2"`, `"id"`, "guard"},
+		{`"p == \"A\" || id > 2"`, `"q == \"A\""`, "unbound variable q"},
+		{`"(id + 1, p)"`, `"(id, \"C\")"`, "enviados"},
+		{`"(id + 1, p)"`, `"(p, id)"`, "enviados"},
+		{`"expr": "(id * 10, p)"`, `"expr": "(id, p, 1)"`, "pintados"},
+		{`"expr": "(id, p)"}, {"place": "maquina"}`, `"expr": "(id, \"C\")"}, {"place": "maquina"}`, "not a value"},
+		{`[2, "B"]`, `[2, "C"]`, "pedidos"},
+		{`{"place": "maquina"}]`, `{"place": "maquina", "expr": "1"}]`, "uncolored"},
+		{`"tuple": ["int", "Producto"]`, `"tuple": ["int", "Color"]`, "unknown color set"},
+	} {
+		src := strings.Replace(workflowNet, tc.replace, tc.with, 1)
+		if src == workflowNet {
+			t.Fatalf("%v not found", tc.replace)
+		}
+		net, err := Decode(strings.NewReader(src))
+		if err == nil {
+			_, err = Compile(net)
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: error %v, expected %q", tc.with, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package colored
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+Expresiones de guardas y arcos. La gramática, de menor a mayor precedencia:
+
+	expr    = and { "||" and }
+	and     = cmp { "&&" cmp }
+	cmp     = sum [ ("==" | "!=" | "<" | "<=" | ">" | ">=") sum ]
+	sum     = product { ("+" | "-") product }
+	product = unary { "*" unary }
+	unary   = ("!" | "-") unary | primary
+	primary = entero | "cadena" | true | false | variable | "(" expr { "," expr } ")"
+
+Un paréntesis con varias expresiones separadas por comas es una tupla, y "_"
+solo aparece en los patrones de los arcos de entrada.
+*/
+type node interface {
+	// check comprueba los tipos con las variables ligadas y devuelve el de la expresión
+	check(vars map[string]*Type) (*Type, error)
+	eval(env map[string]interface{}) interface{}
+}
+
+type literal struct {
+	value interface{}
+	typ   *Type
+}
+
+type variable struct{ name string }
+
+type tuple struct{ items []node }
+
+type unary struct {
+	op string
+	x  node
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+// parse analiza una expresión
+func parse(src string) (node, error) {
+	p := &parser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("expression %q: unexpected %q", src, p.tok)
+	}
+	return n, nil
+}
+
+type parser struct {
+	src string
+	pos int
+	tok string // símbolo actual, "" al final
+}
+
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "!", "(", ")", ","}
+
+// next lee el siguiente símbolo
+func (p *parser) next() error {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == len(p.src) {
+		p.tok = ""
+		return nil
+	}
+	rest := p.src[p.pos:]
+	c := rune(rest[0])
+	end := 0
+	switch {
+	case c == '"':
+		end = 1
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			return fmt.Errorf("expression %q: unterminated string", p.src)
+		}
+		end++
+	case unicode.IsDigit(c):
+		for end < len(rest) && unicode.IsDigit(rune(rest[end])) {
+			end++
+		}
+	case unicode.IsLetter(c) || c == '_':
+		for end < len(rest) && (unicode.IsLetter(rune(rest[end])) || unicode.IsDigit(rune(rest[end])) || rest[end] == '_') {
+			end++
+		}
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op) {
+				end = len(op)
+				break
+			}
+		}
+		if end == 0 {
+			return fmt.Errorf("expression %q: unexpected character %q", p.src, c)
+		}
+	}
+	p.tok = rest[:end]
+	p.pos += end
+	return nil
+}
+
+// binaryLevel analiza una secuencia de operandos de un nivel de precedencia
+func (p *parser) binaryLevel(ops []string, operand func() (node, error), repeat bool) (node, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range ops {
+			if p.tok == o {
+				op = o
+			}
+		}
+		if op == "" {
+			return x, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op, x, y}
+		if !repeat {
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) expr() (node, error) {
+	return p.binaryLevel([]string{"||"}, p.and, true)
+}
+
+func (p *parser) and() (node, error) {
+	return p.binaryLevel([]string{"&&"}, p.cmp, true)
+}
+
+func (p *parser) cmp() (node, error) {
+	return p.binaryLevel([]string{"==", "!=", "<", "<=", ">", ">="}, p.sum, false)
+}
+
+func (p *parser) sum() (node, error) {
+	return p.binaryLevel([]string{"+", "-"}, p.product, true)
+}
+
+func (p *parser) product() (node, error) {
+	return p.binaryLevel([]string{"*"}, p.unary, true)
+}
+
+func (p *parser) unary() (node, error) {
+	if p.tok == "!" || p.tok == "-" {
+		op := p.tok
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op, x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.tok
+	if tok == "" {
+		return nil, fmt.Errorf("expression %q: unexpected end", p.src)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	c := rune(tok[0])
+	switch {
+	case tok == "(":
+		items := []node{}
+		for {
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, x)
+			if p.tok != "," {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if p.tok != ")" {
+			return nil, fmt.Errorf("expression %q: missing )", p.src)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		return tuple{items}, nil
+	case c == '"':
+		s, err := strconv.Unquote(tok)
+		if err != nil {
+			return nil, fmt.Errorf("expression %q: invalid string %v", p.src, tok)
+		}
+		return literal{s, StringType}, nil
+	case unicode.IsDigit(c):
+		n, err := strconv.ParseInt(tok, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expression %q: invalid integer %v", p.src, tok)
+		}
+		return literal{n, IntType}, nil
+	case tok == "true" || tok == "false":
+		return literal{tok == "true", BoolType}, nil
+	case unicode.IsLetter(c) || c == '_':
+		return variable{tok}, nil
+	}
+	return nil, fmt.Errorf("expression %q: unexpected %q", p.src, tok)
+}
+
+func (n literal) check(vars map[string]*Type) (*Type, error) {
+	return n.typ, nil
+}
+
+func (n literal) eval(env map[string]interface{}) interface{} {
+	return n.value
+}
+
+func (n variable) check(vars map[string]*Type) (*Type, error) {
+	if n.name == "_" {
+		return nil, fmt.Errorf("_ is only allowed in input patterns")
+	}
+	t, ok := vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unbound variable %v", n.name)
+	}
+	return t, nil
+}
+
+func (n variable) eval(env map[string]interface{}) interface{} {
+	return env[n.name]
+}
+
+func (n tuple) check(vars map[string]*Type) (*Type, error) {
+	t := &Type{Kind: TupleKind}
+	for _, item := range n.items {
+		it, err := item.check(vars)
+		if err != nil {
+			return nil, err
+		}
+		t.Fields = append(t.Fields, it)
+	}
+	return t, nil
+}
+
+func (n tuple) eval(env map[string]interface{}) interface{} {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(env)
+	}
+	return values
+}
+
+func (n unary) check(vars map[string]*Type) (*Type, error) {
+	t, err := n.x.check(vars)
+	if err != nil {
+		return nil, err
+	}
+	want := IntType
+	if n.op == "!" {
+		want = BoolType
+	}
+	if t.Kind != want.Kind {
+		return nil, fmt.Errorf("operator %v on %v", n.op, t)
+	}
+	return want, nil
+}
+
+func (n unary) eval(env map[string]interface{}) interface{} {
+	x := n.x.eval(env)
+	if n.op == "!" {
+		return !x.(bool)
+	}
+	return -x.(int64)
+}
+
+func (n binary) check(vars map[string]*Type) (*Type, error) {
+	x, err := n.x.check(vars)
+	if err != nil {
+		return nil, err
+	}
+	y, err := n.y.check(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "||", "&&":
+		if x.Kind == BoolKind && y.Kind == BoolKind {
+			return BoolType, nil
+		}
+	case "==", "!=":
+		if comparable(x, y) {
+			return BoolType, nil
+		}
+	case "<", "<=", ">", ">=":
+		if x.Kind == IntKind && y.Kind == IntKind || x.textual() && y.textual() {
+			return BoolType, nil
+		}
+	case "+":
+		if x.Kind == StringKind && y.Kind == StringKind {
+			return StringType, nil
+		}
+		fallthrough
+	case "-", "*":
+		if x.Kind == IntKind && y.Kind == IntKind {
+			return IntType, nil
+		}
+	}
+	return nil, fmt.Errorf("operator %v on %v and %v", n.op, x, y)
+}
+
+func (n binary) eval(env map[string]interface{}) interface{} {
+	x := n.x.eval(env)
+	// los operadores lógicos no evalúan el segundo operando si no hace falta
+	switch n.op {
+	case "||":
+		return x.(bool) || n.y.eval(env).(bool)
+	case "&&":
+		return x.(bool) && n.y.eval(env).(bool)
+	}
+	y := n.y.eval(env)
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(x, y)
+	case "!=":
+		return !reflect.DeepEqual(x, y)
+	case "+":
+		if s, ok := x.(string); ok {
+			return s + y.(string)
+		}
+		return x.(int64) + y.(int64)
+	case "-":
+		return x.(int64) - y.(int64)
+	case "*":
+		return x.(int64) * y.(int64)
+	}
+	if s, ok := x.(string); ok {
+		return order(n.op, strings.Compare(s, y.(string)))
+	}
+	a, b := x.(int64), y.(int64)
+	c := 0
+	if a < b {
+		c = -1
+	} else if a > b {
+		c = 1
+	}
+	return order(n.op, c)
+}
+
+// order aplica un operador de orden al resultado de una comparación
+func order(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
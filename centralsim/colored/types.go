@@ -0,0 +1,292 @@
+package colored
+
+import (
+	"centralsim"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Kind es la clase de un conjunto de colores
+type Kind int
+
+// Clases de conjuntos de colores
+const (
+	IntKind Kind = iota
+	StringKind
+	BoolKind
+	EnumKind
+	TupleKind
+)
+
+// Type es un conjunto de colores: uno de los básicos int, string y bool, una
+// enumeración de cadenas o una tupla de otros conjuntos
+type Type struct {
+	Name   string
+	Kind   Kind
+	Values []string // valores de una enumeración
+	Fields []*Type  // componentes de una tupla
+}
+
+// Conjuntos de colores básicos
+var (
+	IntType    = &Type{Name: "int", Kind: IntKind}
+	StringType = &Type{Name: "string", Kind: StringKind}
+	BoolType   = &Type{Name: "bool", Kind: BoolKind}
+)
+
+func (t *Type) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	fields := make([]string, len(t.Fields))
+	for i, f := range t.Fields {
+		fields[i] = f.String()
+	}
+	return "(" + strings.Join(fields, ", ") + ")"
+}
+
+// textual indica si los valores del conjunto son cadenas
+func (t *Type) textual() bool {
+	return t.Kind == StringKind || t.Kind == EnumKind
+}
+
+// member indica si la cadena es un valor de la enumeración
+func (t *Type) member(s string) bool {
+	for _, v := range t.Values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// comparable indica si se pueden comparar con == valores de ambos conjuntos
+func comparable(x, y *Type) bool {
+	if x.textual() && y.textual() {
+		return true
+	}
+	if x.Kind != y.Kind {
+		return false
+	}
+	if x.Kind != TupleKind {
+		return true
+	}
+	if len(x.Fields) != len(y.Fields) {
+		return false
+	}
+	for i := range x.Fields {
+		if !comparable(x.Fields[i], y.Fields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// assignable indica si la expresión n, de tipo from, da valores del conjunto
+// to. Una cadena solo pertenece a una enumeración si es uno de sus valores
+// literales, para que la comprobación sea estática.
+func assignable(n node, from, to *Type) bool {
+	switch to.Kind {
+	case EnumKind:
+		if from == to {
+			return true
+		}
+		l, ok := n.(literal)
+		return ok && from.Kind == StringKind && to.member(l.value.(string))
+	case TupleKind:
+		if from.Kind != TupleKind || len(from.Fields) != len(to.Fields) {
+			return false
+		}
+		items, _ := n.(tuple)
+		for i := range to.Fields {
+			var item node = variable{}
+			if items.items != nil {
+				item = items.items[i]
+			}
+			if !assignable(item, from.Fields[i], to.Fields[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return from.Kind == to.Kind
+}
+
+// ColorSet declara un conjunto de colores con nombre: una enumeración de
+// cadenas o una tupla de conjuntos básicos o declarados antes
+type ColorSet struct {
+	Name  string   `json:"name"`
+	Enum  []string `json:"enum,omitempty"`
+	Tuple []string `json:"tuple,omitempty"`
+}
+
+// types devuelve los conjuntos de colores de la red por nombre
+func (n *Net) types() (map[string]*Type, error) {
+	types := map[string]*Type{"int": IntType, "string": StringType, "bool": BoolType}
+	for _, c := range n.Colors {
+		if c.Name == "" {
+			return nil, fmt.Errorf("color set without name")
+		}
+		if types[c.Name] != nil {
+			return nil, fmt.Errorf("duplicated color set %v", c.Name)
+		}
+		t := &Type{Name: c.Name}
+		switch {
+		case len(c.Enum) > 0 && len(c.Tuple) == 0:
+			t.Kind = EnumKind
+			for _, v := range c.Enum {
+				if t.member(v) {
+					return nil, fmt.Errorf("color set %v: duplicated value %q", c.Name, v)
+				}
+				t.Values = append(t.Values, v)
+			}
+		case len(c.Tuple) > 1 && len(c.Enum) == 0:
+			t.Kind = TupleKind
+			for _, name := range c.Tuple {
+				field, ok := types[name]
+				if !ok {
+					return nil, fmt.Errorf("color set %v: unknown color set %v", c.Name, name)
+				}
+				t.Fields = append(t.Fields, field)
+			}
+		default:
+			return nil, fmt.Errorf("color set %v: needs an enum or a tuple of at least two color sets", c.Name)
+		}
+		types[c.Name] = t
+	}
+	return types, nil
+}
+
+// value convierte un valor JSON, decodificado con números json.Number, en un
+// valor del conjunto: int64, string, bool o []interface{} para las tuplas
+func (t *Type) value(v interface{}) (interface{}, error) {
+	switch t.Kind {
+	case IntKind:
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+		}
+	case StringKind:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case BoolKind:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case EnumKind:
+		if s, ok := v.(string); ok && t.member(s) {
+			return s, nil
+		}
+	case TupleKind:
+		if items, ok := v.([]interface{}); ok && len(items) == len(t.Fields) {
+			values := make([]interface{}, len(items))
+			for i, item := range items {
+				value, err := t.Fields[i].value(item)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = value
+			}
+			return values, nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not a value of %v", v, t)
+}
+
+// decode lee una ficha del conjunto
+func (t *Type) decode(f centralsim.Ficha) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(string(f)))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return t.value(v)
+}
+
+// encode codifica un valor como ficha; la codificación es canónica, de modo
+// que dos fichas son iguales si y solo si lo son sus valores
+func encode(v interface{}) centralsim.Ficha {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err) // los valores solo contienen enteros, cadenas, booleanos y tuplas
+	}
+	return centralsim.Ficha(b)
+}
+
+// pattern comprueba un patrón de un arco de entrada con valores del conjunto
+// t: una variable, que se liga la primera vez que aparece, "_", un literal o
+// una tupla de patrones
+func pattern(n node, t *Type, vars map[string]*Type) error {
+	switch p := n.(type) {
+	case variable:
+		if p.name == "_" {
+			return nil
+		}
+		if bound, ok := vars[p.name]; ok {
+			if !comparable(bound, t) {
+				return fmt.Errorf("variable %v is %v and %v", p.name, bound, t)
+			}
+			return nil
+		}
+		vars[p.name] = t
+		return nil
+	case literal:
+		if !assignable(p, p.typ, t) {
+			return fmt.Errorf("%v is not a value of %v", p.value, t)
+		}
+		return nil
+	case tuple:
+		if t.Kind != TupleKind || len(t.Fields) != len(p.items) {
+			return fmt.Errorf("tuple of %v does not match %v", len(p.items), t)
+		}
+		for i, item := range p.items {
+			if err := pattern(item, t.Fields[i], vars); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("input patterns only have variables, literals and tuples")
+}
+
+// match compara un valor con un patrón ya comprobado, ligando en env sus
+// variables libres; devuelve las que ha ligado para poder deshacerlo
+func match(n node, v interface{}, env map[string]interface{}) ([]string, bool) {
+	switch p := n.(type) {
+	case variable:
+		if p.name == "_" {
+			return nil, true
+		}
+		if bound, ok := env[p.name]; ok {
+			return nil, reflect.DeepEqual(bound, v)
+		}
+		env[p.name] = v
+		return []string{p.name}, true
+	case literal:
+		return nil, reflect.DeepEqual(p.value, v)
+	case tuple:
+		values := v.([]interface{})
+		bound := []string{}
+		for i, item := range p.items {
+			names, ok := match(item, values[i], env)
+			bound = append(bound, names...)
+			if !ok {
+				unbind(env, bound)
+				return nil, false
+			}
+		}
+		return bound, true
+	}
+	return nil, false
+}
+
+func unbind(env map[string]interface{}, names []string) {
+	for _, name := range names {
+		delete(env, name)
+	}
+}
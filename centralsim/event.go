@@ -1,8 +1,6 @@
 //Package centralsim with several files to offer a centralized simulation
 package centralsim
 
-import "fmt"
-
 // Event define el evento básico de simulación
 type Event struct {
 	// Tiempo para el que debemos considerar el evento
@@ -11,6 +9,11 @@ type Event struct {
 	IiTransicion IndLocalTrans
 	// Constante que mandamos
 	IiCte TypeConst
+	// Si es distinto de 0, el evento cambia las marcas del lugar IiLugar-1
+	// en lugar de la función de sensibilización de la transición
+	IiLugar int `json:",omitempty"`
+	// Fichas que añade al lugar si es coloreado
+	IaFichas []Ficha `json:",omitempty"`
 }
 
 /*
@@ -63,9 +66,7 @@ func (e Event) getCte() TypeConst {
 
 // Imprime atributos de evento para depurar errores
 func (e Event) Imprime(i int, log *Logger) {
-	log.Event.Println("  Evento -> ", i)
-	log.NoFmtLog.Println(
-		fmt.Sprintf("    TIEMPO: %v  TRANSICION: %v  CONSTANTE: %v", e.IiTiempo, e.IiTransicion, e.IiCte))
+	log.Trace("Evento", "index", i, "time", e.IiTiempo, "transition", e.IiTransicion, "cte", e.IiCte)
 }
 
 //----------------------------------------------------------------------------
@@ -163,8 +164,11 @@ func (el *EventList) hayEventos(aiTiempo TypeClock) bool {
 
 // Imprime la lista de eventos para depurar errores
 func (el EventList) Imprime(log *Logger) {
+	if !log.Enabled(LevelTrace) {
+		return
+	}
 	if len(el) == 0 {
-		log.NoFmtLog.Println("LISTA VACÍA")
+		log.Trace("Lista de eventos vacía")
 	}
 	for i, e := range el {
 		e.Imprime(i, log)
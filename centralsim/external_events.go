@@ -2,6 +2,9 @@ package centralsim
 
 import (
 	"errors"
+	"math"
+	"sort"
+	"time"
 )
 
 type LookAhead struct {
@@ -17,26 +20,48 @@ type IncommingEvent struct {
 
 // Rutina encargada de añadir eventos entrantes a la lista
 func (se *SimulationEngine) manageIncommingEvents() {
+	defer se.routines.Done()
 	for {
 		select {
 		case event := <-se.incomEventsCh:
 			se.mux.Lock()
+			se.Log.Debug("Evento insertado", "process", event.ProcessId, "transition", event.Event.IiTransicion,
+				"cte", event.Event.IiCte, "time", event.Event.IiTiempo, "clock", se.iiRelojlocal)
+			if se.causality != nil {
+				se.reportViolations(se.causality.Event(event.ProcessId, event.Event))
+			}
 			se.IlEventos.inserta(event.Event)
 			if se.lookAheads[event.ProcessId] < event.Event.IiTiempo {
 				se.lookAheads[event.ProcessId] = event.Event.IiTiempo
 			}
 
-			if se.isWaitingEvent {
-				se.isWaitingEvent = false
-				se.waitForEvent <- true
+			se.wakeUp()
+			se.mux.Unlock()
+			// El emisor espera a que el evento esté insertado antes de tratar otros
+			// mensajes, para que un LookAhead posterior no adelante el reloj al evento
+			select {
+			case se.incomEventsAckCh <- true:
+			case <-se.done:
+				return
 			}
+			se.activity.Add(-1)
+
+		case req := <-se.snapshotCh:
+			se.mux.Lock()
+			req.recorded(se.checkpoint())
 			se.mux.Unlock()
+			req.done <- true
+			se.activity.Add(-1)
+
+		case <-se.done:
+			return
 		}
 	}
 }
 
 // Rutina encargada de calcular el valor del LookAhead cuando lo requiere otro proceso
 func (se *SimulationEngine) CalculateLookAhead() {
+	defer se.routines.Done()
 	for {
 		select {
 		case lar := <-se.receiveLookAheadReqCh:
@@ -45,50 +70,206 @@ func (se *SimulationEngine) CalculateLookAhead() {
 			la := LookAhead{Process: lar.Process}
 			// si el proceso que solicita también puede enviar eventos, actualiza el look
 			if current, ok := se.lookAheads[lar.Process]; ok && lar.Time > current {
-				se.Log.Clock.Println("Actualiza LookAhead de proceso", lar.Process)
+				se.Log.Debug("Actualiza LookAhead de proceso", "process", lar.Process)
 				se.lookAheads[lar.Process] = lar.Time
 			}
 
-			// si no hay transiciones sensibilizadas ni eventos en cola, da el tiempo futuro máximo
-			if se.ilMislefs.IsTransSensib.isEmpty() && se.IlEventos.ListaEventosVacia() {
-				se.updateTimeWithLA() // actualiza el reloj local con el menor lookAhead
-				// El máximo es el LookAhead mínimo más el tiempo que le tome a un token atravesar la red
-				la.Time = se.iiRelojlocal + se.maxLookAhead
-			} else {
-				la.Time = se.iiRelojlocal + 1 // asume que el tiempo mínimo en que puede generar un evento externo es 1
-			}
+			la.Time = se.lookAheadFor(lar.Process)
 			se.mux.Unlock()
 
-			se.sendLookAheadCh <- la
+			se.activity.Add(1) // el emisor envía la respuesta
+			select {
+			case se.sendLookAheadCh <- la:
+			case <-se.done:
+				return
+			}
+			se.activity.Add(-1)
+
+		case <-se.done:
+			return
 		}
 	}
 }
 
-// Devuelve la transición de salida de la subred
-func (se *SimulationEngine) findOutbound() (Transition, error) {
-	for _, t := range se.ilMislefs.IaRed {
+// OutboundLink agrupa lo que esta subred puede enviar a un proceso posterior
+type OutboundLink struct {
+	Process     int             // Proceso destino
+	LookAhead   TypeClock       // Tiempo mínimo para que un token atraviese la subred hasta el proceso
+	MinDuration TypeClock       // Menor tiempo desde la sensibilización hasta el evento de las transiciones que envían al proceso
+	Targets     []IndLocalTrans // Transiciones remotas (codificadas en negativo) del proceso destino
+}
+
+// Devuelve las transiciones de salida de la subred
+func (l Lefs) findOutbounds() TransitionList {
+	outbounds := TransitionList{}
+	for _, t := range l.IaRed {
 		if t.EsSalida {
-			return t, nil
+			outbounds = append(outbounds, t)
+		}
+	}
+	return outbounds
+}
+
+// OutboundLinks calcula el lookahead de cada proceso posterior a partir de las
+// transiciones de salida que pueden enviarle eventos. processOf devuelve el
+// proceso que contiene una transición global (-1 si no existe). El j-ésimo
+// valor de iL_tiemposhastamarca corresponde a la j-ésima constante PUL externa
+// de la transición, seguidas de las de lugares externos; si no existe se usa
+// defaultLookAhead.
+func (l Lefs) OutboundLinks(processOf func(int) int, defaultLookAhead TypeClock) map[int]OutboundLink {
+	links := make(map[int]OutboundLink)
+	for _, t := range l.findOutbounds() {
+		targets := []int{}
+		for _, trCo := range t.TransConstPul {
+			targets = append(targets, trCo[0])
+		}
+		for _, trPlCo := range t.LugaresPul {
+			targets = append(targets, trPlCo[0])
+		}
+		j := 0 // índice entre las constantes PUL externas
+		for _, target := range targets {
+			if target >= 0 { // propagación local
+				continue
+			}
+			lookAhead := defaultLookAhead
+			if j < len(t.TiempoHastaMarca.LiTiempos) {
+				lookAhead = TypeClock(t.TiempoHastaMarca.LiTiempos[j])
+			}
+			j++
+
+			process := processOf(-1 * (target + 1))
+			if process < 0 {
+				continue
+			}
+			link, ok := links[process]
+			if !ok {
+				link = OutboundLink{Process: process, LookAhead: lookAhead, MinDuration: t.MinDuration()}
+			}
+			if lookAhead < link.LookAhead {
+				link.LookAhead = lookAhead
+			}
+			if t.MinDuration() < link.MinDuration {
+				link.MinDuration = t.MinDuration()
+			}
+			link.Targets = append(link.Targets, IndLocalTrans(target))
+			links[process] = link
+		}
+	}
+	return links
+}
+
+// Calcula el LookAhead que se puede prometer al proceso indicado.
+// Se llama con el mutex bloqueado.
+func (se *SimulationEngine) lookAheadFor(process int) TypeClock {
+	link, ok := se.outLinks[process]
+
+	// si no hay transiciones sensibilizadas ni eventos en cola, da el tiempo futuro máximo.
+	// Las sensibilizadas en el reloj actual aún no están en la pila entre dos pasos
+	if se.ilMislefs.IsTransSensib.isEmpty() && !se.ilMislefs.haySensibilizadasEn(se.iiRelojlocal) &&
+		se.IlEventos.ListaEventosVacia() {
+		se.updateTimeWithLA() // actualiza el reloj local con el menor lookAhead
+		// El máximo es el LookAhead mínimo más el tiempo que le tome a un token atravesar la red
+		if !ok {
+			return se.iiRelojlocal + se.maxLookAhead
+		}
+		return se.iiRelojlocal + link.LookAhead
+	}
+
+	if !ok {
+		return se.iiRelojlocal + 1 // asume que el tiempo mínimo en que puede generar un evento externo es 1
+	}
+	// Ningún disparo puede enviar al proceso antes de la menor duración de sus transiciones
+	lookAhead := se.iiRelojlocal + link.MinDuration
+	// ni después del disparo ya planificado de una transición con intervalo
+	lookAhead = se.ilMislefs.minPlanificado(process, se.outProcess, lookAhead)
+	// ni después de un evento ya generado hacia ese proceso
+	for _, e := range se.IlEventos {
+		if e.IiTiempo >= lookAhead {
+			break
+		}
+		if p, ok := se.outProcess[e.IiTransicion]; ok && p == process {
+			lookAhead = e.IiTiempo
 		}
 	}
-	return Transition{}, errors.New("Transición de salida no encontrada")
+	return lookAhead
+}
+
+// Procesos precedentes en orden, para recorrer los LookAheads siempre igual y
+// que la ejecución no dependa del orden aleatorio de los mapas
+func (se *SimulationEngine) ancestors() []int {
+	ancestors := make([]int, 0, len(se.lookAheads))
+	for i := range se.lookAheads {
+		ancestors = append(ancestors, i)
+	}
+	sort.Ints(ancestors)
+	return ancestors
 }
 
 func (se *SimulationEngine) updateTimeWithLA() {
-	minLookAhead := TypeClock(100) // se inicializa en 100 para reducirlo
-	for i, l := range se.lookAheads {
+	if len(se.lookAheads) == 0 { // sin procesos precedentes el tiempo solo avanza con eventos propios
+		return
+	}
+	minLookAhead := TypeClock(math.MaxInt64) // se inicializa al máximo para reducirlo
+	for _, i := range se.ancestors() {
+		l := se.lookAheads[i]
 		if l < minLookAhead {
 			if l <= se.iiRelojlocal { // Si LookAhead no permite avanzar, se pide nuevo para ampliar más el tiempo
-				se.Log.Clock.Println("LookAhead actual", l)
-				go se.getLookAhead(i) // cuando se hace esto, los lookAhead se acumulan, generando problemas de sincronización
+				se.Log.Debug("LookAhead no permite avanzar", "process", i, "lookahead", l)
+				se.activity.Add(1)
+				go func(i int) { // cuando se hace esto, los lookAhead se acumulan, generando problemas de sincronización
+					se.getLookAhead(i)
+					se.activity.Add(-1)
+				}(i)
 			}
 			minLookAhead = l
 		}
 	}
 	if minLookAhead > se.iiRelojlocal {
-		se.iiRelojlocal = minLookAhead
-		se.Log.Clock.Println("Avanza el tiempo -> ", se.iiRelojlocal)
+		se.avanzarReloj(minLookAhead, true)
+	}
+}
+
+// Despierta al bucle de simulación si espera un evento. Se llama con el mutex
+// bloqueado.
+func (se *SimulationEngine) wakeUp() {
+	if se.isWaitingEvent {
+		se.isWaitingEvent = false
+		se.activity.Add(1) // despierta al bucle de simulación
+		select {
+		case se.waitForEvent <- true:
+		case <-se.done:
+		}
+	}
+}
+
+// AncestorFinished registra que el proceso indicado ha terminado su simulación
+// y ya no enviará eventos. Si era el último precedente que podía enviarlos, el
+// motor deja de esperarlos.
+func (se *SimulationEngine) AncestorFinished(process int) {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	if _, ok := se.lookAheads[process]; !ok {
+		return
+	}
+	se.finished[process] = true
+	if se.nothingToWaitFor() {
+		se.wakeUp()
+	}
+}
+
+// Indica si ya no puede llegar ningún evento que tratar en el periodo, porque
+// el reloj ha llegado al final o han terminado todos los procesos precedentes.
+// Se llama con el mutex bloqueado.
+func (se *SimulationEngine) nothingToWaitFor() bool {
+	if se.iiRelojlocal >= se.finalClock {
+		return true
 	}
+	for i := range se.lookAheads {
+		if !se.finished[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func getFutureEvent(constantsList [][2]int, inputTranId int) ([2]int, error) {
@@ -101,16 +282,126 @@ func getFutureEvent(constantsList [][2]int, inputTranId int) ([2]int, error) {
 	return [2]int{}, errors.New("No se encontró la transición buscada")
 }
 
-func (se *SimulationEngine) getLookAhead(processId int) {
+// getLookAhead solicita el LookAhead del proceso indicado y espera la
+// respuesta. Devuelve false si el motor se detiene antes.
+func (se *SimulationEngine) getLookAhead(processId int) bool {
 	// solicita Look Ahead
+	start := time.Now()
+	se.mux.Lock()
+	se.lookAheadRequests[processId]++
+	// la solicitud promete no enviar eventos anteriores a su tiempo
 	la := LookAhead{Process: processId, Time: se.iiRelojlocal + 1}
-	se.reqLookAheadCh <- la
+	if link, ok := se.outLinks[processId]; ok && link.MinDuration == 0 {
+		la.Time = se.iiRelojlocal // puede enviarle eventos inmediatos de este instante
+	}
+	// o los de un disparo planificado para este instante que se completa sin duración
+	la.Time = se.ilMislefs.minPlanificado(processId, se.outProcess, la.Time)
+	se.mux.Unlock()
+	se.activity.Add(1) // el emisor envía la solicitud
+	select {
+	case se.reqLookAheadCh <- la:
+	case <-se.done:
+		return false
+	}
 	// espera Look Ahead
-	ev := <-se.receiveLookAheadCh
+	se.activity.Add(-1)
+	var ev LookAhead
+	select {
+	case ev = <-se.receiveLookAheadCh:
+	case <-se.done:
+		return false
+	}
 	// Si el evento es de un tiempo mayor se inserta, si no, se vuelve a pedir
 	se.mux.Lock()
+	se.Log.Debug("LookAhead recibido", "process", ev.Process, "lookahead", ev.Time)
+	if se.causality != nil {
+		se.causality.LookAhead(ev.Process, ev.Time)
+	}
 	if ev.Time > se.lookAheads[ev.Process] { // puede ser menor si llega después de un evento
 		se.lookAheads[ev.Process] = ev.Time
 	}
+	clock := se.iiRelojlocal
 	se.mux.Unlock()
+	se.Log.Tracer.Span(TraceEngine, "Solicita LookAhead", start, clock, "process", processId, "lookahead", ev.Time)
+	return true
+}
+
+/*
+Un evento remoto que cambia un lugar con arcos inhibidores o de reinicio puede
+impedir o cambiar los disparos del instante actual, a diferencia de los
+eventos a transiciones, que pueden llegar tarde porque solo sensibilizan,
+salvo que sensibilicen una transición que compite con otra que se puede
+interrumpir. Lo mismo ocurre con los eventos de transiciones inmediatas de
+otros procesos, que deben tratarse antes que las transiciones temporizadas
+del mismo instante. Si hay transiciones que disparar, antes se espera a que
+esos procesos precedentes no puedan enviar eventos del reloj actual y se
+tratan los que hayan llegado.
+*/
+func (se *SimulationEngine) waitForCurrentEvents() {
+	ancestors := se.ilMislefs.IaPrecedentesInmediatos
+	if len(se.ilMislefs.IaLugares) > 0 || se.ilMislefs.hayConMemoria() {
+		ancestors = se.ancestors()
+	}
+	if len(ancestors) == 0 || !se.ilMislefs.haySensibilizadasEn(se.iiRelojlocal) {
+		return
+	}
+	waited := false
+	lookAhead := func(i int) (TypeClock, bool) {
+		se.mux.Lock()
+		defer se.mux.Unlock()
+		l, ok := se.lookAheads[i]
+		return l, ok
+	}
+	for _, i := range ancestors {
+		for l, ok := lookAhead(i); ok && l <= se.iiRelojlocal; l, ok = lookAhead(i) {
+			start := time.Now()
+			se.setState(StateWaitingLookAhead, i)
+			if !se.getLookAhead(i) {
+				return
+			}
+			se.addBlockedLookAhead(i, start)
+			se.setState(StateRunning, -1)
+			waited = true
+		}
+	}
+	if waited {
+		se.mux.Lock()
+		se.tratarEventos()
+		se.mux.Unlock()
+	}
+}
+
+// CheckCausality activa la comprobación de la causalidad local: cada evento
+// entrante y cada avance del reloj se comprueba y las violaciones se registran
+// en el log y se pueden consultar con Violations
+func (se *SimulationEngine) CheckCausality() {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	se.causality = NewCausalityChecker()
+	se.causality.Reset(se.iiRelojlocal)
+}
+
+// Violations devuelve las violaciones de la causalidad local detectadas
+func (se *SimulationEngine) Violations() []Violation {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	if se.causality == nil {
+		return nil
+	}
+	return append([]Violation{}, se.causality.Violations...)
+}
+
+// Comprueba el valor actual del reloj local. Se llama con el mutex bloqueado.
+func (se *SimulationEngine) checkClock() {
+	if se.causality != nil {
+		se.reportViolations(se.causality.Clock(se.iiRelojlocal))
+	}
+}
+
+func (se *SimulationEngine) reportViolations(violations []Violation) {
+	for _, v := range violations {
+		se.Log.Error("Violación de causalidad", "kind", v.Kind, "process", v.Process,
+			"transition", v.Event.IiTransicion, "time", v.Event.IiTiempo, "clock", v.Clock,
+			"previous", v.Previous, "lookahead", v.Promised)
+	}
 }
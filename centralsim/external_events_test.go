@@ -0,0 +1,38 @@
+package centralsim
+
+import "testing"
+
+func TestOutboundLinks(t *testing.T) {
+	// Subred con dos salidas: T0 envía a T1 (PL1) y T4 (PL2); T3 envía a T5 (PL2)
+	lefs := Lefs{IaRed: TransitionList{
+		{IiIndLocal: 0, IiDuracionDisparo: 2, EsSalida: true,
+			TransConstPul:    [][2]int{{-2, -1}, {9, -1}, {-5, -1}},
+			TiempoHastaMarca: TiempoHasta{LiTiempos: []int{3, 4}}},
+		{IiIndLocal: 3, IiDuracionDisparo: 1, EsSalida: true,
+			TransConstPul: [][2]int{{-6, -1}}},
+		{IiIndLocal: 9, IiDuracionDisparo: 1,
+			TransConstPul: [][2]int{{0, -1}}},
+	}}
+	processOf := func(globalId int) int {
+		switch globalId {
+		case 1:
+			return 1
+		case 4, 5:
+			return 2
+		}
+		return -1
+	}
+
+	links := lefs.OutboundLinks(processOf, 3)
+	if len(links) != 2 {
+		t.Fatalf("expected 2 links, got %v", links)
+	}
+	if l := links[1]; l.LookAhead != 3 || l.MinDuration != 2 || len(l.Targets) != 1 {
+		t.Errorf("unexpected link to P1: %+v", l)
+	}
+	// T3 no tiene tiempos hasta marca, así que aporta el valor por defecto,
+	// menor que el 4 de T0
+	if l := links[2]; l.LookAhead != 3 || l.MinDuration != 1 || len(l.Targets) != 2 {
+		t.Errorf("unexpected link to P2: %+v", l)
+	}
+}
@@ -2,4 +2,7 @@ module centralsim
 
 go 1.15
 
-require github.com/DistributedClocks/GoVector v0.0.0-20210402100930-db949c81a0af
+require (
+	github.com/DistributedClocks/GoVector v0.0.0-20210402100930-db949c81a0af
+	github.com/vmihailenco/msgpack/v5 v5.1.4
+)
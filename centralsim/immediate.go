@@ -0,0 +1,120 @@
+package centralsim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Pasos con disparos en un mismo instante a partir de los que se considera
+// que las transiciones inmediatas no van a dejar avanzar el tiempo
+const maxVanishingSteps = 10000
+
+/*
+nivel ordena el disparo de las transiciones sensibilizadas en un mismo
+instante: las inmediatas, de duración 0 y sin intervalo, disparan antes que
+las temporizadas (nivel 0) y entre ellas según su prioridad. Mientras haya
+inmediatas sensibilizadas el marcado es evanescente y el reloj no avanza.
+*/
+func (t Transition) nivel() int {
+	if t.IiDuracionDisparo > 0 || len(t.IiIntervalo) > 0 {
+		return 0
+	}
+	return t.IiPrioridad + 1
+}
+
+// nivelMaximo devuelve el mayor nivel de las transiciones de la pila
+func (l Lefs) nivelMaximo() int {
+	nivel := -1
+	for _, t := range l.IsTransSensib {
+		if n := l.IaRed[t].nivel(); n > nivel {
+			nivel = n
+		}
+	}
+	return nivel
+}
+
+// hayInmediatasEn indica si alguna transición inmediata sigue sensibilizada
+// en el instante dado, p.ej. porque tenía marcas para varios disparos
+func (l Lefs) hayInmediatasEn(aiRelojLocal TypeClock) bool {
+	for _, t := range l.IaRed {
+		if t.nivel() > 0 && l.disparable(t, aiRelojLocal) {
+			return true
+		}
+	}
+	return false
+}
+
+// Estados ya vistos en los pasos con disparos del instante actual
+type vanishingDetector struct {
+	clock  TypeClock
+	steps  int
+	states map[string]int // estado y número de disparos cuando se vio
+}
+
+/*
+checkVanishing detecta secuencias sin fin de transiciones inmediatas: si tras
+un paso con disparos el reloj sigue en el mismo instante y el estado que
+determina los disparos de ese instante se repite, el motor, determinista, lo
+repetirá indefinidamente. Las secuencias que no repiten estado porque crean
+marcas se detectan al superar maxVanishingSteps. Se llama con el mutex bloqueado.
+*/
+func (se *SimulationEngine) checkVanishing(stepClock TypeClock, fired int) {
+	if se.iiRelojlocal != stepClock || se.vanishing.clock != stepClock || se.vanishing.states == nil {
+		se.vanishing = vanishingDetector{clock: se.iiRelojlocal, states: map[string]int{}}
+		return
+	}
+	if fired == 0 {
+		return
+	}
+	se.vanishing.steps++
+	state := se.vanishingState()
+	since, seen := se.vanishing.states[state]
+	if !seen && se.vanishing.steps < maxVanishingSteps {
+		se.vanishing.states[state] = len(se.ivTransResults)
+		return
+	}
+	if !seen {
+		since = len(se.ivTransResults) - fired
+	}
+	ids := map[IndLocalTrans]bool{}
+	for _, r := range se.ivTransResults[since:] {
+		ids[r.CodTransition] = true
+	}
+	loop := make([]int, 0, len(ids))
+	for id := range ids {
+		loop = append(loop, int(id))
+	}
+	sort.Ints(loop)
+	se.err = fmt.Errorf("vanishing loop at %v: transitions %v fire without end", stepClock, loop)
+	se.Log.Error("Bucle de transiciones inmediatas", "clock", stepClock, "transitions", loop)
+}
+
+// Estado que determina los disparos del instante actual: función de
+// sensibilización de cada transición, disparos planificados, marcas de los
+// lugares y eventos pendientes
+func (se *SimulationEngine) vanishingState() string {
+	var b strings.Builder
+	for _, t := range se.ilMislefs.IaRed {
+		fmt.Fprintf(&b, "%v/%v/%v,", t.IiValorLef, t.IiTiempo == se.iiRelojlocal, t.planificada && t.iiDisparo == se.iiRelojlocal)
+	}
+	for _, p := range se.ilMislefs.IaLugares {
+		fmt.Fprintf(&b, "%v%v,", p.IiMarcas, p.IaFichas)
+	}
+	events := []string{}
+	for _, e := range se.IlEventos {
+		if e.IiTiempo == se.iiRelojlocal {
+			events = append(events, fmt.Sprintf("%v/%v/%v", e.IiTransicion, e.IiCte, e.IiLugar))
+		}
+	}
+	sort.Strings(events)
+	b.WriteString(strings.Join(events, ","))
+	return b.String()
+}
+
+// Err devuelve el error que detuvo la simulación, nil si no se detuvo
+func (se *SimulationEngine) Err() error {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	return se.err
+}
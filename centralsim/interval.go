@@ -0,0 +1,145 @@
+package centralsim
+
+import "fmt"
+
+// Políticas de elección del instante de disparo dentro del intervalo
+const (
+	IntervalEarliest = "earliest" // al principio del intervalo
+	IntervalLatest   = "latest"   // al final del intervalo
+	IntervalUniform  = "uniform"  // uniforme entre los extremos, según la semilla
+)
+
+// ValidIntervalPolicy indica si la política de elección es conocida; vacía
+// equivale a IntervalEarliest
+func ValidIntervalPolicy(policy string) bool {
+	switch policy {
+	case "", IntervalEarliest, IntervalLatest, IntervalUniform:
+		return true
+	}
+	return false
+}
+
+// SetIntervalPolicy cambia la política de elección del instante de disparo de
+// las transiciones con intervalo y la semilla de la elección uniforme
+func (se *SimulationEngine) SetIntervalPolicy(policy string, seed int64) error {
+	if !ValidIntervalPolicy(policy) {
+		return fmt.Errorf("unknown interval policy %q", policy)
+	}
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	se.ilMislefs.PoliticaIntervalos = policy
+	se.ilMislefs.SemillaIntervalos = seed
+	return nil
+}
+
+/*
+retardo elige el tiempo desde la sensibilización hasta el disparo dentro del
+intervalo [a, b] de la transición. La elección uniforme depende solo de la
+semilla, de la transición y de cuántas veces se ha sensibilizado, de modo que
+es la misma sea cual sea el proceso que simula la transición.
+*/
+func (l Lefs) retardo(t Transition) TypeClock {
+	a, b := t.IiIntervalo[0], t.IiIntervalo[1]
+	switch l.PoliticaIntervalos {
+	case IntervalLatest:
+		return b
+	case IntervalUniform:
+		h := mix(uint64(l.SemillaIntervalos) ^ mix(uint64(t.IiIndLocal)+1) ^ mix(uint64(t.sensibilizaciones)<<32))
+		return a + TypeClock(h%uint64(b-a+1))
+	}
+	return a
+}
+
+// Función de mezcla de splitmix64
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+/*
+planificaDisparos aplica la semántica de las redes de Petri temporales a las
+transiciones con intervalo, y la de carrera a las que tienen política de
+memoria: al sensibilizarse en aiRelojLocal se planifica su disparo y se añade
+un evento sin constante para despertar al motor en ese instante; si dejan de
+estar sensibilizadas se olvida la planificación y la siguiente
+sensibilización vuelve a empezar el intervalo, o continúa con memoria de
+edad. El disparo es obligatorio en el instante planificado si sigue
+sensibilizada. Se llama con el mutex bloqueado.
+*/
+func (se *SimulationEngine) planificaDisparos(aiRelojLocal TypeClock) {
+	for i := range se.ilMislefs.IaRed {
+		t := &se.ilMislefs.IaRed[i]
+		if !t.planificable() {
+			continue
+		}
+		enabled := t.IiValorLef <= 0 && !se.ilMislefs.inhibida(*t)
+		switch {
+		case !enabled:
+			t.interrumpe(aiRelojLocal)
+		case !t.planificada:
+			t.planificada = true
+			t.iiInicio = aiRelojLocal
+			if t.conMemoria() {
+				t.iiDisparo = aiRelojLocal + t.retardoMemoria()
+			} else {
+				t.iiDisparo = aiRelojLocal + se.ilMislefs.retardo(*t)
+				t.sensibilizaciones++
+			}
+			if t.iiDisparo > aiRelojLocal {
+				se.IlEventos.inserta(Event{IiTiempo: t.iiDisparo, IiTransicion: t.IiIndLocal})
+			}
+		}
+	}
+}
+
+// hayPlanificadasEn indica si alguna transición planificada debe disparar
+// en el instante dado
+func (l Lefs) hayPlanificadasEn(aiRelojLocal TypeClock) bool {
+	for _, t := range l.IaRed {
+		if t.planificable() && l.disparable(t, aiRelojLocal) {
+			return true
+		}
+	}
+	return false
+}
+
+// MinDuration es el menor tiempo desde que la transición se sensibiliza hasta
+// que genera sus eventos: la duración del disparo más el inicio del
+// intervalo, que es la cota que se usa para el LookAhead
+func (t Transition) MinDuration() TypeClock {
+	switch {
+	case len(t.IiIntervalo) > 0:
+		return t.IiIntervalo[0] + t.IiDuracionDisparo
+	case t.IiMemoria == MemoriaEdad && t.IiDuracionDisparo > 0:
+		return 1 // puede haber completado casi todo el disparo antes de interrumpirse
+	}
+	return t.IiDuracionDisparo
+}
+
+// minPlanificado limita el LookAhead prometido al proceso indicado con los
+// eventos que le enviará el disparo ya planificado de las transiciones con
+// intervalo o memoria, que puede estar antes que MinDuration desde el reloj
+// actual. Se llama con el mutex bloqueado.
+func (l Lefs) minPlanificado(process int, outProcess map[IndLocalTrans]int, lookAhead TypeClock) TypeClock {
+	for _, t := range l.IaRed {
+		if !t.planificada || !t.EsSalida || t.iiDisparo+t.duracionEventos() >= lookAhead {
+			continue
+		}
+		targets := []int{}
+		for _, trCo := range t.TransConstPul {
+			targets = append(targets, trCo[0])
+		}
+		for _, trPlCo := range t.LugaresPul {
+			targets = append(targets, trPlCo[0])
+		}
+		for _, target := range targets {
+			if p, ok := outProcess[IndLocalTrans(target)]; ok && p == process {
+				lookAhead = t.iiDisparo + t.duracionEventos()
+				break
+			}
+		}
+	}
+	return lookAhead
+}
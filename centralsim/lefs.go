@@ -20,6 +20,17 @@ type Lefs struct {
 	// Identificadores de las transiciones sensibilizadas para
 	// T = Reloj local actual. Slice que funciona como Stack
 	IsTransSensib TransitionStack
+	// Lugares con arcos inhibidores o de reinicio, cuyo marcado se mantiene
+	IaLugares []Lugar `json:"ia_lugares,omitempty"`
+	// Procesos precedentes con transiciones inmediatas que envían eventos a
+	// la subred, de modo que pueden llegar eventos del mismo instante
+	IaPrecedentesInmediatos []int `json:"ia_precedentes_inmediatos,omitempty"`
+	// Elección del instante de disparo de las transiciones con intervalo:
+	// earliest (por defecto), latest o uniform, con la semilla dada
+	PoliticaIntervalos string `json:"ia_politica_intervalos,omitempty"`
+	SemillaIntervalos  int64  `json:"ia_semilla_intervalos,omitempty"`
+	// Regla de las transiciones coloreadas; no se guarda con la Lefs
+	Colores ColorRule `json:"-"`
 }
 
 // Load obtains Lefs from a json file
@@ -140,13 +151,37 @@ func (l *Lefs) getSensibilizada() IndLocalTrans {
 //  en la pila de transiciones sensibilizadas
 func (l *Lefs) actualizaSensibilizadas(aiRelojLocal TypeClock) bool {
 	for IndT, t := range (*l).IaRed {
-		if t.IiValorLef <= 0 && t.IiTiempo == aiRelojLocal {
+		if l.disparable(t, aiRelojLocal) {
 			(*l).IsTransSensib.push(IndLocalTrans(IndT))
 		}
 	}
 	return true
 }
 
+// haySensibilizadasEn indica si alguna transicion esta sensibilizada para
+// el tiempo dado, aunque aun no se haya insertado en la pila
+func (l Lefs) haySensibilizadasEn(aiRelojLocal TypeClock) bool {
+	for _, t := range l.IaRed {
+		if l.disparable(t, aiRelojLocal) {
+			return true
+		}
+	}
+	return false
+}
+
+// disparable indica si la transición puede disparar en el instante dado, con
+// algún servidor libre y, si es coloreada, alguna ligadura: las que tienen intervalo o memoria, solo en el
+// instante planificado al sensibilizarse
+func (l Lefs) disparable(t Transition, aiRelojLocal TypeClock) bool {
+	if t.IiValorLef > 0 || l.inhibida(t) || !t.servidorLibre() || !l.ligable(t) {
+		return false
+	}
+	if t.planificable() {
+		return t.planificada && t.iiDisparo == aiRelojLocal
+	}
+	return t.IiTiempo == aiRelojLocal
+}
+
 // ImprimeTransiciones para depurar errores
 func (l Lefs) ImprimeTransiciones() {
 	fmt.Println(" ")
@@ -161,15 +196,8 @@ func (l Lefs) ImprimeTransiciones() {
 // ImprimeLefs : Imprimir los atributos de la clase para depurar errores
 func (l Lefs) ImprimeLefs(log *Logger) {
 
-	log.NoFmtLog.Println("STRUCT LEFS")
-	//fmt.Println ("\tNº transiciones: ", self.ii_indice)
-	log.NoFmtLog.Println("\tNº transiciones: ", l.IaRed.length())
-
-	log.NoFmtLog.Println("------Lista transiciones---------")
+	log.Trace("Lefs", "transitions", l.IaRed.length())
 	for _, tr := range l.IaRed {
 		tr.Imprime(log)
 	}
-	log.NoFmtLog.Println("------Final lista transiciones---------")
-
-	log.NoFmtLog.Println("FINAL ESTRUCTURA LEFS")
 }
@@ -1,43 +1,255 @@
 package centralsim
 
 import (
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level es el nivel de detalle de un mensaje de log
+type Level int
+
+const (
+	LevelTrace Level = iota // cada paso del simulador: listas de eventos y transiciones
+	LevelDebug              // disparos, eventos y LookAheads
+	LevelInfo               // inicio, fin, instantáneas
+	LevelWarn               // mensajes perdidos, reenviados o descartados
+	LevelError
+	LevelOff // no se escribe nada
+)
+
+var levelNames = []string{"trace", "debug", "info", "warn", "error", "off"}
+
+func (l Level) String() string {
+	if l < LevelTrace || l > LevelOff {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel obtiene el nivel a partir de su nombre: trace, debug, info, warn, error u off
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelOff, fmt.Errorf("unknown log level %q", name)
+}
 
-	"github.com/DistributedClocks/GoVector/govec"
+// Formatos de los registros
+const (
+	FormatLogfmt = "logfmt" // clave=valor, una línea por registro
+	FormatJSON   = "json"   // un objeto JSON por línea
 )
 
+// LogConfig indica dónde y con qué detalle escribe cada proceso sus logs
+type LogConfig struct {
+	Dir         string      // directorio de <lp>.log y govector/<lp>; vacío para no escribir ficheros
+	Level       Level       // nivel mínimo de los mensajes que se escriben
+	Format      string      // FormatLogfmt o FormatJSON
+	Sinks       []io.Writer // destinos adicionales, p.ej. os.Stderr
+	VectorClock string      // VClockOff, VClockBuiltin o VClockGoVector
+	Trace       TraceConfig // traza de la actividad para un visor de líneas de tiempo
+}
+
+// DefaultLogConfig escribe en ./logs todo salvo la traza de cada paso, con
+// los logs de GoVector para ShiViz
+func DefaultLogConfig() LogConfig {
+	return LogConfig{Dir: "./logs", Level: LevelDebug, Format: FormatLogfmt, VectorClock: VClockGoVector}
+}
+
 /*
-Estructura usada para escribir en archivos de log locales
+Logger escribe registros estructurados con nivel y campos clave/valor.
+Cada registro incluye el proceso lógico (lp) y los campos añadidos con With.
 */
 type Logger struct {
-	NoFmtLog  *log.Logger
-	Tansition *log.Logger
-	Event     *log.Logger
-	Mark      *log.Logger
-	Clock     *log.Logger
-	GoVec     *govec.GoLog
+	lp     string
+	level  Level
+	format string
+	out    io.Writer
+	mux    *sync.Mutex // compartido por los loggers derivados con With
+	fields []interface{}
+	Clock  VectorClock // instrumentación de eventos y mensajes
+	Tracer *Tracer     // traza de la actividad, nil si está desactivada
+}
+
+// NewLogger crea el logger del proceso lp según config, creando el
+// directorio de logs si no existe
+func NewLogger(lp string, config LogConfig) (*Logger, error) {
+	sinks := append([]io.Writer{}, config.Sinks...)
+	govecFile := ""
+
+	if config.Dir != "" {
+		dir := config.Dir
+		if config.VectorClock == VClockGoVector {
+			dir = filepath.Join(dir, "govector")
+			govecFile = filepath.Join(dir, lp)
+		}
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return nil, fmt.Errorf("create log directory: %w", err)
+		}
+		if config.Level < LevelOff {
+			file, err := os.OpenFile(filepath.Join(config.Dir, lp+".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+			if err != nil {
+				return nil, fmt.Errorf("open log file: %w", err)
+			}
+			sinks = append(sinks, file)
+		}
+	}
+
+	format := config.Format
+	if format == "" {
+		format = FormatLogfmt
+	}
+	if format != FormatLogfmt && format != FormatJSON {
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	clock, err := NewVectorClock(config.VectorClock, lp, govecFile)
+	if err != nil {
+		return nil, err
+	}
+	tracer, err := NewTracer(lp, config.Trace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Logger{
+		lp:     lp,
+		level:  config.Level,
+		format: format,
+		out:    io.MultiWriter(sinks...),
+		mux:    &sync.Mutex{},
+		Clock:  clock,
+		Tracer: tracer,
+	}, nil
 }
 
+// CreateLogger crea el logger del proceso con la configuración por defecto.
+// Si no se pueden crear los ficheros escribe en la salida de error.
 func CreateLogger(processId string) *Logger {
-	file, err := os.OpenFile("./logs/"+processId+".log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	logger, err := NewLogger(processId, DefaultLogConfig())
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, "log:", err)
+		logger, _ = NewLogger(processId, LogConfig{Level: LevelInfo, Sinks: []io.Writer{os.Stderr}})
+	}
+	return logger
+}
+
+// NopLogger descarta todos los mensajes y no mantiene reloj vectorial
+func NopLogger(lp string) *Logger {
+	return &Logger{lp: lp, level: LevelOff, format: FormatLogfmt, out: ioutil.Discard, mux: &sync.Mutex{}, Clock: offClock{lp}}
+}
+
+// Close termina la traza, si la hay, enviando los eventos pendientes
+func (log *Logger) Close() error {
+	return log.Tracer.Close()
+}
+
+// With devuelve un logger que añade los campos indicados a cada registro
+func (log *Logger) With(keyvals ...interface{}) *Logger {
+	derived := *log
+	derived.fields = append(append([]interface{}{}, log.fields...), keyvals...)
+	return &derived
+}
+
+// Enabled indica si se escriben los mensajes del nivel indicado, para evitar
+// preparar mensajes costosos que se van a descartar
+func (log *Logger) Enabled(level Level) bool {
+	return level >= log.level && log.level < LevelOff
+}
+
+func (log *Logger) Trace(msg string, keyvals ...interface{}) { log.write(LevelTrace, msg, keyvals) }
+func (log *Logger) Debug(msg string, keyvals ...interface{}) { log.write(LevelDebug, msg, keyvals) }
+func (log *Logger) Info(msg string, keyvals ...interface{})  { log.write(LevelInfo, msg, keyvals) }
+func (log *Logger) Warn(msg string, keyvals ...interface{})  { log.write(LevelWarn, msg, keyvals) }
+func (log *Logger) Error(msg string, keyvals ...interface{}) { log.write(LevelError, msg, keyvals) }
+
+// Escribe un registro con la hora, el nivel, el proceso, el mensaje y los campos
+func (log *Logger) write(level Level, msg string, keyvals []interface{}) {
+	if !log.Enabled(level) {
+		return
+	}
+	record := []interface{}{
+		"ts", time.Now().Format("2006-01-02T15:04:05.000000Z07:00"),
+		"level", level.String(),
+		"lp", log.lp,
+		"msg", msg,
+	}
+	record = append(append(record, log.fields...), keyvals...)
+	if len(record)%2 != 0 {
+		record = append(record, nil)
 	}
 
-	logger := log.New(file, processId+"\t", log.Ltime)
-	transition := log.New(file, processId+"\tTRANSITION:\t\t", log.Ltime)
-	event := log.New(file, processId+"\tEVENT: \t\t", log.Ltime)
-	mark := log.New(file, processId+"\tLOOKAHEAD: \t\t", log.Ltime)
-	clock := log.New(file, processId+"\tSIM CLOCK: \t\t", log.Lmicroseconds)
+	var line []byte
+	if log.format == FormatJSON {
+		line = encodeJSON(record)
+	} else {
+		line = encodeLogfmt(record)
+	}
+	log.mux.Lock()
+	defer log.mux.Unlock()
+	log.out.Write(line)
+}
 
-	defaultConfig := govec.GetDefaultConfig()
-	defaultConfig.UseTimestamps = true
-	goVector := govec.InitGoVector(processId, "./logs/govector/"+processId, defaultConfig)
+func encodeLogfmt(record []interface{}) []byte {
+	var b strings.Builder
+	for i := 0; i < len(record); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(fmt.Sprint(record[i]))
+		b.WriteByte('=')
+		value := fmt.Sprint(record[i+1])
+		if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(value)
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
 
-	return &Logger{Event: event, NoFmtLog: logger, Tansition: transition, Mark: mark, GoVec: goVector, Clock: clock}
+func encodeJSON(record []interface{}) []byte {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(record); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(record[i]))
+		b.Write(key)
+		b.WriteByte(':')
+		value := record[i+1]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			encoded, _ = json.Marshal(fmt.Sprint(value))
+		}
+		b.Write(encoded)
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
 }
 
-func (log *Logger) GoVectLog(message string) {
-	log.GoVec.LogLocalEvent(message, govec.GetDefaultLogOptions())
+// GoVectLog registra un evento local en el reloj vectorial. El mensaje solo
+// se formatea si el reloj lo usa.
+func (log *Logger) GoVectLog(format string, args ...interface{}) {
+	if log.Clock == nil {
+		return
+	}
+	if !log.Clock.Records() {
+		log.Clock.LogLocalEvent(format)
+		return
+	}
+	log.Clock.LogLocalEvent(fmt.Sprintf(format, args...))
 }
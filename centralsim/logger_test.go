@@ -0,0 +1,80 @@
+package centralsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoggerLevelsAndLogfmt(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := NewLogger("1", LogConfig{Level: LevelDebug, Sinks: []io.Writer{&out}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Trace("Reloj local", "clock", 3)
+	logger.With("clock", TypeClock(4)).Debug("Dispara transición", "transition", IndLocalTrans(2))
+	logger.Warn("Mensaje descartado", "err", "server read error: EOF")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records above the trace level, got %q", out.String())
+	}
+	for _, want := range []string{`level=debug`, `lp=1`, `msg="Dispara transición"`, `clock=4`, `transition=2`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("%q does not contain %v", lines[0], want)
+		}
+	}
+	if !strings.Contains(lines[1], `err="server read error: EOF"`) {
+		t.Errorf("value with spaces not quoted: %q", lines[1])
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := NewLogger("0", LogConfig{Level: LevelTrace, Format: FormatJSON, Sinks: []io.Writer{&out}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("Estado restaurado", "clock", TypeClock(7), "stack", []IndLocalTrans{1, 2})
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if record["level"] != "info" || record["lp"] != "0" || record["clock"] != 7.0 || len(record["stack"].([]interface{})) != 2 {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestLoggerCreatesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing", "logs")
+	logger, err := NewLogger("2", LogConfig{Dir: dir, Level: LevelInfo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("Motor de simulación creado")
+	data, err := ioutil.ReadFile(filepath.Join(dir, "2.log"))
+	if err != nil || !strings.Contains(string(data), "Motor de simulación creado") {
+		t.Errorf("log file not written: %q, %v", data, err)
+	}
+}
+
+func TestLoggerOff(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := NewLogger("0", LogConfig{Level: LevelOff, Sinks: []io.Writer{&out}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Error("Fallo")
+	if out.Len() != 0 || logger.Enabled(LevelError) {
+		t.Errorf("level off wrote %q", out.String())
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
@@ -0,0 +1,295 @@
+package centralsim
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+PlaceModel relaciona las transiciones de la Lefs con los lugares de la red
+que la originó: las marcas que consume cada transición al disparar (Pre) y
+las que produce al terminar el disparo (Post), indexadas por lugar. Las marcas
+que retiran los arcos de reinicio dependen del marcado y las indica el motor.
+*/
+type PlaceModel struct {
+	Places  []string
+	Initial []int
+	Pre     map[IndLocalTrans][]int
+	Post    map[IndLocalTrans][]int
+}
+
+// MarkingChange es el cambio de las marcas de un lugar en un instante
+type MarkingChange struct {
+	Time  TypeClock `json:"t"`
+	Place int       `json:"p"`
+	Delta int       `json:"d"`
+}
+
+/*
+MarkingLog es la evolución del marcado entre Start y End. En una simulación
+distribuida cada proceso solo registra los cambios de sus disparos; el
+marcado de la red completa se obtiene uniendo los registros de todos.
+*/
+type MarkingLog struct {
+	Places  []string        `json:"places"`
+	Initial []int           `json:"initial"`
+	Start   TypeClock       `json:"start"`
+	End     TypeClock       `json:"end"`
+	Changes []MarkingChange `json:"changes"`
+}
+
+// Registra el marcado a partir de los disparos del motor
+type markingRecorder struct {
+	model   PlaceModel
+	start   TypeClock
+	marking []int
+	changes []MarkingChange
+	pending []MarkingChange // marcas producidas por disparos que aún no han terminado
+}
+
+// ObservePlaces activa el registro del marcado de los lugares de model a
+// partir del reloj actual
+func (se *SimulationEngine) ObservePlaces(model PlaceModel) {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	se.places = &markingRecorder{model: model, start: se.iiRelojlocal, marking: append([]int{}, model.Initial...)}
+}
+
+// Marking devuelve el marcado actual de los lugares observados, nil si no se observan
+func (se *SimulationEngine) Marking() []int {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	if se.places == nil {
+		return nil
+	}
+	return append([]int{}, se.places.marking...)
+}
+
+// LocalPlaces indica para cada lugar observado si solo lo marcan y desmarcan
+// transiciones de este motor, de modo que Marking da su marcado real y no
+// solo el debido a los disparos locales. Devuelve nil si no se observan.
+func (se *SimulationEngine) LocalPlaces() []bool {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	if se.places == nil {
+		return nil
+	}
+	own := map[IndLocalTrans]bool{}
+	for _, tr := range se.ilMislefs.IaRed {
+		own[tr.IiIndLocal] = true
+	}
+	local := make([]bool, len(se.places.model.Places))
+	for p := range local {
+		local[p] = true
+	}
+	for _, arcs := range []map[IndLocalTrans][]int{se.places.model.Pre, se.places.model.Post} {
+		for t, weights := range arcs {
+			if own[t] {
+				continue
+			}
+			for p, w := range weights {
+				if w != 0 {
+					local[p] = false
+				}
+			}
+		}
+	}
+	return local
+}
+
+// MarkingLog devuelve la evolución del marcado hasta el reloj actual
+func (se *SimulationEngine) MarkingLog() MarkingLog {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	if se.places == nil {
+		return MarkingLog{}
+	}
+	r := se.places
+	return MarkingLog{Places: r.model.Places, Initial: r.model.Initial, Start: r.start, End: se.iiRelojlocal,
+		Changes: append([]MarkingChange{}, r.changes...)}
+}
+
+// Anota el disparo de la transición en el instante clock, con las marcas
+// retiradas de los lugares que vacía; las de salida se producen al terminar
+func (r *markingRecorder) fire(t IndLocalTrans, clock, duration TypeClock, reset map[int]TypeConst) {
+	for p, w := range r.model.Pre[t] {
+		if w != 0 {
+			r.apply(MarkingChange{Time: clock, Place: p, Delta: -w})
+		}
+	}
+	for _, p := range sortedPlaces(reset) {
+		if reset[p] != 0 {
+			r.apply(MarkingChange{Time: clock, Place: p, Delta: -int(reset[p])})
+		}
+	}
+	for p, w := range r.model.Post[t] {
+		if w != 0 {
+			r.pending = append(r.pending, MarkingChange{Time: clock + duration, Place: p, Delta: w})
+		}
+	}
+}
+
+// Aplica las marcas producidas hasta el instante clock
+func (r *markingRecorder) advance(clock TypeClock) {
+	sort.SliceStable(r.pending, func(i, j int) bool { return r.pending[i].Time < r.pending[j].Time })
+	applied := 0
+	for applied < len(r.pending) && r.pending[applied].Time <= clock {
+		r.apply(r.pending[applied])
+		applied++
+	}
+	r.pending = r.pending[applied:]
+}
+
+func sortedPlaces(reset map[int]TypeConst) []int {
+	places := make([]int, 0, len(reset))
+	for p := range reset {
+		places = append(places, p)
+	}
+	sort.Ints(places)
+	return places
+}
+
+func (r *markingRecorder) apply(c MarkingChange) {
+	r.marking[c.Place] += c.Delta
+	r.changes = append(r.changes, c)
+}
+
+// MergeMarkingLogs une los registros de los procesos de una simulación. El
+// resultado cubre el intervalo que han registrado todos.
+func MergeMarkingLogs(logs []MarkingLog) MarkingLog {
+	if len(logs) == 0 {
+		return MarkingLog{}
+	}
+	merged := MarkingLog{Places: logs[0].Places, Initial: logs[0].Initial, Start: logs[0].Start, End: logs[0].End}
+	for _, l := range logs {
+		if l.Start > merged.Start {
+			merged.Start = l.Start
+		}
+		if l.End < merged.End {
+			merged.End = l.End
+		}
+		merged.Changes = append(merged.Changes, l.Changes...)
+	}
+	sort.SliceStable(merged.Changes, func(i, j int) bool { return merged.Changes[i].Time < merged.Changes[j].Time })
+	return merged
+}
+
+// PlaceStats resume el marcado de un lugar entre el inicio y el fin del registro
+type PlaceStats struct {
+	Place     string
+	Average   float64           // media del número de marcas ponderada por el tiempo
+	Min, Max  int               // marcas mínimas y máximas mantenidas durante algún tiempo
+	Final     int               // marcas al final
+	Histogram map[int]TypeClock // tiempo con cada número de marcas
+}
+
+/*
+Series recorre el marcado de la red en cada instante con cambios, empezando
+por el marcado en Start; los cambios de un mismo instante se aplican juntos.
+*/
+func (l MarkingLog) Series(visit func(time TypeClock, marking []int)) {
+	marking := append([]int{}, l.Initial...)
+	i := 0
+	for ; i < len(l.Changes) && l.Changes[i].Time <= l.Start; i++ {
+		marking[l.Changes[i].Place] += l.Changes[i].Delta
+	}
+	visit(l.Start, marking)
+	for i < len(l.Changes) && l.Changes[i].Time <= l.End {
+		time := l.Changes[i].Time
+		for ; i < len(l.Changes) && l.Changes[i].Time == time; i++ {
+			marking[l.Changes[i].Place] += l.Changes[i].Delta
+		}
+		visit(time, marking)
+	}
+}
+
+// Statistics calcula la media ponderada por el tiempo, el mínimo, el máximo y
+// el histograma de ocupación de cada lugar
+func (l MarkingLog) Statistics() []PlaceStats {
+	stats := make([]PlaceStats, len(l.Places))
+	for p, name := range l.Places {
+		stats[p] = PlaceStats{Place: name, Min: l.Initial[p], Max: l.Initial[p], Histogram: map[int]TypeClock{}}
+	}
+	var previous []int
+	last := l.Start
+	hold := func(until TypeClock) { // el marcado anterior se mantuvo desde last hasta until
+		if previous == nil || until <= last {
+			return
+		}
+		for p, tokens := range previous {
+			s := &stats[p]
+			s.Histogram[tokens] += until - last
+			s.Average += float64(tokens) * float64(until-last)
+			if tokens < s.Min {
+				s.Min = tokens
+			}
+			if tokens > s.Max {
+				s.Max = tokens
+			}
+		}
+	}
+	first := true
+	l.Series(func(time TypeClock, marking []int) {
+		hold(time)
+		if first { // el marcado inicial puede cambiar antes de mantenerse
+			for p, tokens := range marking {
+				stats[p].Min, stats[p].Max = tokens, tokens
+			}
+			first = false
+		}
+		previous, last = append([]int{}, marking...), time
+	})
+	hold(l.End)
+	for p := range stats {
+		if l.End > l.Start {
+			stats[p].Average /= float64(l.End - l.Start)
+		} else {
+			stats[p].Average = float64(previous[p])
+		}
+		stats[p].Final = previous[p]
+	}
+	return stats
+}
+
+// WriteMarkingSeries escribe la evolución del marcado en CSV: el instante y
+// las marcas de cada lugar tras los cambios de ese instante
+func WriteMarkingSeries(w io.Writer, l MarkingLog) error {
+	if _, err := fmt.Fprintf(w, "time,%v\n", strings.Join(l.Places, ",")); err != nil {
+		return err
+	}
+	var err error
+	l.Series(func(time TypeClock, marking []int) {
+		if err != nil {
+			return
+		}
+		values := make([]string, len(marking))
+		for p, tokens := range marking {
+			values[p] = fmt.Sprint(tokens)
+		}
+		_, err = fmt.Fprintf(w, "%v,%v\n", time, strings.Join(values, ","))
+	})
+	return err
+}
+
+// WritePlaceReport escribe una tabla con las estadísticas de cada lugar y su
+// histograma de ocupación como porcentaje del tiempo con cada número de marcas
+func WritePlaceReport(w io.Writer, l MarkingLog) {
+	fmt.Fprintf(w, "Marcado entre %v y %v\n", l.Start, l.End)
+	fmt.Fprintf(w, "%-12v %8v %6v %6v %6v  %v\n", "lugar", "media", "mín", "máx", "final", "ocupación")
+	total := float64(l.End - l.Start)
+	for _, s := range l.Statistics() {
+		tokens := make([]int, 0, len(s.Histogram))
+		for k := range s.Histogram {
+			tokens = append(tokens, k)
+		}
+		sort.Ints(tokens)
+		occupancy := make([]string, len(tokens))
+		for i, k := range tokens {
+			occupancy[i] = fmt.Sprintf("%v:%.1f%%", k, 100*float64(s.Histogram[k])/total)
+		}
+		fmt.Fprintf(w, "%-12v %8.3f %6v %6v %6v  %v\n", s.Place, s.Average, s.Min, s.Max, s.Final,
+			strings.Join(occupancy, " "))
+	}
+}
@@ -0,0 +1,41 @@
+package centralsim
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Dos procesos: PL0 mueve la marca de a a b en 2 y PL1 la devuelve en 6
+func TestMergedMarkingStatistics(t *testing.T) {
+	pl0 := MarkingLog{Places: []string{"a", "b"}, Initial: []int{1, 0}, Start: 0, End: 10,
+		Changes: []MarkingChange{{Time: 2, Place: 0, Delta: -1}, {Time: 3, Place: 1, Delta: 1}}}
+	pl1 := MarkingLog{Places: []string{"a", "b"}, Initial: []int{1, 0}, Start: 0, End: 8,
+		Changes: []MarkingChange{{Time: 6, Place: 1, Delta: -1}, {Time: 7, Place: 0, Delta: 1}}}
+	merged := MergeMarkingLogs([]MarkingLog{pl1, pl0})
+	if merged.End != 8 || len(merged.Changes) != 4 || merged.Changes[0].Time != 2 {
+		t.Fatalf("unexpected merged log %+v", merged)
+	}
+
+	stats := merged.Statistics()
+	a, b := stats[0], stats[1]
+	if a.Average != 3.0/8 || a.Min != 0 || a.Max != 1 || a.Final != 1 || a.Histogram[0] != 5 || a.Histogram[1] != 3 {
+		t.Errorf("unexpected statistics of a %+v", a)
+	}
+	if b.Average != 3.0/8 || b.Max != 1 || b.Final != 0 {
+		t.Errorf("unexpected statistics of b %+v", b)
+	}
+
+	var csv bytes.Buffer
+	if err := WriteMarkingSeries(&csv, merged); err != nil {
+		t.Fatal(err)
+	}
+	if csv.String() != "time,a,b\n0,1,0\n2,0,0\n3,0,1\n6,0,0\n7,1,0\n" {
+		t.Errorf("unexpected series:\n%v", csv.String())
+	}
+	var report bytes.Buffer
+	WritePlaceReport(&report, merged)
+	if !strings.Contains(report.String(), "0:62.5% 1:37.5%") {
+		t.Errorf("unexpected report:\n%v", report.String())
+	}
+}
@@ -0,0 +1,318 @@
+package petrinet
+
+import (
+	"centralsim"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Model es una red compilada a Lefs, con la correspondencia entre ambas:
+// la transición i de la red es la transición de la Lefs con identificador i
+type Model struct {
+	Net     *Net
+	Lefs    centralsim.Lefs
+	pre     [][]int     // pre[t][p] marcas que consume t de p
+	post    [][]int     // post[t][p] marcas que produce t en p
+	resets  [][]int     // lugares que vacía cada transición
+	anchors map[int]int // transición a la que se dirigen los eventos de cada lugar con marcado
+}
+
+/*
+Compile traduce la red a una Lefs. El valor de la función de sensibilización
+de cada transición es la suma de los pesos de sus arcos de entrada menos las
+marcas de sus lugares de entrada, de modo que está sensibilizada cuando el
+valor es 0 o menor. Al disparar, las marcas consumidas aumentan de inmediato
+el valor de las transiciones que comparten los lugares de entrada (IUL) y las
+producidas lo reducen al terminar el disparo (PUL).
+
+Los lugares con arcos inhibidores o de reinicio, o marcados como Tracked,
+mantienen además su marcado en la Lefs. Las transiciones que los consumen,
+los vacían o son inhibidas por ellos deben simularse en el mismo proceso; las
+marcas producidas llegan como eventos dirigidos a la primera de ellas, su
+ancla, o a la primera que produce en el lugar si ninguna lo lee.
+*/
+func Compile(net *Net) (*Model, error) {
+	if err := net.Validate(); err != nil {
+		return nil, err
+	}
+	m := &Model{Net: net}
+	m.pre, m.post = net.incidence()
+
+	m.resets = make([][]int, len(net.Transitions))
+	m.anchors = map[int]int{}
+	for i, t := range net.Transitions {
+		for _, place := range t.Resets {
+			m.resets[i] = append(m.resets[i], net.PlaceIndex(place))
+		}
+	}
+
+	lefs := centralsim.Lefs{IaRed: make(centralsim.TransitionList, len(net.Transitions)),
+		IsTransSensib: centralsim.MakeTransitionStack(), PoliticaIntervalos: net.Policy, SemillaIntervalos: net.Seed}
+	for p, place := range net.Places {
+		if !m.tracked(p) {
+			continue
+		}
+		group := m.placeGroup(p)
+		for u := range net.Transitions {
+			if len(group) == 0 && m.post[u][p] > 0 {
+				group = append(group, u)
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+		m.anchors[p] = group[0]
+		lugar := centralsim.Lugar{IiIdGlobal: p, IiMarcas: centralsim.TypeConst(place.Initial)}
+		for u := range net.Transitions {
+			if m.pre[u][p] > 0 {
+				lugar.Consumidores = append(lugar.Consumidores, centralsim.IndLocalTrans(u))
+			}
+		}
+		lefs.IaLugares = append(lefs.IaLugares, lugar)
+	}
+	for i, t := range net.Transitions {
+		value := 0
+		for p, w := range m.pre[i] {
+			if w > 0 {
+				value += w - net.Places[p].Initial
+			}
+		}
+		lefs.IaRed[i] = centralsim.Transition{
+			IiIndLocal:        centralsim.IndLocalTrans(i),
+			IiValorLef:        centralsim.TypeConst(value),
+			IiDuracionDisparo: t.Duration,
+			IiPrioridad:       t.Priority,
+			IiIntervalo:       t.Interval,
+			IiServidores:      t.Servers,
+			IiMemoria:         t.Memory,
+			TransConstIul:     m.propagation(m.pre[i], 1),
+			TransConstPul:     m.propagation(m.post[i], -1),
+			Reinicios:         m.resets[i],
+		}
+		tr := &lefs.IaRed[i]
+		for _, a := range t.Inhibitors {
+			tr.Inhibidores = append(tr.Inhibidores, [2]int{net.PlaceIndex(a.Place), a.weight()})
+		}
+		for p := range net.Places {
+			anchor, ok := m.anchors[p]
+			if !ok {
+				continue
+			}
+			if w := m.pre[i][p]; w > 0 {
+				tr.LugaresIul = append(tr.LugaresIul, [2]int{p, -w})
+			}
+			if w := m.post[i][p]; w > 0 {
+				tr.LugaresPul = append(tr.LugaresPul, [3]int{anchor, p, w})
+			}
+		}
+	}
+	m.Lefs = lefs
+	return m, nil
+}
+
+// Pesos de los arcos de entrada y de salida de cada transición por lugar
+func (n *Net) incidence() (pre, post [][]int) {
+	pre = make([][]int, len(n.Transitions))
+	post = make([][]int, len(n.Transitions))
+	for i, t := range n.Transitions {
+		pre[i] = make([]int, len(n.Places))
+		post[i] = make([]int, len(n.Places))
+		for _, a := range t.Inputs {
+			pre[i][n.PlaceIndex(a.Place)] = a.weight()
+		}
+		for _, a := range t.Outputs {
+			post[i][n.PlaceIndex(a.Place)] = a.weight()
+		}
+	}
+	return pre, post
+}
+
+// Parejas transición/constante que cambian las marcas indicadas por lugar:
+// cada transición con alguno de esos lugares de entrada suma sign*marcas
+func (m *Model) propagation(tokens []int, sign int) [][2]int {
+	pairs := [][2]int{}
+	for u := range m.Net.Transitions {
+		cte := 0
+		for p, w := range tokens {
+			if w > 0 && m.pre[u][p] > 0 {
+				cte += sign * w
+			}
+		}
+		if cte != 0 {
+			pairs = append(pairs, [2]int{u, cte})
+		}
+	}
+	return pairs
+}
+
+// Indica si algún arco inhibidor o de reinicio, o el propio lugar, obliga a
+// mantener su marcado
+func (m *Model) tracked(p int) bool {
+	if m.Net.Places[p].Tracked {
+		return true
+	}
+	name := m.Net.Places[p].Name
+	for _, t := range m.Net.Transitions {
+		for _, a := range t.Inhibitors {
+			if a.Place == name {
+				return true
+			}
+		}
+		for _, place := range t.Resets {
+			if place == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Transiciones que leen el marcado del lugar: lo consumen, lo vacían o son
+// inhibidas por él
+func (m *Model) placeGroup(p int) []int {
+	name := m.Net.Places[p].Name
+	group := []int{}
+	for u, t := range m.Net.Transitions {
+		reads := m.pre[u][p] > 0
+		for _, a := range t.Inhibitors {
+			reads = reads || a.Place == name
+		}
+		for _, q := range m.resets[u] {
+			reads = reads || q == p
+		}
+		if reads {
+			group = append(group, u)
+		}
+	}
+	return group
+}
+
+// WriteLefs escribe la Lefs compilada en el formato JSON que lee centralsim.Load
+func (m *Model) WriteLefs(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m.Lefs)
+}
+
+// TransitionName devuelve el nombre de la transición de la red que corresponde
+// a un identificador de la Lefs
+func (m *Model) TransitionName(id centralsim.IndLocalTrans) string {
+	if id < 0 || int(id) >= len(m.Net.Transitions) {
+		return fmt.Sprintf("T%v", id)
+	}
+	return m.Net.Transitions[id].Name
+}
+
+// PlaceModel devuelve la relación entre las transiciones de la Lefs y los
+// lugares, para que el motor registre el marcado
+func (m *Model) PlaceModel() centralsim.PlaceModel {
+	model := centralsim.PlaceModel{
+		Places:  make([]string, len(m.Net.Places)),
+		Initial: m.InitialMarking(),
+		Pre:     make(map[centralsim.IndLocalTrans][]int),
+		Post:    make(map[centralsim.IndLocalTrans][]int),
+	}
+	for p, place := range m.Net.Places {
+		model.Places[p] = place.Name
+	}
+	for t := range m.Net.Transitions {
+		model.Pre[centralsim.IndLocalTrans(t)] = m.pre[t]
+		model.Post[centralsim.IndLocalTrans(t)] = m.post[t]
+	}
+	return model
+}
+
+// Marking es el número de marcas de cada lugar, en el orden de Net.Places
+type Marking []int
+
+// InitialMarking devuelve el marcado inicial de la red
+func (m *Model) InitialMarking() Marking {
+	marking := make(Marking, len(m.Net.Places))
+	for i, p := range m.Net.Places {
+		marking[i] = p.Initial
+	}
+	return marking
+}
+
+/*
+MarkingAt reconstruye el marcado en el instante clock a partir de los disparos
+de la simulación: cada disparo consume las marcas de entrada en su instante,
+vacía los lugares de sus arcos de reinicio y produce las de salida al
+terminar, tras la duración de la transición; las que tienen política de
+memoria anotan el disparo al completarse y producen sus marcas en ese mismo
+instante. Las marcas producidas en un instante están disponibles para los
+disparos de ese instante. Devuelve error si un disparo no corresponde a la
+red o deja un lugar con marcas negativas.
+*/
+func (m *Model) MarkingAt(results []centralsim.ResultadoTransition, clock centralsim.TypeClock) (Marking, error) {
+	firings := make([]centralsim.ResultadoTransition, 0, len(results))
+	for _, r := range results {
+		t := int(r.CodTransition)
+		if t < 0 || t >= len(m.Net.Transitions) {
+			return nil, fmt.Errorf("fired transition %v is not in the net", r.CodTransition)
+		}
+		if r.ValorRelojDisparo <= clock {
+			firings = append(firings, r)
+		}
+	}
+	// los disparos de un mismo instante mantienen el orden en que ocurrieron
+	sort.SliceStable(firings, func(i, j int) bool { return firings[i].ValorRelojDisparo < firings[j].ValorRelojDisparo })
+
+	marking := m.InitialMarking()
+	pending := []centralsim.ResultadoTransition{} // disparos que aún no han producido sus marcas
+	produce := func(until centralsim.TypeClock) {
+		rest := pending[:0]
+		for _, r := range pending {
+			t := int(r.CodTransition)
+			if r.ValorRelojDisparo+m.Net.Transitions[t].delay() > until {
+				rest = append(rest, r)
+				continue
+			}
+			for p, w := range m.post[t] {
+				marking[p] += w
+			}
+		}
+		pending = rest
+	}
+	for _, r := range firings {
+		produce(r.ValorRelojDisparo)
+		t := int(r.CodTransition)
+		for p, w := range m.pre[t] {
+			marking[p] -= w
+		}
+		for _, p := range m.resets[t] {
+			if marking[p] > 0 {
+				marking[p] = 0
+			}
+		}
+		pending = append(pending, r)
+	}
+	produce(clock)
+	for p, tokens := range marking {
+		if tokens < 0 {
+			return nil, fmt.Errorf("place %v has %v tokens at %v", m.Net.Places[p].Name, tokens, clock)
+		}
+	}
+	return marking, nil
+}
+
+// Format describe el marcado con el nombre de cada lugar, p.ej. "p0=1 p1=0"
+func (m *Model) Format(marking Marking) string {
+	parts := make([]string, len(marking))
+	for p, tokens := range marking {
+		parts[p] = fmt.Sprintf("%v=%v", m.Net.Places[p].Name, tokens)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Firings cuenta los disparos de cada transición de la red por nombre
+func (m *Model) Firings(results []centralsim.ResultadoTransition) map[string]int {
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[m.TransitionName(r.CodTransition)]++
+	}
+	return counts
+}
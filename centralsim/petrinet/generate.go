@@ -0,0 +1,203 @@
+package petrinet
+
+import (
+	"centralsim"
+	"fmt"
+	"math/rand"
+)
+
+// Familias de redes del generador
+const (
+	FamilyPipeline = "pipeline" // una fuente y Size etapas en serie
+	FamilyRing     = "ring"     // Size etapas en anillo con Tokens marcas
+	FamilyForkJoin = "forkjoin" // Size ramas en paralelo entre una bifurcación y una unión
+	FamilyGrid     = "grid"     // malla toroidal de Size x Size lugares
+	FamilyRandom   = "random"   // máquina de estados aleatoria fuertemente conexa de Size lugares
+)
+
+/*
+Generator describe una familia de redes para medir el protocolo a escala.
+Las duraciones se eligen entre MinDuration y MaxDuration con la semilla Seed,
+como los arcos de la red aleatoria, que además del ciclo que la hace conexa
+une cada par de lugares con probabilidad Density. Processes es el número de
+procesos lógicos entre los que se reparten las transiciones, en bloques
+contiguos de la estructura.
+*/
+type Generator struct {
+	Family      string
+	Size        int
+	Tokens      int
+	MinDuration centralsim.TypeClock
+	MaxDuration centralsim.TypeClock
+	Density     float64
+	Processes   int
+	Seed        int64
+}
+
+// Generate construye la red de la familia y devuelve el proceso de cada
+// transición, para Partition
+func (g Generator) Generate() (*Net, []int, error) {
+	if g.Size < 1 {
+		return nil, nil, fmt.Errorf("size %v, must be at least 1", g.Size)
+	}
+	if g.MinDuration < 1 || g.MaxDuration < g.MinDuration {
+		return nil, nil, fmt.Errorf("invalid durations [%v, %v], must be 1 <= min <= max", g.MinDuration, g.MaxDuration)
+	}
+	if g.Density < 0 || g.Density > 1 {
+		return nil, nil, fmt.Errorf("density %v, must be between 0 and 1", g.Density)
+	}
+	tokens := g.Tokens
+	if tokens == 0 {
+		tokens = 1
+	}
+	if tokens < 0 || g.Family == FamilyForkJoin && tokens > 1 {
+		// la unión solo es lineal si sus lugares de entrada nunca tienen más de una marca
+		return nil, nil, fmt.Errorf("%v tokens in a %v net", tokens, g.Family)
+	}
+	gen := &generation{Generator: g, rnd: rand.New(rand.NewSource(g.Seed)), net: &Net{}}
+	blocks := 0 // número de bloques de la estructura que se reparten entre los procesos
+	switch g.Family {
+	case FamilyPipeline:
+		blocks = gen.pipeline()
+	case FamilyRing:
+		blocks = gen.ring(tokens)
+	case FamilyForkJoin:
+		blocks = gen.forkJoin()
+	case FamilyGrid:
+		blocks = gen.grid(tokens)
+	case FamilyRandom:
+		blocks = gen.random(tokens)
+	default:
+		return nil, nil, fmt.Errorf("unknown net family %q", g.Family)
+	}
+	if g.Processes < 1 || g.Processes > blocks {
+		return nil, nil, fmt.Errorf("%v processes for a %v net with %v blocks", g.Processes, g.Family, blocks)
+	}
+	owner := make([]int, len(gen.blocks))
+	for t, b := range gen.blocks {
+		owner[t] = b * g.Processes / blocks
+	}
+	return gen.net, owner, nil
+}
+
+// Estado de la generación: la red y el bloque de cada transición
+type generation struct {
+	Generator
+	rnd    *rand.Rand
+	net    *Net
+	blocks []int
+}
+
+func (gen *generation) place(name string, initial int) {
+	gen.net.Places = append(gen.net.Places, Place{Name: name, Initial: initial})
+}
+
+// transition añade una transición con la duración elegida al azar
+func (gen *generation) transition(name string, block int, inputs, outputs []string) {
+	t := Transition{Name: name, Duration: gen.MinDuration}
+	if gen.MaxDuration > gen.MinDuration {
+		t.Duration += centralsim.TypeClock(gen.rnd.Int63n(int64(gen.MaxDuration - gen.MinDuration + 1)))
+	}
+	for _, p := range inputs {
+		t.Inputs = append(t.Inputs, Arc{Place: p})
+	}
+	for _, p := range outputs {
+		t.Outputs = append(t.Outputs, Arc{Place: p})
+	}
+	gen.net.Transitions = append(gen.net.Transitions, t)
+	gen.blocks = append(gen.blocks, block)
+}
+
+// La fuente genera una marca cada vez que termina y la última etapa la retira
+func (gen *generation) pipeline() int {
+	gen.place("fuente", 1)
+	for i := 0; i < gen.Size; i++ {
+		gen.place(fmt.Sprintf("p%v", i), 0)
+	}
+	gen.transition("genera", 0, []string{"fuente"}, []string{"fuente", "p0"})
+	for i := 0; i < gen.Size; i++ {
+		outputs := []string{}
+		if i+1 < gen.Size {
+			outputs = append(outputs, fmt.Sprintf("p%v", i+1))
+		}
+		gen.transition(fmt.Sprintf("etapa%v", i), i, []string{fmt.Sprintf("p%v", i)}, outputs)
+	}
+	return gen.Size
+}
+
+// Las marcas empiezan repartidas por los primeros lugares
+func (gen *generation) ring(tokens int) int {
+	for i := 0; i < gen.Size; i++ {
+		gen.place(fmt.Sprintf("p%v", i), share(tokens, gen.Size, i))
+	}
+	for i := 0; i < gen.Size; i++ {
+		gen.transition(fmt.Sprintf("etapa%v", i), i, []string{fmt.Sprintf("p%v", i)}, []string{fmt.Sprintf("p%v", (i+1)%gen.Size)})
+	}
+	return gen.Size
+}
+
+// La bifurcación y la unión van con la primera rama
+func (gen *generation) forkJoin() int {
+	gen.place("inicio", 1)
+	branches, joined := []string{}, []string{}
+	for i := 0; i < gen.Size; i++ {
+		branches = append(branches, fmt.Sprintf("rama%v", i))
+		joined = append(joined, fmt.Sprintf("hecha%v", i))
+	}
+	for i := range branches {
+		gen.place(branches[i], 0)
+		gen.place(joined[i], 0)
+	}
+	gen.transition("bifurca", 0, []string{"inicio"}, branches)
+	for i := range branches {
+		gen.transition(fmt.Sprintf("procesa%v", i), i, []string{branches[i]}, []string{joined[i]})
+	}
+	gen.transition("une", 0, joined, []string{"inicio"})
+	return gen.Size
+}
+
+// Cada marca de la malla va a la derecha o abajo, en conflicto; cada fila es un bloque
+func (gen *generation) grid(tokens int) int {
+	cell := func(i, j int) string { return fmt.Sprintf("p%v_%v", i%gen.Size, j%gen.Size) }
+	for k := 0; k < gen.Size*gen.Size; k++ {
+		gen.place(cell(k/gen.Size, k%gen.Size), share(tokens, gen.Size*gen.Size, k))
+	}
+	for i := 0; i < gen.Size; i++ {
+		for j := 0; j < gen.Size; j++ {
+			gen.transition(fmt.Sprintf("derecha%v_%v", i, j), i, []string{cell(i, j)}, []string{cell(i, j+1)})
+			gen.transition(fmt.Sprintf("abajo%v_%v", i, j), i, []string{cell(i, j)}, []string{cell(i+1, j)})
+		}
+	}
+	return gen.Size
+}
+
+// Un ciclo por todos los lugares en orden aleatorio la hace fuertemente
+// conexa; cada lugar es un bloque, con las transiciones que lo consumen
+func (gen *generation) random(tokens int) int {
+	for i := 0; i < gen.Size; i++ {
+		gen.place(fmt.Sprintf("p%v", i), share(tokens, gen.Size, i))
+	}
+	order := gen.rnd.Perm(gen.Size)
+	next := make([]int, gen.Size)
+	for k, p := range order {
+		next[p] = order[(k+1)%gen.Size]
+	}
+	for i := 0; i < gen.Size; i++ {
+		for j := 0; j < gen.Size; j++ {
+			if j != next[i] && (j == i || gen.rnd.Float64() >= gen.Density) {
+				continue
+			}
+			gen.transition(fmt.Sprintf("t%v_%v", i, j), i, []string{fmt.Sprintf("p%v", i)}, []string{fmt.Sprintf("p%v", j)})
+		}
+	}
+	return gen.Size
+}
+
+// share devuelve las marcas iniciales del lugar i de n al repartir tokens
+// desde el primero
+func share(tokens, n, i int) int {
+	if i < tokens%n {
+		return tokens/n + 1
+	}
+	return tokens / n
+}
@@ -0,0 +1,100 @@
+package petrinet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFamilies(t *testing.T) {
+	for _, g := range []Generator{
+		{Family: FamilyPipeline, Size: 5, Processes: 2},
+		{Family: FamilyRing, Size: 6, Tokens: 4, Processes: 3},
+		{Family: FamilyForkJoin, Size: 4, Processes: 4},
+		{Family: FamilyGrid, Size: 3, Tokens: 5, Processes: 3},
+		{Family: FamilyRandom, Size: 8, Tokens: 3, Density: 0.3, Processes: 4},
+	} {
+		g.MinDuration, g.MaxDuration, g.Seed = 1, 4, 7
+		net, owner, err := g.Generate()
+		if err != nil {
+			t.Fatalf("%v: %v", g.Family, err)
+		}
+		model, err := Compile(net)
+		if err != nil {
+			t.Fatalf("%v: %v", g.Family, err)
+		}
+		subnets, err := model.Partition(owner)
+		if err != nil {
+			t.Fatalf("%v: %v", g.Family, err)
+		}
+		if len(subnets) != g.Processes {
+			t.Errorf("%v: %v subnets, expected %v", g.Family, len(subnets), g.Processes)
+		}
+		for p, lefs := range subnets {
+			if len(lefs.IaRed) == 0 {
+				t.Errorf("%v: process %v has no transitions", g.Family, p)
+			}
+		}
+		tokens := 0
+		for _, p := range net.Places {
+			tokens += p.Initial
+		}
+		if g.Tokens > 0 && tokens != g.Tokens {
+			t.Errorf("%v: %v tokens, expected %v", g.Family, tokens, g.Tokens)
+		}
+		// la misma semilla genera la misma red
+		again, _, _ := g.Generate()
+		if !reflect.DeepEqual(net, again) {
+			t.Errorf("%v: the same seed generated different nets", g.Family)
+		}
+		// y la red sigue viva
+		se := simulate(model, 50)
+		if n := len(se.Checkpoint().Results); n < 10 {
+			t.Errorf("%v: only %v firings", g.Family, n)
+		}
+	}
+}
+
+// Desde cualquier lugar de la red aleatoria se llega a todos los demás
+func TestGenerateRandomIsStronglyConnected(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		g := Generator{Family: FamilyRandom, Size: 10, Density: 0.1, MinDuration: 1, MaxDuration: 1, Processes: 1, Seed: seed}
+		net, _, err := g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for from := range net.Places {
+			reached := map[string]bool{net.Places[from].Name: true}
+			for changed := true; changed; {
+				changed = false
+				for _, tr := range net.Transitions {
+					if reached[tr.Inputs[0].Place] && !reached[tr.Outputs[0].Place] {
+						reached[tr.Outputs[0].Place] = true
+						changed = true
+					}
+				}
+			}
+			if len(reached) != len(net.Places) {
+				t.Fatalf("seed %v: from %v reaches %v places", seed, net.Places[from].Name, len(reached))
+			}
+		}
+	}
+}
+
+func TestGenerateRejectsInvalidParameters(t *testing.T) {
+	for _, tc := range []struct {
+		g   Generator
+		err string
+	}{
+		{Generator{Family: "star", Size: 3, MinDuration: 1, MaxDuration: 1, Processes: 1}, "unknown net family"},
+		{Generator{Family: FamilyRing, Size: 0, MinDuration: 1, MaxDuration: 1, Processes: 1}, "size"},
+		{Generator{Family: FamilyRing, Size: 3, MinDuration: 2, MaxDuration: 1, Processes: 1}, "invalid durations"},
+		{Generator{Family: FamilyRing, Size: 3, MinDuration: 1, MaxDuration: 1, Processes: 4}, "4 processes"},
+		{Generator{Family: FamilyForkJoin, Size: 3, Tokens: 2, MinDuration: 1, MaxDuration: 1, Processes: 1}, "2 tokens"},
+		{Generator{Family: FamilyRandom, Size: 3, Density: 2, MinDuration: 1, MaxDuration: 1, Processes: 1}, "density"},
+	} {
+		if _, _, err := tc.g.Generate(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%+v: error %v, expected %q", tc.g, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package petrinet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+Module es una red reutilizable. Sus lugares, transiciones e instancias de
+otros módulos pueden usar parámetros: cualquier valor "$nombre" se sustituye
+por el argumento de la instancia, o por el valor por defecto de Params, antes
+de leerlos. Ports son los nombres que enlaza cada instancia con la red que la
+contiene: un lugar que el módulo no declara y se funde con un lugar de esa red,
+o una transición del módulo cuyos arcos se añaden a una transición de esa red,
+que conserva su duración y demás atributos.
+*/
+type Module struct {
+	Name        string                     `json:"name"`
+	Params      map[string]json.RawMessage `json:"params,omitempty"`
+	Ports       []string                   `json:"ports,omitempty"`
+	Places      json.RawMessage            `json:"places,omitempty"`
+	Transitions json.RawMessage            `json:"transitions,omitempty"`
+	Instances   json.RawMessage            `json:"instances,omitempty"`
+}
+
+// Instance es una copia de un módulo con sus argumentos y el enlace de sus
+// puertos. Sus transiciones se simulan en Process, o en el proceso de la red
+// que la contiene si no se indica
+type Instance struct {
+	Name    string                     `json:"name"`
+	Module  string                     `json:"module"`
+	Args    map[string]json.RawMessage `json:"args,omitempty"`
+	Bind    map[string]string          `json:"bind,omitempty"`
+	Process *int                       `json:"process,omitempty"`
+}
+
+// Hierarchy es una red con instancias de módulos. Processes indica el proceso
+// de las transiciones propias, 0 si no aparecen
+type Hierarchy struct {
+	Modules     []Module       `json:"modules"`
+	Places      []Place        `json:"places"`
+	Transitions []Transition   `json:"transitions"`
+	Instances   []Instance     `json:"instances"`
+	Processes   map[string]int `json:"processes,omitempty"`
+	Policy      string         `json:"policy,omitempty"`
+	Seed        int64          `json:"seed,omitempty"`
+}
+
+// LoadHierarchy lee una red jerárquica en formato JSON
+func LoadHierarchy(filename string) (*Hierarchy, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeHierarchy(file)
+}
+
+// DecodeHierarchy lee una red jerárquica en formato JSON
+func DecodeHierarchy(r io.Reader) (*Hierarchy, error) {
+	h := &Hierarchy{}
+	if err := json.NewDecoder(r).Decode(h); err != nil {
+		return nil, fmt.Errorf("decode hierarchy: %w", err)
+	}
+	return h, nil
+}
+
+/*
+Flatten aplana la jerarquía en una red: los lugares y transiciones de cada
+instancia se llaman <instancia>.<nombre>, con los nombres de las instancias
+que la contienen delante, y los puertos se sustituyen por los nombres que
+enlazan. Devuelve también el proceso de cada transición de la red, para
+Partition. La red resultante no se ha validado; Compile lo hace.
+*/
+func (h *Hierarchy) Flatten() (*Net, []int, error) {
+	modules := map[string]Module{}
+	for _, m := range h.Modules {
+		if m.Name == "" {
+			return nil, nil, fmt.Errorf("module without name")
+		}
+		if _, ok := modules[m.Name]; ok {
+			return nil, nil, fmt.Errorf("duplicated module %v", m.Name)
+		}
+		modules[m.Name] = m
+	}
+	f := &flattener{modules: modules, net: &Net{Policy: h.Policy, Seed: h.Seed}}
+	f.net.Places = append(f.net.Places, h.Places...)
+	for _, t := range h.Transitions {
+		f.net.Transitions = append(f.net.Transitions, t)
+		f.owner = append(f.owner, h.Processes[t.Name])
+	}
+	for name := range h.Processes {
+		if f.net.TransitionIndex(name) < 0 {
+			return nil, nil, fmt.Errorf("process of unknown transition %v", name)
+		}
+	}
+	identity := func(n string) string { return n }
+	for _, inst := range h.Instances {
+		if err := f.expand(inst, "", identity, 0, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return f.net, f.owner, nil
+}
+
+// Estado del aplanado: la red construida y el proceso de sus transiciones
+type flattener struct {
+	modules map[string]Module
+	net     *Net
+	owner   []int
+}
+
+// expand añade a la red la instancia con el prefijo scope, simulada por
+// defecto en process. resolve da el nombre en la red de los lugares y
+// transiciones de quien la contiene, y stack son los módulos que la contienen
+func (f *flattener) expand(inst Instance, scope string, resolve func(string) string, process int, stack []string) error {
+	name := scope + inst.Name
+	m, ok := f.modules[inst.Module]
+	if !ok {
+		return fmt.Errorf("instance %v: unknown module %v", name, inst.Module)
+	}
+	for _, s := range stack {
+		if s == m.Name {
+			return fmt.Errorf("instance %v: module %v contains itself", name, m.Name)
+		}
+	}
+	if inst.Name == "" || strings.Contains(inst.Name, ".") {
+		return fmt.Errorf("instance %q of module %v: invalid name", inst.Name, m.Name)
+	}
+	if inst.Process != nil {
+		process = *inst.Process
+	}
+
+	args := map[string]interface{}{}
+	for param, def := range m.Params {
+		raw, ok := inst.Args[param]
+		if !ok {
+			raw = def
+		}
+		v, err := decodeValue(raw)
+		if err != nil {
+			return fmt.Errorf("instance %v: parameter %v: %w", name, param, err)
+		}
+		if v == nil {
+			return fmt.Errorf("instance %v: missing parameter %v", name, param)
+		}
+		args[param] = v
+	}
+	for param := range inst.Args {
+		if _, ok := m.Params[param]; !ok {
+			return fmt.Errorf("instance %v: unknown parameter %v", name, param)
+		}
+	}
+	var places []Place
+	var transitions []Transition
+	var instances []Instance
+	for _, body := range []struct {
+		raw json.RawMessage
+		v   interface{}
+	}{{m.Places, &places}, {m.Transitions, &transitions}, {m.Instances, &instances}} {
+		if err := substitute(body.raw, args, body.v); err != nil {
+			return fmt.Errorf("instance %v: %w", name, err)
+		}
+	}
+
+	ports := map[string]string{}
+	for _, port := range m.Ports {
+		bound, ok := inst.Bind[port]
+		if !ok {
+			return fmt.Errorf("instance %v: port %v is not bound", name, port)
+		}
+		ports[port] = resolve(bound)
+	}
+	for port := range inst.Bind {
+		if _, ok := ports[port]; !ok {
+			return fmt.Errorf("instance %v: unknown port %v", name, port)
+		}
+	}
+	local := func(n string) string {
+		if bound, ok := ports[n]; ok {
+			return bound
+		}
+		return name + "." + n
+	}
+	rename := func(arcs []Arc) []Arc {
+		var renamed []Arc
+		for _, a := range arcs {
+			renamed = append(renamed, Arc{Place: local(a.Place), Weight: a.Weight})
+		}
+		return renamed
+	}
+
+	for _, p := range places {
+		if _, ok := ports[p.Name]; ok {
+			return fmt.Errorf("instance %v: port %v is a place of the module", name, p.Name)
+		}
+		p.Name = local(p.Name)
+		f.net.Places = append(f.net.Places, p)
+	}
+	for _, t := range transitions {
+		t.Inputs, t.Outputs, t.Inhibitors = rename(t.Inputs), rename(t.Outputs), rename(t.Inhibitors)
+		var resets []string
+		for _, p := range t.Resets {
+			resets = append(resets, local(p))
+		}
+		t.Resets = resets
+		bound, fused := ports[t.Name]
+		if !fused {
+			t.Name = local(t.Name)
+			f.net.Transitions = append(f.net.Transitions, t)
+			f.owner = append(f.owner, process)
+			continue
+		}
+		i := f.net.TransitionIndex(bound)
+		if i < 0 {
+			return fmt.Errorf("instance %v: port %v bound to unknown transition %v", name, t.Name, bound)
+		}
+		target := &f.net.Transitions[i]
+		target.Inputs = mergeArcs(target.Inputs, t.Inputs)
+		target.Outputs = mergeArcs(target.Outputs, t.Outputs)
+		target.Inhibitors = append(append([]Arc(nil), target.Inhibitors...), t.Inhibitors...)
+		target.Resets = append(append([]string(nil), target.Resets...), t.Resets...)
+	}
+	for _, bound := range ports {
+		if f.net.PlaceIndex(bound) < 0 && f.net.TransitionIndex(bound) < 0 {
+			return fmt.Errorf("instance %v: port bound to unknown %v", name, bound)
+		}
+	}
+	for _, nested := range instances {
+		if err := f.expand(nested, name+".", local, process, append(stack, m.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeArcs añade los arcos de more a arcs, sumando los pesos de los arcos
+// con el mismo lugar
+func mergeArcs(arcs, more []Arc) []Arc {
+	merged := append([]Arc{}, arcs...)
+	for _, a := range more {
+		found := false
+		for i := range merged {
+			if merged[i].Place == a.Place {
+				merged[i].Weight = merged[i].weight() + a.weight()
+				found = true
+			}
+		}
+		if !found {
+			merged = append(merged, a)
+		}
+	}
+	return merged
+}
+
+// decodeValue lee un valor JSON conservando los números tal como se escribieron
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// substitute sustituye en raw los valores "$parámetro" por sus argumentos y
+// decodifica el resultado en v
+func substitute(raw json.RawMessage, args map[string]interface{}, v interface{}) error {
+	tree, err := decodeValue(raw)
+	if err != nil || tree == nil {
+		return err
+	}
+	var replace func(x interface{}) (interface{}, error)
+	replace = func(x interface{}) (interface{}, error) {
+		switch x := x.(type) {
+		case string:
+			if strings.HasPrefix(x, "$") {
+				arg, ok := args[x[1:]]
+				if !ok {
+					return nil, fmt.Errorf("unknown parameter %v", x[1:])
+				}
+				return arg, nil
+			}
+		case []interface{}:
+			for i := range x {
+				item, err := replace(x[i])
+				if err != nil {
+					return nil, err
+				}
+				x[i] = item
+			}
+		case map[string]interface{}:
+			for k := range x {
+				item, err := replace(x[k])
+				if err != nil {
+					return nil, err
+				}
+				x[k] = item
+			}
+		}
+		return x, nil
+	}
+	if tree, err = replace(tree); err != nil {
+		return err
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,100 @@
+package petrinet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Una línea de dos estaciones, la primera con el tiempo de proceso de la
+// instancia, y un contador de las piezas que vuelven a la fuente
+const hierarchyNet = `{
+  "modules": [
+    {"name": "estacion", "params": {"t": 1}, "ports": ["entrada", "salida"],
+      "transitions": [{"name": "procesa", "duration": "$t", "inputs": [{"place": "entrada"}], "outputs": [{"place": "salida"}]}]},
+    {"name": "linea", "params": {"t": null}, "ports": ["entrada", "salida"],
+      "places": [{"name": "medio"}],
+      "instances": [
+        {"name": "a", "module": "estacion", "args": {"t": "$t"}, "bind": {"entrada": "entrada", "salida": "medio"}},
+        {"name": "b", "module": "estacion", "bind": {"entrada": "medio", "salida": "salida"}, "process": 1}
+      ]},
+    {"name": "contador", "ports": ["vuelve"], "places": [{"name": "cuenta"}],
+      "transitions": [{"name": "vuelve", "duration": 0, "outputs": [{"place": "cuenta"}]}]}
+  ],
+  "places": [{"name": "fuente", "initial": 1}, {"name": "fin"}],
+  "transitions": [{"name": "vuelve", "duration": 1, "inputs": [{"place": "fin"}], "outputs": [{"place": "fuente"}]}],
+  "instances": [
+    {"name": "l1", "module": "linea", "args": {"t": 3}, "bind": {"entrada": "fuente", "salida": "fin"}},
+    {"name": "c", "module": "contador", "bind": {"vuelve": "vuelve"}}
+  ]
+}`
+
+func TestFlattenHierarchy(t *testing.T) {
+	h, err := DecodeHierarchy(strings.NewReader(hierarchyNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, owner, err := h.Flatten()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Net{
+		Places: []Place{{Name: "fuente", Initial: 1}, {Name: "fin"}, {Name: "l1.medio"}, {Name: "c.cuenta"}},
+		Transitions: []Transition{
+			{Name: "vuelve", Duration: 1, Inputs: []Arc{{Place: "fin"}}, Outputs: []Arc{{Place: "fuente"}, {Place: "c.cuenta"}}},
+			{Name: "l1.a.procesa", Duration: 3, Inputs: []Arc{{Place: "fuente"}}, Outputs: []Arc{{Place: "l1.medio"}}},
+			{Name: "l1.b.procesa", Duration: 1, Inputs: []Arc{{Place: "l1.medio"}}, Outputs: []Arc{{Place: "fin"}}},
+		},
+	}
+	if !reflect.DeepEqual(net, want) {
+		t.Fatalf("flattened net %+v, expected %+v", net, want)
+	}
+	if !reflect.DeepEqual(owner, []int{0, 0, 1}) {
+		t.Errorf("processes %v, expected [0 0 1]", owner)
+	}
+
+	model, err := Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Partition(owner); err != nil {
+		t.Fatal(err)
+	}
+	// cada vuelta dura 3 + 1 + 1 y en 11 la pieza está en la primera estación
+	se := simulate(model, 12)
+	marking, err := model.MarkingAt(se.Checkpoint().Results, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := model.Format(marking); got != "fuente=0 fin=0 l1.medio=0 c.cuenta=2" {
+		t.Errorf("marking at 11 is %v", got)
+	}
+}
+
+func TestFlattenRejectsInvalidInstances(t *testing.T) {
+	for _, tc := range []struct {
+		replace, with, err string
+	}{
+		{`"args": {"t": 3}, `, ``, "missing parameter t"},
+		{`"args": {"t": 3}`, `"args": {"t": 3, "u": 1}`, "unknown parameter u"},
+		{`"duration": "$t"`, `"duration": "$u"`, "unknown parameter u"},
+		{`"bind": {"vuelve": "vuelve"}`, `"bind": {}`, "port vuelve is not bound"},
+		{`"bind": {"vuelve": "vuelve"}`, `"bind": {"vuelve": "vuelve", "otro": "fin"}`, "unknown port otro"},
+		{`"bind": {"vuelve": "vuelve"}`, `"bind": {"vuelve": "sale"}`, "unknown transition sale"},
+		{`"salida": "fin"}`, `"salida": "final"}`, "unknown final"},
+		{`"module": "contador"`, `"module": "cuenta"`, "unknown module cuenta"},
+		{`"module": "estacion", "bind"`, `"module": "linea", "args": {"t": 1}, "bind"`, "contains itself"},
+	} {
+		src := strings.Replace(hierarchyNet, tc.replace, tc.with, 1)
+		if src == hierarchyNet {
+			t.Fatalf("%v not found", tc.replace)
+		}
+		h, err := DecodeHierarchy(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := h.Flatten(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: error %v, expected %q", tc.with, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package petrinet
+
+import (
+	"centralsim"
+	"fmt"
+)
+
+/*
+Partition reparte la Lefs compilada entre procesos lógicos: owner[t] es el
+proceso que simula la transición t. Los eventos hacia transiciones de otro
+proceso se codifican con el identificador global negativo, -(t+1), y las
+transiciones que los generan son de salida con el menor tiempo hasta sus
+eventos, MinDuration, como tiempo hasta la marca. Las transiciones que comparten un lugar de entrada se
+propagan de inmediato (IUL), por lo que deben estar en el mismo proceso, igual
+que las que leen el marcado de un lugar con arcos inhibidores o de reinicio.
+Las transiciones inmediatas pueden enviar eventos a otro proceso en el mismo
+instante, que las espera antes de disparar; por eso no pueden formar un ciclo
+entre procesos.
+*/
+func (m *Model) Partition(owner []int) ([]centralsim.Lefs, error) {
+	if len(owner) != len(m.Net.Transitions) {
+		return nil, fmt.Errorf("partition has %v transitions and the net %v", len(owner), len(m.Net.Transitions))
+	}
+	processes := 0
+	for t, p := range owner {
+		if p < 0 {
+			return nil, fmt.Errorf("transition %v: negative process %v", m.Net.Transitions[t].Name, p)
+		}
+		if p >= processes {
+			processes = p + 1
+		}
+	}
+	for t, tr := range m.Lefs.IaRed {
+		for _, trCo := range tr.TransConstIul {
+			if owner[trCo[0]] != owner[t] {
+				return nil, fmt.Errorf("transitions %v and %v share an input place and must be in the same process",
+					m.Net.Transitions[t].Name, m.Net.Transitions[trCo[0]].Name)
+			}
+		}
+	}
+	for p, anchor := range m.anchors {
+		for _, u := range m.placeGroup(p) {
+			if owner[u] != owner[anchor] {
+				return nil, fmt.Errorf("transitions %v and %v read place %v and must be in the same process",
+					m.Net.Transitions[anchor].Name, m.Net.Transitions[u].Name, m.Net.Places[p].Name)
+			}
+		}
+	}
+
+	subnets := make([]centralsim.Lefs, processes)
+	for i := range subnets {
+		subnets[i] = centralsim.Lefs{IaRed: centralsim.TransitionList{}, IsTransSensib: centralsim.MakeTransitionStack(),
+			PoliticaIntervalos: m.Lefs.PoliticaIntervalos, SemillaIntervalos: m.Lefs.SemillaIntervalos, Colores: m.Lefs.Colores}
+	}
+	for t, tr := range m.Lefs.IaRed {
+		local := owner[t]
+		minDuration := tr.MinDuration()
+		remote := func(target int) int {
+			if owner[target] == local {
+				return target
+			}
+			tr.EsSalida = true
+			tr.TiempoHastaMarca.LiTiempos = append(tr.TiempoHastaMarca.LiTiempos, int(minDuration))
+			return -(target + 1)
+		}
+		tr.TiempoHastaMarca.LiTiempos = nil
+		pul := make([][2]int, len(tr.TransConstPul))
+		for i, trCo := range tr.TransConstPul {
+			pul[i] = [2]int{remote(trCo[0]), trCo[1]}
+		}
+		tr.TransConstPul = pul
+		if tr.LugaresPul != nil {
+			places := make([][3]int, len(tr.LugaresPul))
+			for i, trPlCo := range tr.LugaresPul {
+				places[i] = [3]int{remote(trPlCo[0]), trPlCo[1], trPlCo[2]}
+			}
+			tr.LugaresPul = places
+		}
+		subnets[local].IaRed = append(subnets[local].IaRed, tr)
+	}
+	immediate := make([]map[int]bool, processes) // procesos que envían eventos inmediatos a cada uno
+	for p := range immediate {
+		immediate[p] = map[int]bool{}
+	}
+	for t, tr := range m.Lefs.IaRed {
+		if tr.IiDuracionDisparo > 0 || len(tr.IiIntervalo) > 0 && tr.IiIntervalo[0] > 0 {
+			continue // no envía eventos del instante en que se sensibiliza
+		}
+		targets := []int{}
+		for _, trCo := range tr.TransConstPul {
+			targets = append(targets, trCo[0])
+		}
+		for _, trPlCo := range tr.LugaresPul {
+			targets = append(targets, trPlCo[0])
+		}
+		for _, u := range targets {
+			if owner[u] != owner[t] {
+				immediate[owner[u]][owner[t]] = true
+			}
+		}
+	}
+	if cycle := immediateCycle(immediate); cycle != nil {
+		return nil, fmt.Errorf("immediate transitions send events in a cycle between processes %v", cycle)
+	}
+	for p, senders := range immediate {
+		for q := 0; q < processes; q++ {
+			if senders[q] {
+				subnets[p].IaPrecedentesInmediatos = append(subnets[p].IaPrecedentesInmediatos, q)
+			}
+		}
+	}
+	for _, lugar := range m.Lefs.IaLugares {
+		local := owner[m.anchors[lugar.IiIdGlobal]]
+		subnets[local].IaLugares = append(subnets[local].IaLugares, lugar)
+	}
+	return subnets, nil
+}
+
+// Devuelve los procesos de un ciclo de eventos inmediatos, nil si no hay;
+// senders[p] son los procesos que envían eventos inmediatos a p
+func immediateCycle(senders []map[int]bool) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(senders))
+	path := []int{}
+	var visit func(p int) []int
+	visit = func(p int) []int {
+		state[p] = visiting
+		path = append(path, p)
+		for q := range senders {
+			if !senders[p][q] {
+				continue
+			}
+			if state[q] == visiting {
+				for i, r := range path {
+					if r == q {
+						return append([]int{}, path[i:]...)
+					}
+				}
+			}
+			if state[q] == unvisited {
+				if cycle := visit(q); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[p] = visited
+		return nil
+	}
+	for p := range senders {
+		if state[p] == unvisited {
+			if cycle := visit(p); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+/*
+Package petrinet describe redes de Petri temporizadas con lugares, marcas y
+arcos con peso, y las compila a la codificación Lefs que simula centralsim.
+La correspondencia inversa permite expresar los resultados de la simulación
+como marcados de los lugares.
+*/
+package petrinet
+
+import (
+	"centralsim"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Place es un lugar de la red con su marcado inicial. Tracked mantiene su
+// marcado en la Lefs aunque ningún arco inhibidor o de reinicio lo lea, como
+// necesitan los lugares de las redes coloreadas para guardar sus fichas
+type Place struct {
+	Name    string `json:"name"`
+	Initial int    `json:"initial"`
+	Tracked bool   `json:"tracked,omitempty"`
+}
+
+// Arc une un lugar con una transición; el peso por defecto es 1
+type Arc struct {
+	Place  string `json:"place"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// weight devuelve el peso del arco, 1 si no se indicó
+func (a Arc) weight() int {
+	if a.Weight == 0 {
+		return 1
+	}
+	return a.Weight
+}
+
+/*
+Transition es una transición temporizada con sus arcos de entrada y salida, o
+inmediata si su duración es 0: dispara en el instante en que se sensibiliza,
+antes que las temporizadas y, entre las inmediatas, por orden de prioridad.
+Un arco inhibidor impide el disparo mientras el lugar tenga al menos Weight
+marcas (1 por defecto); un arco de reinicio vacía el lugar al disparar.
+Con Interval [a, b], como en las redes de Petri temporales, dispara entre a y
+b después de sensibilizarse, en el instante que elige la política de la red,
+y si antes deja de estar sensibilizada el intervalo vuelve a empezar.
+Servers limita los disparos en curso de una transición temporizada sin
+intervalo: 0 o 1 para un único servidor, k, o centralsim.ServidoresInfinitos
+para disparar tantas veces como su grado de sensibilización.
+Con Memory el disparo compite por las marcas durante la duración, que las
+consume al completarse, y se interrumpe si antes se desactiva: con
+centralsim.MemoriaSensibilizacion vuelve a empezar al sensibilizarse de nuevo
+y con centralsim.MemoriaEdad continúa con el tiempo que le faltaba.
+*/
+type Transition struct {
+	Name       string                 `json:"name"`
+	Duration   centralsim.TypeClock   `json:"duration"` // tiempo desde el disparo hasta que se producen las marcas
+	Priority   int                    `json:"priority,omitempty"`
+	Interval   []centralsim.TypeClock `json:"interval,omitempty"`
+	Servers    int                    `json:"servers,omitempty"`
+	Memory     string                 `json:"memory,omitempty"`
+	Inputs     []Arc                  `json:"inputs"`
+	Outputs    []Arc                  `json:"outputs"`
+	Inhibitors []Arc                  `json:"inhibitors,omitempty"`
+	Resets     []string               `json:"resets,omitempty"`
+}
+
+// delay es el tiempo desde el disparo anotado hasta que produce sus marcas
+func (t Transition) delay() centralsim.TypeClock {
+	if t.Memory != "" {
+		return 0
+	}
+	return t.Duration
+}
+
+// Net es una red de Petri de lugares y transiciones. Policy elige el instante
+// de disparo de las transiciones con intervalo: centralsim.IntervalEarliest
+// (por defecto), IntervalLatest o IntervalUniform con la semilla Seed
+type Net struct {
+	Places      []Place      `json:"places"`
+	Transitions []Transition `json:"transitions"`
+	Policy      string       `json:"policy,omitempty"`
+	Seed        int64        `json:"seed,omitempty"`
+}
+
+// Load lee una red en formato JSON
+func Load(filename string) (*Net, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file)
+}
+
+// Decode lee una red en formato JSON y comprueba que es válida
+func Decode(r io.Reader) (*Net, error) {
+	net := &Net{}
+	if err := json.NewDecoder(r).Decode(net); err != nil {
+		return nil, fmt.Errorf("decode net: %w", err)
+	}
+	if err := net.Validate(); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+// PlaceIndex devuelve la posición del lugar con ese nombre, -1 si no existe
+func (n *Net) PlaceIndex(name string) int {
+	for i, p := range n.Places {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// TransitionIndex devuelve la posición de la transición con ese nombre, -1 si no existe
+func (n *Net) TransitionIndex(name string) int {
+	for i, t := range n.Transitions {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+Validate comprueba que la red se puede compilar a una Lefs: nombres únicos,
+arcos a lugares existentes con peso positivo, marcado inicial no negativo y
+transiciones con algún lugar de entrada, duración no negativa, intervalo
+[a, b] con 0 <= a <= b, prioridad solo si son inmediatas, servidores solo
+si son temporizadas sin intervalo y política de memoria si además no tienen
+servidores, y arcos inhibidores y de reinicio a lugares existentes sin
+repetir.
+La función de sensibilización lineal solo es exacta si cada transición tiene
+un lugar de entrada, con cualquier peso, o varios con peso 1 que nunca tienen
+más de una marca; por eso no se admiten varios lugares de entrada con pesos
+o marcado inicial mayores que 1. El marcado inicial de los lugares Tracked
+puede ser mayor, porque las transiciones coloreadas comprueban el marcado
+exacto de sus entradas al buscar una ligadura.
+*/
+func (n *Net) Validate() error {
+	places := map[string]bool{}
+	initial := map[string]int{} // marcado inicial de los lugares no Tracked
+	for _, p := range n.Places {
+		if p.Name == "" {
+			return fmt.Errorf("place without name")
+		}
+		if places[p.Name] {
+			return fmt.Errorf("duplicated place %v", p.Name)
+		}
+		if p.Initial < 0 {
+			return fmt.Errorf("place %v: negative initial marking %v", p.Name, p.Initial)
+		}
+		places[p.Name] = true
+		if !p.Tracked {
+			initial[p.Name] = p.Initial
+		}
+	}
+
+	if !centralsim.ValidIntervalPolicy(n.Policy) {
+		return fmt.Errorf("unknown interval policy %q", n.Policy)
+	}
+	transitions := map[string]bool{}
+	for _, t := range n.Transitions {
+		if t.Name == "" {
+			return fmt.Errorf("transition without name")
+		}
+		if transitions[t.Name] {
+			return fmt.Errorf("duplicated transition %v", t.Name)
+		}
+		transitions[t.Name] = true
+		if t.Duration < 0 {
+			return fmt.Errorf("transition %v: negative duration %v", t.Name, t.Duration)
+		}
+		if t.Priority < 0 {
+			return fmt.Errorf("transition %v: negative priority %v", t.Name, t.Priority)
+		}
+		if len(t.Interval) > 0 && (len(t.Interval) != 2 || t.Interval[0] < 0 || t.Interval[0] > t.Interval[1]) {
+			return fmt.Errorf("transition %v: invalid interval %v", t.Name, t.Interval)
+		}
+		if t.Servers < centralsim.ServidoresInfinitos {
+			return fmt.Errorf("transition %v: invalid servers %v", t.Name, t.Servers)
+		}
+		if t.Servers != 0 && (t.Duration == 0 || len(t.Interval) > 0) {
+			return fmt.Errorf("transition %v: servers %v, only timed transitions without interval have servers", t.Name, t.Servers)
+		}
+		if !centralsim.ValidMemoryPolicy(t.Memory) {
+			return fmt.Errorf("transition %v: unknown memory policy %q", t.Name, t.Memory)
+		}
+		if t.Memory != "" && (t.Duration == 0 || len(t.Interval) > 0 || t.Servers != 0) {
+			return fmt.Errorf("transition %v: memory policy %v, only timed transitions without interval or servers are preemptible",
+				t.Name, t.Memory)
+		}
+		if t.Priority > 0 && (t.Duration > 0 || len(t.Interval) > 0) {
+			return fmt.Errorf("transition %v: priority %v, only immediate transitions have priorities", t.Name, t.Priority)
+		}
+		if len(t.Inputs) == 0 {
+			return fmt.Errorf("transition %v: no input places", t.Name)
+		}
+		for _, arcs := range [][]Arc{t.Inputs, t.Outputs} {
+			seen := map[string]bool{}
+			for _, a := range arcs {
+				if !places[a.Place] {
+					return fmt.Errorf("transition %v: unknown place %v", t.Name, a.Place)
+				}
+				if a.Weight < 0 {
+					return fmt.Errorf("transition %v: negative weight on arc with %v", t.Name, a.Place)
+				}
+				if seen[a.Place] {
+					return fmt.Errorf("transition %v: duplicated arc with %v", t.Name, a.Place)
+				}
+				seen[a.Place] = true
+			}
+		}
+		seen := map[string]bool{}
+		for _, a := range t.Inhibitors {
+			if !places[a.Place] {
+				return fmt.Errorf("transition %v: unknown place %v", t.Name, a.Place)
+			}
+			if a.Weight < 0 {
+				return fmt.Errorf("transition %v: negative threshold on inhibitor arc with %v", t.Name, a.Place)
+			}
+			if seen[a.Place] {
+				return fmt.Errorf("transition %v: duplicated inhibitor arc with %v", t.Name, a.Place)
+			}
+			seen[a.Place] = true
+		}
+		seen = map[string]bool{}
+		for _, place := range t.Resets {
+			if !places[place] {
+				return fmt.Errorf("transition %v: unknown place %v", t.Name, place)
+			}
+			if seen[place] {
+				return fmt.Errorf("transition %v: duplicated reset arc with %v", t.Name, place)
+			}
+			seen[place] = true
+		}
+		if len(t.Inputs) > 1 {
+			for _, a := range t.Inputs {
+				if a.weight() > 1 {
+					return fmt.Errorf("transition %v: weight %v on input %v, only single-input transitions can have weighted inputs",
+						t.Name, a.weight(), a.Place)
+				}
+				if initial[a.Place] > 1 {
+					return fmt.Errorf("transition %v: initial marking %v on input %v, inputs of multi-input transitions hold one token at most",
+						t.Name, initial[a.Place], a.Place)
+				}
+			}
+		}
+	}
+	return nil
+}
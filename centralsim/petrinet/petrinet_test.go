@@ -0,0 +1,482 @@
+package petrinet
+
+import (
+	"bytes"
+	"centralsim"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Una máquina procesa una pieza, anotándola en el registro, y un operario la
+// devuelve a la cola
+const ringNet = `{
+  "places": [{"name": "cola", "initial": 1}, {"name": "hecha"}, {"name": "registro"}],
+  "transitions": [
+    {"name": "procesa", "duration": 1, "inputs": [{"place": "cola"}], "outputs": [{"place": "hecha"}, {"place": "registro"}]},
+    {"name": "devuelve", "duration": 2, "inputs": [{"place": "hecha"}], "outputs": [{"place": "cola"}]}
+  ]
+}`
+
+// Simula el modelo en un único motor hasta el ciclo indicado, registrando el marcado
+func simulate(model *Model, cycles centralsim.TypeClock) *centralsim.SimulationEngine {
+	se := centralsim.MakeSimulationEngine(model.Lefs, centralsim.NopLogger("0"),
+		make(chan centralsim.Event), make(chan centralsim.IncommingEvent), make(chan bool),
+		make(chan centralsim.LookAhead), make(chan centralsim.LookAhead),
+		make(chan centralsim.LookAhead), make(chan centralsim.LookAhead),
+		map[int]centralsim.TypeClock{}, 0, nil)
+	se.ObservePlaces(model.PlaceModel())
+	se.SimularPeriodo(0, cycles)
+	return se
+}
+
+func firing(t centralsim.IndLocalTrans, clock centralsim.TypeClock) centralsim.ResultadoTransition {
+	return centralsim.ResultadoTransition{CodTransition: t, ValorRelojDisparo: clock}
+}
+
+func TestCompileRing(t *testing.T) {
+	net, err := Decode(strings.NewReader(ringNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	procesa, devuelve := model.Lefs.IaRed[0], model.Lefs.IaRed[1]
+	if procesa.IiValorLef != 0 || devuelve.IiValorLef != 1 {
+		t.Errorf("initial lef values %v and %v, expected 0 and 1", procesa.IiValorLef, devuelve.IiValorLef)
+	}
+	if !reflect.DeepEqual(procesa.TransConstIul, [][2]int{{0, 1}}) || !reflect.DeepEqual(procesa.TransConstPul, [][2]int{{1, -1}}) {
+		t.Errorf("unexpected propagation of procesa: IUL %v PUL %v", procesa.TransConstIul, procesa.TransConstPul)
+	}
+
+	// la Lefs escrita se vuelve a leer igual
+	var out bytes.Buffer
+	if err := model.WriteLefs(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"ii_listactes_PUL"`) {
+		t.Errorf("unexpected lefs JSON:\n%v", out.String())
+	}
+
+	se := simulate(model, 10)
+	results := se.Checkpoint().Results
+	want := []centralsim.ResultadoTransition{firing(0, 0), firing(1, 1), firing(0, 3), firing(1, 4), firing(0, 6), firing(1, 7), firing(0, 9)}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("firings %v, expected %v", results, want)
+	}
+	if counts := model.Firings(results); counts["procesa"] != 4 || counts["devuelve"] != 3 {
+		t.Errorf("unexpected firings %v", counts)
+	}
+
+	for clock, want := range map[centralsim.TypeClock]string{
+		0:  "cola=0 hecha=0 registro=0", // la pieza está en proceso
+		1:  "cola=0 hecha=0 registro=1", // y vuelve a la cola
+		9:  "cola=0 hecha=0 registro=3",
+		10: "cola=0 hecha=1 registro=4",
+	} {
+		marking, err := model.MarkingAt(results, clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := model.Format(marking); got != want {
+			t.Errorf("marking at %v is %v, expected %v", clock, got, want)
+		}
+	}
+
+	// el motor mantiene el mismo marcado que se reconstruye de los disparos
+	if got := model.Format(se.Marking()); got != "cola=0 hecha=1 registro=4" {
+		t.Errorf("engine marking %v", got)
+	}
+	stats := se.MarkingLog().Statistics()
+	if s := stats[2]; s.Min != 0 || s.Max != 3 || s.Final != 4 || s.Average != 1.8 || s.Histogram[1] != 3 {
+		t.Errorf("unexpected statistics of registro %+v", s)
+	}
+
+	if _, err := model.MarkingAt([]centralsim.ResultadoTransition{firing(1, 0)}, 0); err == nil {
+		t.Error("firing devuelve without tokens should be inconsistent")
+	}
+}
+
+// Un generador llena un almacén que se vacía cada 4 ciclos; el vigilante
+// trabaja cada ciclo salvo cuando el almacén tiene 3 o más piezas
+const inhibitorNet = `{
+  "places": [{"name": "fuente", "initial": 1}, {"name": "almacen"}, {"name": "turno", "initial": 1}, {"name": "reloj", "initial": 1}],
+  "transitions": [
+    {"name": "genera", "duration": 1, "inputs": [{"place": "fuente"}], "outputs": [{"place": "fuente"}, {"place": "almacen"}]},
+    {"name": "vigila", "duration": 1, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}],
+      "inhibitors": [{"place": "almacen", "weight": 3}]},
+    {"name": "limpia", "duration": 4, "inputs": [{"place": "reloj"}], "outputs": [{"place": "reloj"}], "resets": ["almacen"]}
+  ]
+}`
+
+func TestInhibitorAndResetArcs(t *testing.T) {
+	net, err := Decode(strings.NewReader(inhibitorNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genera, vigila, limpia := model.Lefs.IaRed[0], model.Lefs.IaRed[1], model.Lefs.IaRed[2]
+	if !reflect.DeepEqual(vigila.Inhibidores, [][2]int{{1, 3}}) || !reflect.DeepEqual(limpia.Reinicios, []int{1}) {
+		t.Errorf("unexpected arcs: inhibitors %v resets %v", vigila.Inhibidores, limpia.Reinicios)
+	}
+	// las piezas llegan al almacén como eventos dirigidos a vigila
+	if !reflect.DeepEqual(genera.LugaresPul, [][3]int{{1, 1, 1}}) {
+		t.Errorf("unexpected place propagation of genera %v", genera.LugaresPul)
+	}
+	if !reflect.DeepEqual(model.Lefs.IaLugares, []centralsim.Lugar{{IiIdGlobal: 1}}) {
+		t.Errorf("unexpected tracked places %+v", model.Lefs.IaLugares)
+	}
+
+	se := simulate(model, 13)
+	results := se.Checkpoint().Results
+	vigilaFirings := []centralsim.TypeClock{}
+	for _, r := range results {
+		if r.CodTransition == 1 {
+			vigilaFirings = append(vigilaFirings, r.ValorRelojDisparo)
+		}
+	}
+	if want := []centralsim.TypeClock{0, 1, 2, 4, 5, 6, 8, 9, 10, 12}; !reflect.DeepEqual(vigilaFirings, want) {
+		t.Errorf("vigila fired at %v, expected %v", vigilaFirings, want)
+	}
+	if counts := model.Firings(results); counts["genera"] != 13 || counts["limpia"] != 4 {
+		t.Errorf("unexpected firings %v", counts)
+	}
+
+	for clock, want := range map[centralsim.TypeClock]string{
+		11: "fuente=0 almacen=3 turno=1 reloj=0",
+		12: "fuente=0 almacen=0 turno=0 reloj=0", // limpia retira las 4 piezas
+		13: "fuente=1 almacen=1 turno=1 reloj=0",
+	} {
+		marking, err := model.MarkingAt(results, clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := model.Format(marking); got != want {
+			t.Errorf("marking at %v is %v, expected %v", clock, got, want)
+		}
+	}
+	if got := model.Format(se.Marking()); got != "fuente=1 almacen=1 turno=1 reloj=0" {
+		t.Errorf("engine marking %v", got)
+	}
+	if s := se.MarkingLog().Statistics()[1]; s.Max != 3 || s.Final != 1 {
+		t.Errorf("unexpected statistics of almacen %+v", s)
+	}
+
+	if _, err := model.Partition([]int{0, 0, 1}); err == nil || !strings.Contains(err.Error(), "read place almacen") {
+		t.Errorf("error %v, expected vigila and limpia in the same process", err)
+	}
+}
+
+// Cada 2 ciclos llega un pedido que atiende de inmediato la transición
+// urgente, de mayor prioridad que normal, y que se anota en el mismo instante
+const immediateNet = `{
+  "places": [{"name": "fuente", "initial": 1}, {"name": "cola"}, {"name": "rapida"}, {"name": "lenta"},
+    {"name": "caducada"}, {"name": "libro"}],
+  "transitions": [
+    {"name": "llega", "duration": 2, "inputs": [{"place": "fuente"}], "outputs": [{"place": "fuente"}, {"place": "cola"}]},
+    {"name": "urgente", "duration": 0, "priority": 1, "inputs": [{"place": "cola"}], "outputs": [{"place": "rapida"}]},
+    {"name": "normal", "duration": 0, "inputs": [{"place": "cola"}], "outputs": [{"place": "lenta"}]},
+    {"name": "caduca", "duration": 1, "inputs": [{"place": "cola"}], "outputs": [{"place": "caducada"}]},
+    {"name": "anota", "duration": 0, "inputs": [{"place": "rapida"}], "outputs": [{"place": "libro"}]}
+  ]
+}`
+
+func TestImmediateTransitions(t *testing.T) {
+	net, err := Decode(strings.NewReader(immediateNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se := simulate(model, 7)
+	if err := se.Err(); err != nil {
+		t.Fatal(err)
+	}
+	results := se.Checkpoint().Results
+	// en cada llegada disparan las inmediatas por prioridad y después la temporizada
+	want := []centralsim.ResultadoTransition{firing(0, 0),
+		firing(1, 2), firing(4, 2), firing(0, 2),
+		firing(1, 4), firing(4, 4), firing(0, 4),
+		firing(1, 6), firing(4, 6), firing(0, 6)}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("firings %v, expected %v", results, want)
+	}
+	marking, err := model.MarkingAt(results, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := model.Format(marking); got != "fuente=0 cola=0 rapida=0 lenta=0 caducada=0 libro=3" {
+		t.Errorf("marking at 6 is %v", got)
+	}
+	// el motor termina en 8, con el pedido que llega entonces aún sin atender
+	if got := model.Format(se.Marking()); got != "fuente=1 cola=1 rapida=0 lenta=0 caducada=0 libro=3" {
+		t.Errorf("engine marking %v", got)
+	}
+}
+
+func TestVanishingLoop(t *testing.T) {
+	net, err := Decode(strings.NewReader(`{
+  "places": [{"name": "p", "initial": 1}, {"name": "q"}, {"name": "r", "initial": 1}],
+  "transitions": [
+    {"name": "ida", "duration": 0, "inputs": [{"place": "p"}], "outputs": [{"place": "q"}]},
+    {"name": "vuelta", "duration": 0, "inputs": [{"place": "q"}], "outputs": [{"place": "p"}]},
+    {"name": "espera", "duration": 3, "inputs": [{"place": "r"}], "outputs": [{"place": "r"}]}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se := simulate(model, 10)
+	if err := se.Err(); err == nil || err.Error() != "vanishing loop at 0: transitions [0 1] fire without end" {
+		t.Fatalf("error %v, expected a vanishing loop", err)
+	}
+	if clock := se.Clock(); clock != 0 {
+		t.Errorf("simulation stopped at %v, expected 0", clock)
+	}
+
+	// el bucle tampoco puede repartirse entre dos procesos
+	if _, err := model.Partition([]int{0, 1, 0}); err == nil || !strings.Contains(err.Error(), "cycle between processes") {
+		t.Errorf("error %v, expected an immediate cycle between processes", err)
+	}
+}
+
+// Un pedido se sirve entre 2 y 5 después de llegar o se cancela entre 3 y 4;
+// el turno mantiene un evento pendiente para que el motor no espere sin fin
+const raceNet = `{
+  "places": [{"name": "pedido", "initial": 1}, {"name": "servido"}, {"name": "anulado"}, {"name": "turno", "initial": 1}],
+  "transitions": [
+    {"name": "sirve", "duration": 1, "interval": [2, 5], "inputs": [{"place": "pedido"}], "outputs": [{"place": "servido"}]},
+    {"name": "cancela", "duration": 0, "interval": [3, 4], "inputs": [{"place": "pedido"}], "outputs": [{"place": "anulado"}]},
+    {"name": "cambia", "duration": 20, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}]}
+  ]
+}`
+
+func TestFiringIntervals(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		want   []centralsim.ResultadoTransition
+	}{
+		{centralsim.IntervalEarliest, []centralsim.ResultadoTransition{firing(2, 0), firing(0, 2)}},
+		{centralsim.IntervalLatest, []centralsim.ResultadoTransition{firing(2, 0), firing(1, 4)}},
+	} {
+		net, err := Decode(strings.NewReader(raceNet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		net.Policy = tc.policy
+		model, err := Compile(net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results := simulate(model, 10).Checkpoint().Results; !reflect.DeepEqual(results, tc.want) {
+			t.Errorf("%v: firings %v, expected %v", tc.policy, results, tc.want)
+		}
+	}
+
+	// la elección uniforme queda dentro del intervalo y se repite con la misma semilla
+	net, err := Decode(strings.NewReader(raceNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[centralsim.ResultadoTransition]bool{}
+	for seed := int64(1); seed <= 20; seed++ {
+		net.Policy, net.Seed = centralsim.IntervalUniform, seed
+		var runs [2][]centralsim.ResultadoTransition
+		for i := range runs {
+			model, err := Compile(net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runs[i] = simulate(model, 10).Checkpoint().Results
+		}
+		results := runs[0]
+		if !reflect.DeepEqual(results, runs[1]) {
+			t.Fatalf("seed %v: firings %v and %v", seed, results, runs[1])
+		}
+		if len(results) != 2 {
+			t.Fatalf("seed %v: firings %v, expected two", seed, results)
+		}
+		r := results[1]
+		if r.CodTransition == 0 && (r.ValorRelojDisparo < 2 || r.ValorRelojDisparo > 4) ||
+			r.CodTransition == 1 && (r.ValorRelojDisparo < 3 || r.ValorRelojDisparo > 4) {
+			t.Errorf("seed %v: firing %v outside its interval", seed, r)
+		}
+		seen[r] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("uniform choice always fires %v", seen)
+	}
+}
+
+func TestIntervalRestartsWhenDisabled(t *testing.T) {
+	// la alarma avisa 2 después de sensibilizarse, pero la puerta abierta la
+	// inhibe entre 1 y 2 y el intervalo vuelve a empezar al cerrarla
+	net, err := Decode(strings.NewReader(`{
+  "places": [{"name": "cerrada", "initial": 1}, {"name": "abierta"}, {"name": "hecho"},
+             {"name": "alarma", "initial": 1}, {"name": "avisado"}, {"name": "turno", "initial": 1}],
+  "transitions": [
+    {"name": "abre", "duration": 1, "inputs": [{"place": "cerrada"}], "outputs": [{"place": "abierta"}]},
+    {"name": "cierra", "duration": 0, "interval": [1, 1], "inputs": [{"place": "abierta"}], "outputs": [{"place": "hecho"}]},
+    {"name": "avisa", "duration": 0, "interval": [2, 2], "inputs": [{"place": "alarma"}], "outputs": [{"place": "avisado"}],
+     "inhibitors": [{"place": "abierta"}]},
+    {"name": "cambia", "duration": 20, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}]}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se := simulate(model, 10)
+	want := []centralsim.ResultadoTransition{firing(3, 0), firing(0, 0), firing(1, 2), firing(2, 4)}
+	if results := se.Checkpoint().Results; !reflect.DeepEqual(results, want) {
+		t.Fatalf("firings %v, expected %v", results, want)
+	}
+	if got := model.Format(se.Marking()); got != "cerrada=0 abierta=0 hecho=1 alarma=0 avisado=1 turno=1" {
+		t.Errorf("engine marking %v", got)
+	}
+}
+
+// Tres piezas esperan a una máquina que tarda 4 en procesar cada una
+const poolNet = `{
+  "places": [{"name": "piezas", "initial": 3}, {"name": "hechas"}, {"name": "turno", "initial": 1}],
+  "transitions": [
+    {"name": "procesa", "duration": 4, "inputs": [{"place": "piezas"}], "outputs": [{"place": "hechas"}]},
+    {"name": "cambia", "duration": 20, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}]}
+  ]
+}`
+
+func TestServerSemantics(t *testing.T) {
+	for _, tc := range []struct {
+		servers int
+		want    []centralsim.ResultadoTransition
+		at4     string
+	}{
+		{0, []centralsim.ResultadoTransition{firing(1, 0), firing(0, 0), firing(0, 4), firing(0, 8)}, "piezas=1 hechas=1 turno=0"},
+		{2, []centralsim.ResultadoTransition{firing(1, 0), firing(0, 0), firing(0, 0), firing(0, 4)}, "piezas=0 hechas=2 turno=0"},
+		{centralsim.ServidoresInfinitos, []centralsim.ResultadoTransition{firing(1, 0), firing(0, 0), firing(0, 0), firing(0, 0)},
+			"piezas=0 hechas=3 turno=0"},
+	} {
+		net, err := Decode(strings.NewReader(poolNet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		net.Transitions[0].Servers = tc.servers
+		model, err := Compile(net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		se := simulate(model, 15)
+		results := se.Checkpoint().Results
+		if !reflect.DeepEqual(results, tc.want) {
+			t.Errorf("%v servers: firings %v, expected %v", tc.servers, results, tc.want)
+		}
+		// los disparos en curso producen sus marcas al terminar
+		marking, err := model.MarkingAt(results, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := model.Format(marking); got != tc.at4 {
+			t.Errorf("%v servers: marking at 4 is %v, expected %v", tc.servers, got, tc.at4)
+		}
+	}
+}
+
+// Un operario trabaja 5 una pieza, pero en 2 llega un ladrón que se la lleva
+// y la devuelve en 5
+const preemptionNet = `{
+  "places": [{"name": "pieza", "initial": 1}, {"name": "hecha"}, {"name": "calle", "initial": 1}, {"name": "ladron"},
+    {"name": "turno", "initial": 1}],
+  "transitions": [
+    {"name": "trabaja", "duration": 5, "inputs": [{"place": "pieza"}], "outputs": [{"place": "hecha"}]},
+    {"name": "llega", "duration": 2, "inputs": [{"place": "calle"}], "outputs": [{"place": "ladron"}]},
+    {"name": "roba", "duration": 3, "inputs": [{"place": "ladron"}, {"place": "pieza"}], "outputs": [{"place": "pieza"}]},
+    {"name": "cambia", "duration": 20, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}]}
+  ]
+}`
+
+func TestPreemption(t *testing.T) {
+	for _, tc := range []struct {
+		memory string
+		want   []centralsim.ResultadoTransition
+		at6    string
+	}{
+		// sin memoria el disparo consume la pieza al empezar y el ladrón no la encuentra
+		{"", []centralsim.ResultadoTransition{firing(3, 0), firing(1, 0), firing(0, 0)},
+			"pieza=0 hecha=1 calle=0 ladron=1 turno=0"},
+		// interrumpido en 2, vuelve a empezar al recuperar la pieza en 5
+		{centralsim.MemoriaSensibilizacion, []centralsim.ResultadoTransition{firing(3, 0), firing(1, 0), firing(2, 2), firing(0, 10)},
+			"pieza=1 hecha=0 calle=0 ladron=0 turno=0"},
+		// y con memoria de edad solo le faltan 3
+		{centralsim.MemoriaEdad, []centralsim.ResultadoTransition{firing(3, 0), firing(1, 0), firing(2, 2), firing(0, 8)},
+			"pieza=1 hecha=0 calle=0 ladron=0 turno=0"},
+	} {
+		net, err := Decode(strings.NewReader(preemptionNet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		net.Transitions[0].Memory = tc.memory
+		model, err := Compile(net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		se := simulate(model, 15)
+		results := se.Checkpoint().Results
+		if !reflect.DeepEqual(results, tc.want) {
+			t.Errorf("memory %q: firings %v, expected %v", tc.memory, results, tc.want)
+			continue
+		}
+		marking, err := model.MarkingAt(results, 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := model.Format(marking); got != tc.at6 {
+			t.Errorf("memory %q: marking at 6 is %v, expected %v", tc.memory, got, tc.at6)
+		}
+		if got := model.Format(se.Marking()); !strings.Contains(got, "hecha=1") {
+			t.Errorf("memory %q: engine marking %v", tc.memory, got)
+		}
+	}
+}
+
+func TestValidateRejectsNonLinearNets(t *testing.T) {
+	for _, tc := range []struct{ net, err string }{
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": 1, "inputs": [{"place": "b"}]}]}`, "unknown place b"},
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": -1, "inputs": [{"place": "a"}]}]}`, "negative duration -1"},
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": 1, "priority": 2, "inputs": [{"place": "a"}]}]}`,
+			"only immediate transitions have priorities"},
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": 0, "interval": [3, 1], "inputs": [{"place": "a"}]}]}`,
+			"invalid interval [3 1]"},
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": 0, "servers": 2, "inputs": [{"place": "a"}]}]}`,
+			"only timed transitions without interval have servers"},
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": 0, "memory": "age", "inputs": [{"place": "a"}]}]}`,
+			"only timed transitions without interval or servers are preemptible"},
+		{`{"places": [{"name": "a"}], "policy": "last", "transitions": [{"name": "t", "duration": 1, "inputs": [{"place": "a"}]}]}`,
+			"unknown interval policy"},
+		{`{"places": [{"name": "a"}, {"name": "b"}], "transitions": [{"name": "t", "duration": 1,
+			"inputs": [{"place": "a", "weight": 2}, {"place": "b"}]}]}`, "only single-input transitions can have weighted inputs"},
+		{`{"places": [{"name": "a", "initial": 2}, {"name": "b"}], "transitions": [{"name": "t", "duration": 1,
+			"inputs": [{"place": "a"}, {"place": "b"}]}]}`, "initial marking 2 on input a, inputs of multi-input transitions hold one token at most"},
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": 1, "inputs": [{"place": "a"}],
+			"inhibitors": [{"place": "b"}]}]}`, "unknown place b"},
+		{`{"places": [{"name": "a"}], "transitions": [{"name": "t", "duration": 1, "inputs": [{"place": "a"}],
+			"resets": ["a", "a"]}]}`, "duplicated reset arc with a"},
+	} {
+		if _, err := Decode(strings.NewReader(tc.net)); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("error %v, expected %q", err, tc.err)
+		}
+	}
+}
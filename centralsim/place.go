@@ -0,0 +1,88 @@
+package centralsim
+
+// Lugar es un lugar de la red cuyo marcado se mantiene porque tiene arcos
+// inhibidores o de reinicio, o porque sus marcas tienen color. Pertenece a la subred de las transiciones que
+// lo consumen, lo vacían o son inhibidas por él.
+type Lugar struct {
+	IiIdGlobal int       `json:"ii_idglobal"`
+	IiMarcas   TypeConst `json:"ii_marcas"`
+	// transiciones que tienen el lugar como entrada; al vaciarlo aumenta
+	// su función de sensibilización en las marcas retiradas
+	Consumidores []IndLocalTrans `json:"ii_consumidores,omitempty"`
+	// fichas de un lugar coloreado, ordenadas; vacío si sus marcas no tienen valor
+	IaFichas []Ficha `json:"ia_fichas,omitempty"`
+}
+
+// findPlace devuelve la posición del lugar en IaLugares, -1 si no está
+func (l Lefs) findPlace(id int) int {
+	for i, p := range l.IaLugares {
+		if p.IiIdGlobal == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// inhibida indica si algún arco inhibidor impide disparar la transición
+func (l Lefs) inhibida(t Transition) bool {
+	for _, inh := range t.Inhibidores {
+		if i := l.findPlace(inh[0]); i >= 0 && l.IaLugares[i].IiMarcas >= TypeConst(inh[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+cambiaMarcas suma delta a las marcas del lugar id en el instante aiTiempo.
+Las transiciones que dejan de estar inhibidas pasan a considerarse en ese
+instante; si inmediato, el cambio ocurre al disparar en el reloj actual y
+las que quedan sensibilizadas se añaden a la pila para disparar en este paso.
+*/
+func (l *Lefs) cambiaMarcas(id int, delta TypeConst, aiTiempo TypeClock, inmediato bool) {
+	i := l.findPlace(id)
+	if i < 0 || delta == 0 {
+		return
+	}
+	inhibidas := make([]bool, len(l.IaRed))
+	for t, tr := range l.IaRed {
+		inhibidas[t] = l.inhibida(tr)
+	}
+	l.IaLugares[i].IiMarcas += delta
+	for t := range l.IaRed {
+		tr := &l.IaRed[t]
+		if !inhibidas[t] || l.inhibida(*tr) {
+			continue
+		}
+		tr.actualizaTiempo(aiTiempo)
+		if inmediato && l.disparable(*tr, aiTiempo) {
+			l.IsTransSensib.push(IndLocalTrans(t))
+		}
+	}
+}
+
+// vaciaLugar retira todas las marcas del lugar id en el reloj actual y
+// devuelve cuántas había
+func (l *Lefs) vaciaLugar(id int, aiTiempo TypeClock) TypeConst {
+	i := l.findPlace(id)
+	if i < 0 {
+		return 0
+	}
+	marcas := l.IaLugares[i].IiMarcas
+	l.IaLugares[i].IaFichas = nil
+	for _, c := range l.IaLugares[i].Consumidores {
+		if t := l.IaRed.findIndex(c); t >= 0 {
+			l.IaRed[t].updateFuncValue(marcas)
+		}
+	}
+	l.cambiaMarcas(id, -marcas, aiTiempo, true)
+	return marcas
+}
+
+// sensibilizada indica si la transición puede disparar en el reloj actual;
+// un disparo anterior en el mismo instante puede haberla desactivado,
+// ocupado su último servidor o consumido las fichas que necesitaba
+func (l Lefs) sensibilizada(t IndLocalTrans) bool {
+	tr := l.IaRed[t]
+	return tr.IiValorLef <= 0 && !l.inhibida(tr) && tr.servidorLibre() && l.ligable(tr)
+}
@@ -0,0 +1,75 @@
+package centralsim
+
+// Políticas de memoria de las transiciones temporizadas que se pueden
+// interrumpir
+const (
+	MemoriaSensibilizacion = "enabling" // al interrumpirse se pierde el tiempo transcurrido
+	MemoriaEdad            = "age"      // al volver a sensibilizarse continúa donde se interrumpió
+)
+
+// ValidMemoryPolicy indica si la política de memoria es conocida; vacía
+// indica que el disparo no se puede interrumpir
+func ValidMemoryPolicy(policy string) bool {
+	switch policy {
+	case "", MemoriaSensibilizacion, MemoriaEdad:
+		return true
+	}
+	return false
+}
+
+/*
+Las transiciones con política de memoria compiten por sus marcas durante la
+duración del disparo: no las consumen al empezar sino al completarse, y si
+antes otro disparo o un arco inhibidor las desactiva el disparo se
+interrumpe, sin haber generado eventos que cancelar. Se planifican igual que
+las que tienen intervalo, con la duración como retardo, y anotan el disparo
+y producen sus marcas en el instante en que se completan.
+*/
+func (t Transition) conMemoria() bool {
+	return t.IiMemoria != ""
+}
+
+// planificable indica si el instante de disparo se elige al sensibilizarse
+func (t Transition) planificable() bool {
+	return len(t.IiIntervalo) > 0 || t.conMemoria()
+}
+
+// duracionEventos es el tiempo desde el disparo hasta los eventos que genera
+func (t Transition) duracionEventos() TypeClock {
+	if t.conMemoria() {
+		return 0
+	}
+	return t.IiDuracionDisparo
+}
+
+// retardoMemoria devuelve lo que falta para completar el disparo: con memoria
+// de edad se descuenta el tiempo sensibilizada antes de interrumpirse
+func (t Transition) retardoMemoria() TypeClock {
+	return t.IiDuracionDisparo - t.iiTranscurrido
+}
+
+// hayConMemoria indica si la subred tiene transiciones que se pueden interrumpir
+func (l Lefs) hayConMemoria() bool {
+	for _, t := range l.IaRed {
+		if t.conMemoria() {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+interrumpe olvida el disparo planificado al desactivarse en el instante dado;
+con memoria de edad acumula el tiempo que estuvo sensibilizada. Si pierde la
+carrera en el mismo instante en que se completaba le queda una unidad de
+tiempo, de modo que MinDuration sigue siendo una cota para el LookAhead.
+*/
+func (t *Transition) interrumpe(aiRelojLocal TypeClock) {
+	if t.planificada && t.IiMemoria == MemoriaEdad {
+		t.iiTranscurrido += aiRelojLocal - t.iiInicio
+		if t.iiTranscurrido >= t.IiDuracionDisparo {
+			t.iiTranscurrido = t.IiDuracionDisparo - 1
+		}
+	}
+	t.planificada = false
+}
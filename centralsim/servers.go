@@ -0,0 +1,65 @@
+package centralsim
+
+// ServidoresInfinitos indica que una transición puede tener tantos disparos
+// en curso como su grado de sensibilización
+const ServidoresInfinitos = -1
+
+/*
+conServidores indica si la transición limita sus disparos en curso: solo las
+temporizadas sin intervalo ni memoria, ya que las inmediatas terminan el
+disparo en el mismo instante y las planificadas se planifican de nuevo al
+disparar.
+*/
+func (t Transition) conServidores() bool {
+	return t.IiDuracionDisparo > 0 && !t.planificable()
+}
+
+// servidores devuelve cuántos disparos puede tener en curso la transición;
+// 0 equivale a un único servidor
+func (t Transition) servidores() int {
+	if t.IiServidores == 0 {
+		return 1
+	}
+	return t.IiServidores
+}
+
+// servidorLibre indica si la transición puede empezar otro disparo
+func (t Transition) servidorLibre() bool {
+	return !t.conServidores() || t.servidores() == ServidoresInfinitos || t.IiVecesDisparada < t.servidores()
+}
+
+/*
+iniciaDisparo ocupa un servidor de la transición hasta que termina el disparo
+y añade un evento sin constante para ese instante: al tratarlo la transición
+vuelve a tener el tiempo del reloj y dispara de nuevo si sigue sensibilizada.
+Se llama con el mutex bloqueado.
+*/
+func (se *SimulationEngine) iniciaDisparo(ilTr IndLocalTrans) {
+	t := &se.ilMislefs.IaRed[ilTr]
+	if !t.conServidores() {
+		return
+	}
+	fin := t.IiTiempo + t.IiDuracionDisparo
+	t.finDisparos = append(t.finDisparos, fin)
+	t.IiVecesDisparada++
+	se.IlEventos.inserta(Event{IiTiempo: fin, IiTransicion: t.IiIndLocal})
+}
+
+// terminaDisparos libera los servidores de los disparos que han terminado en
+// el instante dado. Se llama con el mutex bloqueado.
+func (se *SimulationEngine) terminaDisparos(aiRelojLocal TypeClock) {
+	for i := range se.ilMislefs.IaRed {
+		t := &se.ilMislefs.IaRed[i]
+		if len(t.finDisparos) == 0 && t.IiVecesDisparada == 0 {
+			continue
+		}
+		enCurso := t.finDisparos[:0]
+		for _, fin := range t.finDisparos {
+			if fin > aiRelojLocal {
+				enCurso = append(enCurso, fin)
+			}
+		}
+		t.finDisparos = enCurso
+		t.IiVecesDisparada = len(enCurso)
+	}
+}
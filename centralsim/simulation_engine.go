@@ -31,16 +31,40 @@ type SimulationEngine struct {
 	ivTransResults        []ResultadoTransition // slice dinamico con los resultados
 	EventNumber           float64               // cantidad de eventos ejecutados
 	Log                   *Logger
-	lookAheads            map[int]TypeClock // usado para saber los lookAheads de los procesos precedentes
-	maxLookAhead          TypeClock         // se usa para dar lookAhead cuando no hay transiciones sensibilizadas
+	lookAheads            map[int]TypeClock     // usado para saber los lookAheads de los procesos precedentes
+	maxLookAhead          TypeClock             // se usa para dar lookAhead cuando no hay transiciones sensibilizadas
+	outLinks              map[int]OutboundLink  // lookAhead por cada proceso posterior
+	outProcess            map[IndLocalTrans]int // proceso destino de cada transición remota
 	sendEventCh           chan Event
 	incomEventsCh         chan IncommingEvent // Recibe eventos de otros procesos
+	incomEventsAckCh      chan bool           // Confirma que el evento recibido ya está en la lista
 	reqLookAheadCh        chan LookAhead      // Canal para solicitar lookAhead al proceso indicado
 	receiveLookAheadCh    chan LookAhead      // Canal para recibir LookAhead de proceso precedente
 	receiveLookAheadReqCh chan LookAhead      // Recibe solicitud de LookAhead de proceso posterior
 	sendLookAheadCh       chan LookAhead      // Envía LookAhead propio a proceso posterior
 	isWaitingEvent        bool
 	waitForEvent          chan bool
+	snapshotCh            chan snapshotRequest  // Solicitudes de instantánea, ordenadas con los eventos entrantes
+	causality             *CausalityChecker     // Comprobación de la causalidad local, nil si está desactivada
+	blockedEvent          time.Duration         // Tiempo total esperando eventos
+	blockedLookAhead      map[int]time.Duration // Tiempo total esperando el LookAhead de cada proceso
+	lookAheadRequests     map[int]int           // Solicitudes de LookAhead a cada proceso
+	clockAdvances         int                   // Avances del reloj local
+	nullAdvances          int                   // Avances del reloj por LookAhead, sin evento
+	simulating            time.Duration         // Tiempo real de los periodos ya simulados
+	simulatingSince       time.Time             // Inicio del periodo en curso, cero si no se simula
+	state                 string                // StateIdle, StateRunning, ...
+	waitingFor            int                   // proceso cuyo LookAhead se espera, -1 si ninguno
+	places                *markingRecorder      // Registro del marcado de los lugares, nil si no se observan
+	deferred              bool                  // quedan transiciones de menor nivel por disparar en este instante
+	vanishing             vanishingDetector     // detección de bucles de transiciones inmediatas
+	err                   error                 // error que detuvo la simulación
+	finalClock            TypeClock             // ciclo en que termina el periodo que se simula
+	finished              map[int]bool          // procesos precedentes que han terminado su simulación
+	activity              Activity              // rutinas del proceso con trabajo
+	done                  chan struct{}         // se cierra al detener el motor
+	stopOnce              sync.Once             // Stop cierra done una sola vez
+	routines              sync.WaitGroup        // rutinas que atienden a otros procesos
 	mux                   sync.Mutex
 }
 
@@ -50,12 +74,14 @@ func MakeSimulationEngine(
 	logger *Logger,
 	sendEv chan Event,
 	incomingEvent chan IncommingEvent,
+	incomingEventAck chan bool,
 	requestLookAhead chan LookAhead,
 	receiveLACh chan LookAhead,
 	receiveLAReqCh chan LookAhead,
 	sendLookAheadCh chan LookAhead,
 	lookAheads map[int]TypeClock,
 	maxLookAhead TypeClock,
+	outLinks map[int]OutboundLink,
 ) *SimulationEngine {
 
 	m := SimulationEngine{}
@@ -68,18 +94,34 @@ func MakeSimulationEngine(
 	m.Log = logger
 	m.sendEventCh = sendEv
 	m.incomEventsCh = incomingEvent
+	m.incomEventsAckCh = incomingEventAck
 	m.reqLookAheadCh = requestLookAhead
 	m.receiveLookAheadCh = receiveLACh
 	m.receiveLookAheadReqCh = receiveLAReqCh
 	m.sendLookAheadCh = sendLookAheadCh
 	m.lookAheads = lookAheads
 	m.maxLookAhead = maxLookAhead
+	m.outLinks = outLinks
+	m.outProcess = make(map[IndLocalTrans]int)
+	for p, link := range outLinks {
+		for _, t := range link.Targets {
+			m.outProcess[t] = p
+		}
+	}
 	m.isWaitingEvent = false
 	m.waitForEvent = make(chan bool)
+	m.snapshotCh = make(chan snapshotRequest)
+	m.blockedLookAhead = make(map[int]time.Duration)
+	m.lookAheadRequests = make(map[int]int)
+	m.finished = make(map[int]bool)
+	m.state, m.waitingFor = StateIdle, -1
+	m.activity = nopActivity{}
+	m.done = make(chan struct{})
 	m.mux = sync.Mutex{}
 
-	m.Log.NoFmtLog.Println("Motor de simulación creado")
+	m.Log.Info("Motor de simulación creado", "transitions", len(alLaLef.IaRed))
 
+	m.routines.Add(2)
 	go m.manageIncommingEvents()
 	go m.CalculateLookAhead()
 
@@ -89,27 +131,55 @@ func MakeSimulationEngine(
 // disparar una transicion. Esto es, generar todos los eventos
 //	   ocurridos por el disparo de una transicion
 //   RECIBE: Indice en el vector de la transicion a disparar
-func (se *SimulationEngine) dispararTransicion(ilTr IndLocalTrans) {
-	// Prepare 5 local variables
-	trList := se.ilMislefs.IaRed              // transition list
-	timeTrans := trList[ilTr].IiTiempo        // time to spread to new events
-	timeDur := trList[ilTr].IiDuracionDisparo // firing time length
-	listIul := trList[ilTr].TransConstIul     // Iul list of pairs Trans, Ctes
-	listPul := trList[ilTr].TransConstPul     // Pul list of pairs Trans, Ctes
+//   DEVUELVE: Marcas retiradas de cada lugar que vacía la transición
+func (se *SimulationEngine) dispararTransicion(ilTr IndLocalTrans) map[int]TypeConst {
+	// Prepare 6 local variables
+	trList := se.ilMislefs.IaRed                // transition list
+	timeTrans := trList[ilTr].IiTiempo          // time to spread to new events
+	timeDur := trList[ilTr].duracionEventos()   // firing time length
+	listIul := trList[ilTr].TransConstIul       // Iul list of pairs Trans, Ctes
+	listPul := trList[ilTr].TransConstPul       // Pul list of pairs Trans, Ctes
+	ligadura := se.ilMislefs.liga(trList[ilTr]) // colored tokens, nil if not colored
 
 	// First apply Iul propagations (Inmediate : 0 propagation time)
 	for _, trCo := range listIul {
 		localIndex := se.ilMislefs.IaRed.findIndex(IndLocalTrans(trCo[0])) // Encontrar id de transición
 		trList[localIndex].updateFuncValue(TypeConst(trCo[1]))
 	}
+	// Marcas consumidas de los lugares que se mantienen y lugares que se vacían
+	for _, plCo := range trList[ilTr].LugaresIul {
+		if ligadura != nil {
+			se.ilMislefs.quitaFichas(plCo[0], ligadura.Consume[plCo[0]])
+		}
+		se.ilMislefs.cambiaMarcas(plCo[0], TypeConst(plCo[1]), timeTrans, true)
+	}
+	var reset map[int]TypeConst
+	for _, pl := range trList[ilTr].Reinicios {
+		if reset == nil {
+			reset = make(map[int]TypeConst)
+		}
+		reset[pl] = se.ilMislefs.vaciaLugar(pl, timeTrans)
+	}
 
 	// Generamos eventos ocurridos por disparo de transicion ilTr
 	for _, trCo := range listPul {
 		// tiempo = tiempo de la transicion + coste disparo
-		se.IlEventos.inserta(Event{timeTrans + timeDur,
-			IndLocalTrans(trCo[0]),
-			TypeConst(trCo[1])})
+		se.IlEventos.inserta(Event{IiTiempo: timeTrans + timeDur,
+			IiTransicion: IndLocalTrans(trCo[0]),
+			IiCte:        TypeConst(trCo[1])})
+	}
+	for _, trPlCo := range trList[ilTr].LugaresPul {
+		var fichas []Ficha
+		if ligadura != nil {
+			fichas = ligadura.Produce[trPlCo[1]]
+		}
+		se.IlEventos.inserta(Event{IiTiempo: timeTrans + timeDur,
+			IiTransicion: IndLocalTrans(trPlCo[0]),
+			IiCte:        TypeConst(trPlCo[2]),
+			IiLugar:      trPlCo[1] + 1,
+			IaFichas:     fichas})
 	}
+	return reset
 }
 
 /* fireEnabledTransitions dispara todas las transiciones sensibilizadas
@@ -118,15 +188,42 @@ func (se *SimulationEngine) dispararTransicion(ilTr IndLocalTrans) {
 		transicion disparada. Igualmente anotara en resultados el disparo de
 		cada transicion para el reloj actual dado
 */
-func (se *SimulationEngine) fireEnabledTransitions(aiLocalClock TypeClock) {
+func (se *SimulationEngine) fireEnabledTransitions(aiLocalClock TypeClock) int {
+	fired := 0
+	// solo disparan las de mayor nivel; las demás siguen sensibilizadas en este instante
+	nivel := se.ilMislefs.nivelMaximo()
+	se.deferred = false
 	for se.ilMislefs.haySensibilizadas() { //while
 		liCodTrans := se.ilMislefs.getSensibilizada()
-		se.dispararTransicion(liCodTrans)
-		se.Log.GoVectLog(fmt.Sprintf("Dispara transición %v", liCodTrans))
+		if !se.ilMislefs.sensibilizada(liCodTrans) { // otro disparo de este instante la desactivó
+			continue
+		}
+		if se.ilMislefs.IaRed[liCodTrans].nivel() < nivel {
+			se.deferred = true
+			continue
+		}
+		fired++
+		reset := se.dispararTransicion(liCodTrans)
+		se.ilMislefs.IaRed[liCodTrans].planificada = false
+		se.ilMislefs.IaRed[liCodTrans].iiTranscurrido = 0
+		se.iniciaDisparo(liCodTrans)
+		if se.places != nil {
+			tr := se.ilMislefs.IaRed[liCodTrans]
+			se.places.fire(tr.IiIndLocal, aiLocalClock, tr.duracionEventos(), reset)
+		}
+		se.Log.GoVectLog("Dispara transición %v", liCodTrans)
+		se.Log.Debug("Dispara transición", "transition", se.ilMislefs.IaRed[liCodTrans].IiIndLocal, "clock", aiLocalClock)
+		se.Log.Tracer.Instant(TraceEngine, "Dispara transición", aiLocalClock, "transition", se.ilMislefs.IaRed[liCodTrans].IiIndLocal)
 
 		// Anotar el Resultado que disparo la liCodTrans en tiempoaiLocalClock
 		se.ivTransResults = append(se.ivTransResults, ResultadoTransition{se.ilMislefs.IaRed[liCodTrans].IiIndLocal, aiLocalClock})
+
+		// con varios servidores dispara tantas veces como su grado de sensibilización
+		if tr := se.ilMislefs.IaRed[liCodTrans]; tr.conServidores() && se.ilMislefs.disparable(tr, aiLocalClock) {
+			se.ilMislefs.agnadeSensibilizada(liCodTrans)
+		}
 	}
+	return fired
 }
 
 // tratarEventos : Accede a lista eventos y trata todos con tiempo aiTiempo
@@ -134,7 +231,7 @@ func (se *SimulationEngine) tratarEventos() {
 	var leEvento Event
 	aiTiempo := se.iiRelojlocal
 
-	se.Log.Event.Println("Tratar Eventos", se.IlEventos)
+	se.Log.Trace("Tratar eventos", "clock", aiTiempo, "events", se.IlEventos)
 	for se.IlEventos.hayEventos(aiTiempo) {
 		leEvento = se.IlEventos.popPrimerEvento() // extraer evento más reciente
 
@@ -142,14 +239,21 @@ func (se *SimulationEngine) tratarEventos() {
 		trList := se.ilMislefs.IaRed  // obtener lista de transiciones de Lefs
 
 		if idTr < 0 { // Enviar evento a la transición correspondiente
-			se.sendEventCh <- leEvento
+			se.activity.Add(1) // el emisor envía el evento
+			select {
+			case se.sendEventCh <- leEvento:
+			case <-se.done:
+			}
+		} else if leEvento.IiLugar != 0 { // Marcas de un lugar que se mantiene
+			se.ilMislefs.agnadeFichas(leEvento.IiLugar-1, leEvento.IaFichas)
+			se.ilMislefs.cambiaMarcas(leEvento.IiLugar-1, leEvento.IiCte, leEvento.IiTiempo, false)
 		} else {
 			idTr := trList.findIndex(idTr) // Encontrar el índice
 			// Establecer nuevo valor de la funcion
 			trList[idTr].updateFuncValue(leEvento.IiCte)
 			// Establecer nuevo valor del tiempo
 			trList[idTr].actualizaTiempo(leEvento.IiTiempo)
-			se.Log.GoVectLog(fmt.Sprintf("Evento local %v", se.EventNumber))
+			se.Log.GoVectLog("Evento local %v", se.EventNumber)
 		}
 
 		se.EventNumber++
@@ -166,7 +270,10 @@ func (se *SimulationEngine) avanzarTiempo() TypeClock {
 			nextTime = l
 		}
 	}
-	se.Log.Clock.Println("NEXT CLOCK...... : ", nextTime)
+	if nextTime < se.iiRelojlocal { // el reloj no retrocede
+		nextTime = se.iiRelojlocal
+	}
+	se.Log.Trace("Siguiente tiempo", "clock", nextTime)
 	return nextTime
 }
 
@@ -191,44 +298,105 @@ func (se *SimulationEngine) devolverResultados() {
 	fmt.Println(resultados)
 }
 
-// SimularUnpaso de una RdP con duración disparo >= 1
+// SimularUnpaso de una RdP; las transiciones inmediatas, de duración 0,
+// disparan en pasos sucesivos del mismo instante, igual que las que tienen
+// intervalo y siguen sensibilizadas con retardo 0
 func (se *SimulationEngine) simularUnpaso() {
+	stepStart, stepClock := time.Now(), se.iiRelojlocal
+	se.mux.Lock()
+	se.terminaDisparos(se.iiRelojlocal)
+	se.planificaDisparos(se.iiRelojlocal)
+	se.mux.Unlock()
+	se.waitForCurrentEvents()
 	se.ilMislefs.actualizaSensibilizadas(se.iiRelojlocal)
 	// Si no hay transiciones sensibilizadas ni eventos por procesar, espera evento
 	if !se.ilMislefs.haySensibilizadas() && se.IlEventos.ListaEventosVacia() {
-		// Espera evento
-		se.Log.NoFmtLog.Println("ESPERA EVENTO")
+		// Espera evento, salvo que ya no pueda llegar ninguno en el periodo
+		se.mux.Lock()
+		if se.nothingToWaitFor() {
+			if se.finalClock > se.iiRelojlocal {
+				se.avanzarReloj(se.finalClock, true)
+			}
+			se.mux.Unlock()
+			return
+		}
+		se.Log.Debug("Espera evento", "clock", se.iiRelojlocal)
 		se.isWaitingEvent = true
-		<-se.waitForEvent
+		se.state = StateWaitingEvent
+		se.mux.Unlock()
+		start := time.Now()
+		se.activity.Add(-1)
+		select {
+		case <-se.waitForEvent:
+		case <-se.done:
+			return
+		}
+		se.addBlocked(&se.blockedEvent, start)
+		se.setState(StateRunning, -1)
+		se.Log.Tracer.Span(TraceEngine, "Espera evento", start, se.iiRelojlocal)
+		se.mux.Lock()
+		noEvent := se.IlEventos.ListaEventosVacia() // lo despertó el fin del periodo, no un evento
+		se.mux.Unlock()
+		if noEvent {
+			return
+		}
 	}
 	// si los eventos son de tiempo menor a los lookahead, los procesa, si no, pide lookahead
-	for i, l := range se.lookAheads {
-		se.Log.Mark.Println(fmt.Sprintf("LOOKAHEAD P%v ACTUAL: %v", i, l))
+	for _, i := range se.ancestors() {
+		l := se.lookAheads[i]
+		se.Log.Trace("LookAhead actual", "process", i, "lookahead", l)
 		if l < se.IlEventos.tiempoPrimerEvento() {
-			se.getLookAhead(i)
+			start := time.Now()
+			se.setState(StateWaitingLookAhead, i)
+			if !se.getLookAhead(i) {
+				return
+			}
+			se.addBlockedLookAhead(i, start)
+			se.setState(StateRunning, -1)
 		}
 	}
 	se.mux.Lock()
 
-	se.Log.NoFmtLog.Println("-----------Stack de transiciones sensibilizadas---------")
 	se.ilMislefs.IsTransSensib.ImprimeTransStack(se.Log)
-	se.Log.NoFmtLog.Println("-----------Final Stack de transiciones---------")
 
 	// Fire enabled transitions and produce events
-	se.fireEnabledTransitions(se.iiRelojlocal)
+	fired := se.fireEnabledTransitions(se.iiRelojlocal)
+	se.planificaDisparos(se.iiRelojlocal)
 
-	se.Log.NoFmtLog.Println("-----------Lista eventos después de disparos---------")
 	se.IlEventos.Imprime(se.Log)
-	se.Log.NoFmtLog.Println("-----------Final lista eventos---------")
 
-	if !se.IlEventos.ListaEventosVacia() {
+	if se.deferred || se.ilMislefs.hayInmediatasEn(se.iiRelojlocal) || se.ilMislefs.hayPlanificadasEn(se.iiRelojlocal) {
+		// el marcado es evanescente: el reloj no avanza hasta disparar las restantes
+		se.Log.Debug("Marcado evanescente", "clock", se.iiRelojlocal)
+	} else if !se.IlEventos.ListaEventosVacia() {
 		// Cuando hay eventos futuros
-		se.iiRelojlocal = se.avanzarTiempo()
-		se.Log.Clock.Println("Avanza el tiempo -> ", se.iiRelojlocal)
-		se.Log.GoVectLog(fmt.Sprintf("Avanza el tiempo -> %v", se.iiRelojlocal))
+		next := se.avanzarTiempo()
+		se.avanzarReloj(next, next < se.IlEventos.tiempoPrimerEvento())
+		se.Log.GoVectLog("Avanza el tiempo -> %v", se.iiRelojlocal)
 	}
 	se.tratarEventos()
+	se.terminaDisparos(se.iiRelojlocal)
+	se.planificaDisparos(se.iiRelojlocal)
+	se.checkVanishing(stepClock, fired)
 	se.mux.Unlock()
+	se.Log.Tracer.Span(TraceEngine, "Paso", stepStart, stepClock, "next", se.iiRelojlocal)
+}
+
+// Cambia el reloj local, que no debe retroceder. El avance es nulo si lo
+// permite un LookAhead sin que haya eventos. Se llama con el mutex bloqueado.
+func (se *SimulationEngine) avanzarReloj(clock TypeClock, null bool) {
+	if clock > se.iiRelojlocal {
+		se.clockAdvances++
+		if null {
+			se.nullAdvances++
+		}
+	}
+	se.iiRelojlocal = clock
+	if se.places != nil {
+		se.places.advance(clock)
+	}
+	se.Log.Debug("Avanza el tiempo", "clock", se.iiRelojlocal)
+	se.checkClock()
 }
 
 // SimularPeriodo de una RdP
@@ -241,16 +409,27 @@ func (se *SimulationEngine) SimularPeriodo(CicloInicial, CicloFinal TypeClock) {
 	// Inicializamos el reloj local
 	// ------------------------------------------------------------------
 	se.iiRelojlocal = CicloInicial
+	se.mux.Lock()
+	se.finalClock = CicloFinal
+	se.checkClock()
+	se.simulatingSince = ldIni
+	se.state = StateRunning
+	se.mux.Unlock()
 
-	for se.iiRelojlocal < CicloFinal {
+	for se.iiRelojlocal < CicloFinal && se.Err() == nil && !se.stopped() {
 		///*		//DEPURACION
-		se.Log.NoFmtLog.Println("RELOJ LOCAL !!!  = ", se.iiRelojlocal)
+		se.Log.Trace("Reloj local", "clock", se.iiRelojlocal)
 		// se.ilMislefs.ImprimeLefs(se.Log)
 		//*/
 		se.simularUnpaso()
 	}
 
 	elapsedTime := time.Since(ldIni)
+	se.mux.Lock()
+	se.simulating += elapsedTime
+	se.simulatingSince = time.Time{}
+	se.state = StateFinished
+	se.mux.Unlock()
 
 	fmt.Printf("Eventos por segundo = %f",
 		se.EventNumber/elapsedTime.Seconds())
@@ -0,0 +1,248 @@
+package centralsim
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// EngineStats es el estado del motor que se publica como métricas
+type EngineStats struct {
+	Clock            TypeClock     // reloj local
+	EventsProcessed  float64       // eventos tratados
+	TransitionsFired int           // transiciones disparadas
+	EventListLength  int           // eventos pendientes en la lista
+	BlockedEvent     time.Duration // tiempo esperando eventos de otros procesos
+	BlockedLookAhead time.Duration // tiempo esperando LookAheads de los procesos precedentes
+}
+
+// Stats devuelve el estado actual del motor
+func (se *SimulationEngine) Stats() EngineStats {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	stats := EngineStats{
+		Clock:            se.iiRelojlocal,
+		EventsProcessed:  se.EventNumber,
+		TransitionsFired: len(se.ivTransResults),
+		EventListLength:  len(se.IlEventos),
+		BlockedEvent:     se.blockedEvent,
+	}
+	for _, d := range se.blockedLookAhead {
+		stats.BlockedLookAhead += d
+	}
+	return stats
+}
+
+// Estados del motor que muestra el panel de control
+const (
+	StateIdle             = "idle"              // aún no simula
+	StateRunning          = "running"           // tratando eventos y disparando transiciones
+	StateWaitingEvent     = "waiting-event"     // sin eventos ni transiciones sensibilizadas
+	StateWaitingLookAhead = "waiting-lookahead" // esperando el LookAhead de un proceso precedente
+	StateFinished         = "finished"          // alcanzó el ciclo final
+)
+
+// Eventos y disparos que incluye el estado del motor
+const statusListLength = 5
+
+// EngineStatus es el estado del motor para seguir una simulación en marcha
+type EngineStatus struct {
+	Clock            TypeClock
+	State            string
+	WaitingFor       int                   // proceso cuyo LookAhead se espera, -1 si ninguno
+	LookAheads       map[int]TypeClock     // último LookAhead de cada proceso precedente
+	PendingEvents    int                   // eventos en la lista
+	NextEvents       []Event               // primeros eventos de la lista
+	TransitionsFired int                   // transiciones disparadas
+	LastFired        []ResultadoTransition // últimos disparos, el más reciente al final
+}
+
+// Status devuelve el estado actual del motor
+func (se *SimulationEngine) Status() EngineStatus {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	status := EngineStatus{
+		Clock:            se.iiRelojlocal,
+		State:            se.state,
+		WaitingFor:       se.waitingFor,
+		LookAheads:       make(map[int]TypeClock),
+		PendingEvents:    len(se.IlEventos),
+		TransitionsFired: len(se.ivTransResults),
+	}
+	for i, l := range se.lookAheads {
+		status.LookAheads[i] = l
+	}
+	next := len(se.IlEventos)
+	if next > statusListLength {
+		next = statusListLength
+	}
+	status.NextEvents = append([]Event{}, se.IlEventos[:next]...)
+	last := len(se.ivTransResults) - statusListLength
+	if last < 0 {
+		last = 0
+	}
+	status.LastFired = append([]ResultadoTransition{}, se.ivTransResults[last:]...)
+	return status
+}
+
+// Cambia el estado del motor, con el proceso cuyo LookAhead se espera
+func (se *SimulationEngine) setState(state string, waitingFor int) {
+	se.mux.Lock()
+	se.state, se.waitingFor = state, waitingFor
+	se.mux.Unlock()
+}
+
+/*
+Profile reparte el tiempo real de la simulación entre el cálculo y la
+sincronización con los demás procesos: la espera de eventos y la de los
+LookAheads de cada proceso precedente. Un avance nulo es un avance del reloj
+que permite un LookAhead sin que haya un evento en ese tiempo.
+*/
+type Profile struct {
+	Process           int                   // proceso lógico, lo indica quien crea el motor
+	Wall              time.Duration         // tiempo real simulando
+	WaitingEvent      time.Duration         // tiempo esperando eventos
+	WaitingLookAhead  map[int]time.Duration // tiempo esperando el LookAhead de cada proceso
+	LookAheadRequests map[int]int           // solicitudes de LookAhead a cada proceso
+	ClockAdvances     int                   // avances del reloj local
+	NullAdvances      int                   // avances del reloj por LookAhead, sin evento
+	EventsProcessed   float64
+	TransitionsFired  int
+}
+
+// Profile devuelve el perfil de la simulación hasta el momento
+func (se *SimulationEngine) Profile() Profile {
+	se.mux.Lock()
+	defer se.mux.Unlock()
+	p := Profile{
+		Wall:              se.simulating,
+		WaitingEvent:      se.blockedEvent,
+		WaitingLookAhead:  make(map[int]time.Duration),
+		LookAheadRequests: make(map[int]int),
+		ClockAdvances:     se.clockAdvances,
+		NullAdvances:      se.nullAdvances,
+		EventsProcessed:   se.EventNumber,
+		TransitionsFired:  len(se.ivTransResults),
+	}
+	if !se.simulatingSince.IsZero() {
+		p.Wall += time.Since(se.simulatingSince)
+	}
+	for i, d := range se.blockedLookAhead {
+		p.WaitingLookAhead[i] = d
+	}
+	for i, n := range se.lookAheadRequests {
+		p.LookAheadRequests[i] = n
+	}
+	return p
+}
+
+// Synchronization es el tiempo total esperando a otros procesos
+func (p Profile) Synchronization() time.Duration {
+	total := p.WaitingEvent
+	for _, d := range p.WaitingLookAhead {
+		total += d
+	}
+	return total
+}
+
+// Compute es el tiempo dedicado a simular
+func (p Profile) Compute() time.Duration {
+	return p.Wall - p.Synchronization()
+}
+
+// Efficiency es la fracción del tiempo real dedicada a simular
+func (p Profile) Efficiency() float64 {
+	if p.Wall <= 0 {
+		return 0
+	}
+	return float64(p.Compute()) / float64(p.Wall)
+}
+
+// NullAdvanceRatio es la fracción de avances del reloj sin evento
+func (p Profile) NullAdvanceRatio() float64 {
+	if p.ClockAdvances == 0 {
+		return 0
+	}
+	return float64(p.NullAdvances) / float64(p.ClockAdvances)
+}
+
+// Requests es el número total de solicitudes de LookAhead
+func (p Profile) Requests() int {
+	total := 0
+	for _, n := range p.LookAheadRequests {
+		total += n
+	}
+	return total
+}
+
+// AggregateProfiles suma los perfiles de todos los procesos. El tiempo real
+// es la suma de los de cada proceso, así que la eficiencia agregada es la
+// fracción del tiempo de todos los procesos dedicada a simular.
+func AggregateProfiles(profiles []Profile) Profile {
+	total := Profile{Process: -1, WaitingLookAhead: make(map[int]time.Duration), LookAheadRequests: make(map[int]int)}
+	for _, p := range profiles {
+		total.Wall += p.Wall
+		total.WaitingEvent += p.WaitingEvent
+		for i, d := range p.WaitingLookAhead {
+			total.WaitingLookAhead[i] += d
+		}
+		for i, n := range p.LookAheadRequests {
+			total.LookAheadRequests[i] += n
+		}
+		total.ClockAdvances += p.ClockAdvances
+		total.NullAdvances += p.NullAdvances
+		total.EventsProcessed += p.EventsProcessed
+		total.TransitionsFired += p.TransitionsFired
+	}
+	return total
+}
+
+// WriteProfileReport escribe el informe de eficiencia de cada proceso y, si
+// hay varios, el agregado
+func WriteProfileReport(w io.Writer, profiles []Profile) {
+	fmt.Fprintf(w, "%-6v %10v %10v %10v %10v %10v %10v %8v\n",
+		"PL", "real", "cálculo", "eventos", "lookahead", "eficiencia", "peticiones", "nulos")
+	row := func(name string, p Profile) {
+		var lookAhead time.Duration
+		for _, d := range p.WaitingLookAhead {
+			lookAhead += d
+		}
+		fmt.Fprintf(w, "%-6v %10v %10v %10v %10v %9.1f%% %10v %7.1f%%\n", name,
+			p.Wall.Round(time.Millisecond), p.Compute().Round(time.Millisecond),
+			p.WaitingEvent.Round(time.Millisecond), lookAhead.Round(time.Millisecond),
+			100*p.Efficiency(), p.Requests(), 100*p.NullAdvanceRatio())
+	}
+	for _, p := range profiles {
+		row(fmt.Sprint(p.Process), p)
+	}
+	if len(profiles) > 1 {
+		row("total", AggregateProfiles(profiles))
+	}
+
+	for _, p := range profiles {
+		ancestors := make([]int, 0, len(p.WaitingLookAhead))
+		for i := range p.LookAheadRequests {
+			ancestors = append(ancestors, i)
+		}
+		sort.Ints(ancestors)
+		for _, i := range ancestors {
+			fmt.Fprintf(w, "PL%v espera a PL%v: %v en %v peticiones de LookAhead\n",
+				p.Process, i, p.WaitingLookAhead[i].Round(time.Millisecond), p.LookAheadRequests[i])
+		}
+	}
+}
+
+// Suma el tiempo bloqueado desde start. Se llama sin el mutex bloqueado.
+func (se *SimulationEngine) addBlocked(total *time.Duration, start time.Time) {
+	se.mux.Lock()
+	*total += time.Since(start)
+	se.mux.Unlock()
+}
+
+// Suma el tiempo bloqueado esperando el LookAhead de un proceso desde start
+func (se *SimulationEngine) addBlockedLookAhead(process int, start time.Time) {
+	se.mux.Lock()
+	se.blockedLookAhead[process] += time.Since(start)
+	se.mux.Unlock()
+}
@@ -0,0 +1,386 @@
+package centralsim
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hilos de cada proceso en la línea de tiempo
+const (
+	TraceEngine        = 1 // motor de simulación
+	TraceCommunication = 2 // módulo de comunicación
+)
+
+var traceThreadNames = map[int]string{TraceEngine: "motor", TraceCommunication: "comunicación"}
+
+// TraceConfig indica dónde exporta cada proceso la traza de su actividad
+type TraceConfig struct {
+	Dir      string // directorio de <lp>.trace.json en formato Chrome Trace Event; vacío para no escribirlo
+	Endpoint string // colector OTLP/HTTP, p.ej. http://localhost:4318; vacío para no enviar
+	ID       string // identificador de la ejecución, común a todos los procesos en OTLP
+}
+
+// Enabled indica si se exporta la traza a algún destino
+func (c TraceConfig) Enabled() bool {
+	return c.Dir != "" || c.Endpoint != ""
+}
+
+/*
+TraceEvent es un evento en formato Chrome Trace Event: un intervalo (fase X)
+o un instante (fase i) de un hilo del proceso lógico, con los tiempos en
+microsegundos desde 1970 para poder unir las trazas de todos los procesos.
+*/
+type TraceEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat,omitempty"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur,omitempty"`
+	Process   int                    `json:"pid"`
+	Thread    int                    `json:"tid"`
+	Scope     string                 `json:"s,omitempty"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// Destino de los eventos de la traza
+type traceExporter interface {
+	export(e TraceEvent)
+	close() error
+}
+
+/*
+Tracer registra la actividad del proceso (pasos, disparos, envíos,
+solicitudes de LookAhead y bloqueos) con el tiempo simulado y el proceso
+como atributos. Un Tracer nil no registra nada.
+*/
+type Tracer struct {
+	mux       sync.Mutex
+	lp        int
+	exporters []traceExporter
+}
+
+// NewTracer crea la traza del proceso lp; devuelve nil si config no indica ningún destino
+func NewTracer(lp string, config TraceConfig) (*Tracer, error) {
+	if !config.Enabled() {
+		return nil, nil
+	}
+	pid, err := strconv.Atoi(lp)
+	if err != nil {
+		return nil, fmt.Errorf("trace process %q: %w", lp, err)
+	}
+	t := &Tracer{lp: pid}
+	if config.Dir != "" {
+		if err := os.MkdirAll(config.Dir, 0777); err != nil {
+			return nil, fmt.Errorf("create trace directory: %w", err)
+		}
+		file, err := os.Create(filepath.Join(config.Dir, lp+".trace.json"))
+		if err != nil {
+			return nil, fmt.Errorf("create trace file: %w", err)
+		}
+		t.exporters = append(t.exporters, newChromeExporter(file))
+	}
+	if config.Endpoint != "" {
+		t.exporters = append(t.exporters, newOTLPExporter(config.Endpoint, config.ID, pid))
+	}
+
+	// Nombres del proceso y de sus hilos en el visor
+	t.metadata("process_name", 0, "PL"+lp)
+	for _, thread := range []int{TraceEngine, TraceCommunication} {
+		t.metadata("thread_name", thread, traceThreadNames[thread])
+	}
+	return t, nil
+}
+
+func (t *Tracer) metadata(name string, thread int, value string) {
+	for _, e := range t.exporters {
+		if chrome, ok := e.(*chromeExporter); ok {
+			chrome.export(TraceEvent{Name: name, Phase: "M", Process: t.lp, Thread: thread,
+				Args: map[string]interface{}{"name": value}})
+		}
+	}
+}
+
+// Span registra un intervalo del hilo indicado que empezó en start y termina ahora
+func (t *Tracer) Span(thread int, name string, start time.Time, clock TypeClock, keyvals ...interface{}) {
+	if t == nil {
+		return
+	}
+	end := time.Now()
+	t.record(TraceEvent{Name: name, Phase: "X", Timestamp: start.UnixNano() / 1000,
+		Duration: end.Sub(start).Nanoseconds() / 1000, Thread: thread}, clock, keyvals)
+}
+
+// Instant registra un suceso puntual del hilo indicado
+func (t *Tracer) Instant(thread int, name string, clock TypeClock, keyvals ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.record(TraceEvent{Name: name, Phase: "i", Scope: "t", Timestamp: time.Now().UnixNano() / 1000,
+		Thread: thread}, clock, keyvals)
+}
+
+func (t *Tracer) record(e TraceEvent, clock TypeClock, keyvals []interface{}) {
+	e.Process = t.lp
+	e.Category = traceThreadNames[e.Thread]
+	e.Args = map[string]interface{}{"lp": t.lp, "clock": clock}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		e.Args[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, exporter := range t.exporters {
+		exporter.export(e)
+	}
+}
+
+// Close termina la traza y espera a que se escriban y envíen los eventos
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	var first error
+	for _, exporter := range t.exporters {
+		if err := exporter.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	t.exporters = nil
+	return first
+}
+
+// Escribe un array JSON de eventos que se abre en chrome://tracing o Perfetto
+type chromeExporter struct {
+	file   io.WriteCloser
+	out    *bufio.Writer
+	events int
+	err    error
+}
+
+func newChromeExporter(file io.WriteCloser) *chromeExporter {
+	out := bufio.NewWriter(file)
+	out.WriteString("[")
+	return &chromeExporter{file: file, out: out}
+}
+
+func (c *chromeExporter) export(e TraceEvent) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		if c.err == nil {
+			c.err = err
+		}
+		return
+	}
+	if c.events > 0 {
+		c.out.WriteString(",")
+	}
+	c.out.WriteString("\n")
+	c.out.Write(data)
+	c.events++
+}
+
+func (c *chromeExporter) close() error {
+	c.out.WriteString("\n]\n")
+	err := c.out.Flush()
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	if c.err != nil {
+		return c.err
+	}
+	return err
+}
+
+// Eventos que se envían juntos al colector
+const otlpBatchSize = 512
+
+/*
+Envía los eventos como spans OTLP/HTTP con codificación JSON. Los lotes se
+envían en otra rutina para no retrasar la simulación; si el colector no da
+abasto se descartan.
+*/
+type otlpExporter struct {
+	url     string
+	traceID string
+	lp      int
+	spans   int
+	batch   []TraceEvent
+	batches chan []TraceEvent
+	done    chan error
+	dropped int
+}
+
+func newOTLPExporter(endpoint, id string, lp int) *otlpExporter {
+	sum := sha256.Sum256([]byte(id))
+	o := &otlpExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		traceID: hex.EncodeToString(sum[:16]),
+		lp:      lp,
+		batches: make(chan []TraceEvent, 16),
+		done:    make(chan error, 1),
+	}
+	go o.sender()
+	return o
+}
+
+func (o *otlpExporter) export(e TraceEvent) {
+	o.batch = append(o.batch, e)
+	if len(o.batch) >= otlpBatchSize {
+		o.flush()
+	}
+}
+
+func (o *otlpExporter) flush() {
+	if len(o.batch) == 0 {
+		return
+	}
+	select {
+	case o.batches <- o.batch:
+	default:
+		o.dropped += len(o.batch)
+	}
+	o.batch = nil
+}
+
+func (o *otlpExporter) close() error {
+	o.flush()
+	close(o.batches)
+	err := <-o.done
+	if err == nil && o.dropped > 0 {
+		err = fmt.Errorf("otlp: %v spans dropped", o.dropped)
+	}
+	return err
+}
+
+// Rutina que envía los lotes; devuelve en done el primer error
+func (o *otlpExporter) sender() {
+	client := &http.Client{Timeout: 5 * time.Second}
+	var first error
+	for batch := range o.batches {
+		if err := o.post(client, batch); err != nil && first == nil {
+			first = err
+		}
+	}
+	o.done <- first
+}
+
+func (o *otlpExporter) post(client *http.Client, batch []TraceEvent) error {
+	body, err := json.Marshal(o.request(batch))
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(o.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: collector answered %v", resp.Status)
+	}
+	return nil
+}
+
+// Petición ExportTraceServiceRequest con los eventos como spans
+func (o *otlpExporter) request(batch []TraceEvent) map[string]interface{} {
+	spans := make([]interface{}, 0, len(batch))
+	for _, e := range batch {
+		o.spans++
+		start := e.Timestamp * 1000
+		attributes := []interface{}{otlpAttribute("thread.name", traceThreadNames[e.Thread])}
+		for _, key := range sortedKeys(e.Args) {
+			attributes = append(attributes, otlpAttribute("petrisim."+key, e.Args[key]))
+		}
+		spans = append(spans, map[string]interface{}{
+			"traceId":           o.traceID,
+			"spanId":            fmt.Sprintf("%04x%012x", o.lp+1, o.spans),
+			"name":              e.Name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(start, 10),
+			"endTimeUnixNano":   strconv.FormatInt(start+e.Duration*1000, 10),
+			"attributes":        attributes,
+		})
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": []interface{}{
+				otlpAttribute("service.name", "petrisim"),
+				otlpAttribute("service.instance.id", "PL"+strconv.Itoa(o.lp)),
+				otlpAttribute("petrisim.lp", o.lp),
+			}},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "petrisim"},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+func otlpAttribute(key string, value interface{}) map[string]interface{} {
+	var v map[string]interface{}
+	switch n := value.(type) {
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(n)}
+	case TypeClock:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(int64(n), 10)}
+	case IndLocalTrans:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(int64(n), 10)}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+	}
+	return map[string]interface{}{"key": key, "value": v}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MergeTraces une en w las trazas Chrome de varios procesos en un único array
+func MergeTraces(w io.Writer, traces ...io.Reader) error {
+	all := []json.RawMessage{}
+	for _, r := range traces {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		events := []json.RawMessage{}
+		if err := json.Unmarshal(data, &events); err != nil {
+			// la traza de un proceso interrumpido no tiene el cierre del array
+			trimmed := strings.TrimRight(strings.TrimSpace(string(data)), ",")
+			if json.Unmarshal([]byte(trimmed+"]"), &events) != nil {
+				return fmt.Errorf("invalid trace: %w", err)
+			}
+		}
+		all = append(all, events...)
+	}
+	out := bufio.NewWriter(w)
+	out.WriteString("[")
+	for i, e := range all {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n")
+		out.Write(e)
+	}
+	out.WriteString("\n]\n")
+	return out.Flush()
+}
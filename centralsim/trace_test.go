@@ -0,0 +1,86 @@
+package centralsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracerExportsChromeAndOTLP(t *testing.T) {
+	requests := make(chan map[string]interface{}, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+		request := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		requests <- request
+	}))
+	defer collector.Close()
+
+	dir := t.TempDir()
+	logger, err := NewLogger("2", LogConfig{Level: LevelOff, Trace: TraceConfig{Dir: dir, Endpoint: collector.URL, ID: "run"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Tracer.Span(TraceEngine, "Paso", time.Now().Add(-time.Millisecond), 5, "next", TypeClock(7))
+	logger.Tracer.Instant(TraceCommunication, "Recibe Event", 9, "process", 1)
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "2.trace.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []TraceEvent{}
+	if err := json.Unmarshal(data, &events); err != nil {
+		t.Fatalf("invalid chrome trace: %v\n%s", err, data)
+	}
+	// nombres del proceso y de los dos hilos, el paso y la recepción
+	if len(events) != 5 || events[0].Args["name"] != "PL2" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	step, receive := events[3], events[4]
+	if step.Phase != "X" || step.Process != 2 || step.Thread != TraceEngine || step.Duration < 1000 ||
+		step.Args["clock"] != 5.0 || step.Args["next"] != 7.0 {
+		t.Errorf("unexpected step %+v", step)
+	}
+	if receive.Phase != "i" || receive.Thread != TraceCommunication || receive.Args["process"] != 1.0 {
+		t.Errorf("unexpected receive %+v", receive)
+	}
+
+	request := <-requests
+	encoded, _ := json.Marshal(request)
+	for _, want := range []string{`"name":"Paso"`, `"name":"Recibe Event"`, `"key":"petrisim.clock","value":{"intValue":"5"}`,
+		`"key":"petrisim.lp","value":{"intValue":"2"}`, `"spanId":"0003000000000001"`} {
+		if !bytes.Contains(encoded, []byte(want)) {
+			t.Errorf("OTLP request does not contain %v:\n%s", want, encoded)
+		}
+	}
+}
+
+func TestMergeTraces(t *testing.T) {
+	complete := `[
+{"name":"Paso","ph":"X","ts":1,"pid":0,"tid":1}
+]`
+	interrupted := `[
+{"name":"Paso","ph":"X","ts":2,"pid":1,"tid":1},
+{"name":"Envía Event","ph":"X","ts":3,"pid":1,"tid":2}`
+	var out bytes.Buffer
+	if err := MergeTraces(&out, strings.NewReader(complete), strings.NewReader(interrupted)); err != nil {
+		t.Fatal(err)
+	}
+	events := []TraceEvent{}
+	if err := json.Unmarshal(out.Bytes(), &events); err != nil || len(events) != 3 || events[2].Process != 1 {
+		t.Errorf("unexpected merged trace %v: %s", err, out.String())
+	}
+}
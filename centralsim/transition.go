@@ -31,8 +31,32 @@ type Transition struct {
 	IiValorLef TypeConst `json:"ii_valor"`
 	IiTiempo   TypeClock `json:"ii_tiempo"`
 
-	// tiempo que dura el disparo de la transicion
+	// tiempo que dura el disparo de la transicion; 0 si es inmediata
 	IiDuracionDisparo TypeClock `json:"ii_duracion_disparo"`
+	// prioridad entre las transiciones inmediatas sensibilizadas en el mismo
+	// instante: dispara antes la de mayor valor
+	IiPrioridad int `json:"ii_prioridad,omitempty"`
+	// intervalo [a, b] de disparo desde que se sensibiliza, en redes de Petri
+	// temporales; vacío si dispara en cuanto se sensibiliza
+	IiIntervalo []TypeClock `json:"ii_intervalo,omitempty"`
+	// instante de disparo elegido en la sensibilización actual
+	iiDisparo         TypeClock
+	planificada       bool
+	sensibilizaciones int // veces que se ha sensibilizado, para la elección uniforme
+	// política de memoria si el disparo se puede interrumpir: MemoriaSensibilizacion
+	// o MemoriaEdad; vacía si consume las marcas al empezar y siempre termina
+	IiMemoria      string `json:"ii_memoria,omitempty"`
+	iiInicio       TypeClock // instante en que se sensibilizó
+	iiTranscurrido TypeClock // tiempo sensibilizada antes de interrumpirse, con memoria de edad
+	// las fichas que consume y produce dependen de la ligadura que elige la
+	// ColorRule de la Lefs
+	Coloreada bool `json:"ib_coloreada,omitempty"`
+	// disparos en curso que puede tener la transición temporizada: 0 o 1
+	// para un único servidor, k, o ServidoresInfinitos
+	IiServidores int `json:"ii_servidores,omitempty"`
+	// disparos en curso y el instante en que termina cada uno
+	IiVecesDisparada int `json:"ii_vecesdisparada"`
+	finDisparos      []TypeClock
 
 	// vector con parejas :
 	//		transicion junto con cte a actualizarle de forma inmediata
@@ -43,6 +67,18 @@ type Transition struct {
 	// 		tengo que propagar
 	TransConstPul [][2]int `json:"ii_listactes_PUL"`
 
+	// Arcos con lugares cuyo marcado se mantiene en IaLugares de la Lefs:
+	//		parejas lugar / marcas a partir de las que la transición está inhibida
+	Inhibidores [][2]int `json:"ii_inhibidores,omitempty"`
+	//		lugares que vacía al disparar
+	Reinicios []int `json:"ii_reinicios,omitempty"`
+	//		parejas lugar / cte que cambian de forma inmediata
+	LugaresIul [][2]int `json:"ii_lugares_IUL,omitempty"`
+	//		ternas transición / lugar / cte que cambian al terminar el disparo;
+	//		el evento va a la transición, de la misma subred que el lugar, y
+	//		se codifica en negativo si está en otra subred
+	LugaresPul [][3]int `json:"ii_lugares_PUL,omitempty"`
+
 	// Tiempos de LookAhead
 	// cada valor corresponde con las transiciones de la subred
 	TiempoHastaMarca TiempoHasta `json:"iL_tiemposhastamarca"`
@@ -71,20 +107,18 @@ func (t *Transition) updateFuncValue(aiValLef TypeConst) {
 
 // Imprime los atributos de una transicion para depurar errores
 func (t *Transition) Imprime(log *Logger) {
-	log.Tansition.Println("ID GLOBAL: ", t.IiIndLocal)
-	log.NoFmtLog.Println(
-		fmt.Sprintf("VALOR LEF: %v TIEMPO: %v DURACION DISPARO: %v", t.IiValorLef, t.IiTiempo, t.IiDuracionDisparo))
-	log.NoFmtLog.Println(" LISTA DE CTES IUL: ")
-	for _, v := range t.TransConstIul {
-		log.NoFmtLog.Println("\tTRANSICION: ", v[0], "\t\tCTE: ", v[1])
-	}
-	log.NoFmtLog.Println(" LISTA DE CTES PUL: ")
-	for _, v := range t.TransConstPul {
-		log.NoFmtLog.Println("\tTRANSICION: ", v[0], "\t\tCTE: ", v[1])
+	fields := []interface{}{
+		"transition", t.IiIndLocal,
+		"value", t.IiValorLef,
+		"time", t.IiTiempo,
+		"duration", t.IiDuracionDisparo,
+		"iul", t.TransConstIul,
+		"pul", t.TransConstPul,
 	}
 	if t.EsSalida {
-		log.NoFmtLog.Println("\tTIEMPOS LOOKAHEAD: ", t.TiempoHastaMarca.LiTiempos)
+		fields = append(fields, "lookahead_times", t.TiempoHastaMarca.LiTiempos)
 	}
+	log.Trace("Transición", fields...)
 }
 
 // ImprimeValores de la transición
@@ -148,11 +182,5 @@ func (st TransitionStack) isEmpty() bool {
 }
 
 func (st TransitionStack) ImprimeTransStack(log *Logger) {
-	if st.isEmpty() {
-		log.NoFmtLog.Println("\tStack TRANSICIONES VACIA")
-	} else {
-		for _, iTr := range st {
-			log.NoFmtLog.Println("\t\t\t", iTr)
-		}
-	}
+	log.Trace("Transiciones sensibilizadas", "stack", []IndLocalTrans(st))
 }
@@ -0,0 +1,203 @@
+package centralsim
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Relojes vectoriales disponibles para instrumentar la simulación
+const (
+	VClockOff      = "off"      // no se mantiene reloj, los mensajes viajan con un reloj vacío
+	VClockBuiltin  = "builtin"  // reloj vectorial en memoria, sin ficheros
+	VClockGoVector = "govector" // GoVector, con ficheros de log para ShiViz
+)
+
+/*
+VectorClock instrumenta los eventos locales y los mensajes de un proceso.
+Todas las implementaciones usan el mismo formato en la red, el de GoVector:
+msgpack con el identificador del emisor, el mensaje y su reloj vectorial.
+Así se comunican procesos con distinta instrumentación; un reloj vacío
+indica que el emisor no está instrumentado. Si el mensaje implementa
+fmt.Stringer su descripción se añade al evento de envío o recepción.
+*/
+type VectorClock interface {
+	// Registra un evento local
+	LogLocalEvent(msg string)
+	// Codifica el mensaje con el reloj del proceso
+	PrepareSend(msg string, payload interface{}) ([]byte, error)
+	// Decodifica en payload un mensaje recibido y actualiza el reloj
+	UnpackReceive(msg string, buf []byte, payload interface{}) error
+	// Indica si LogLocalEvent usa el mensaje, para no formatearlo en vano
+	Records() bool
+	// Copia del reloj actual, vacía si no se mantiene
+	Clock() map[string]uint64
+}
+
+// NewVectorClock crea el reloj del tipo indicado para el proceso lp. El
+// fichero solo se usa con GoVector, vacío para no escribir el log.
+func NewVectorClock(kind string, lp string, logFile string) (VectorClock, error) {
+	switch kind {
+	case VClockOff, "":
+		return offClock{lp}, nil
+	case VClockBuiltin:
+		return &builtinClock{lp: lp, vc: vclock.New()}, nil
+	case VClockGoVector:
+		config := govec.GetDefaultConfig()
+		config.UseTimestamps = true
+		if logFile == "" {
+			logFile = lp
+			config.LogToFile = false
+		}
+		return &goVectorClock{lp: lp, log: govec.InitGoVector(lp, logFile, config)}, nil
+	}
+	return nil, fmt.Errorf("unknown vector clock %q", kind)
+}
+
+// Codifica un mensaje en el formato de GoVector
+func encodeClockPayload(lp string, clock map[string]uint64, payload interface{}) ([]byte, error) {
+	return msgpack.Marshal(&govec.VClockPayload{Pid: lp, VcMap: clock, Payload: payload})
+}
+
+// Decodifica un mensaje en el formato de GoVector y devuelve el reloj del emisor
+func decodeClockPayload(buf []byte, payload interface{}) (map[string]uint64, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(buf))
+	if _, err := dec.DecodeString(); err != nil {
+		return nil, fmt.Errorf("decode sender: %w", err)
+	}
+	if err := dec.Decode(payload); err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return nil, fmt.Errorf("decode vector clock: %w", err)
+	}
+	clock := make(map[string]uint64, n)
+	for i := 0; i < n; i++ {
+		id, err := dec.DecodeString()
+		if err != nil {
+			return nil, fmt.Errorf("decode vector clock: %w", err)
+		}
+		if clock[id], err = dec.DecodeUint64(); err != nil {
+			return nil, fmt.Errorf("decode vector clock: %w", err)
+		}
+	}
+	return clock, nil
+}
+
+// Sin instrumentación
+type offClock struct {
+	lp string
+}
+
+func (c offClock) LogLocalEvent(msg string) {}
+
+func (c offClock) PrepareSend(msg string, payload interface{}) ([]byte, error) {
+	return encodeClockPayload(c.lp, nil, payload)
+}
+
+func (c offClock) UnpackReceive(msg string, buf []byte, payload interface{}) error {
+	_, err := decodeClockPayload(buf, payload)
+	return err
+}
+
+func (c offClock) Records() bool { return false }
+
+func (c offClock) Clock() map[string]uint64 { return map[string]uint64{} }
+
+// Reloj vectorial en memoria
+type builtinClock struct {
+	lp  string
+	mux sync.Mutex
+	vc  vclock.VClock
+}
+
+func (c *builtinClock) LogLocalEvent(msg string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.vc.Tick(c.lp)
+}
+
+func (c *builtinClock) PrepareSend(msg string, payload interface{}) ([]byte, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.vc.Tick(c.lp)
+	return encodeClockPayload(c.lp, c.vc.GetMap(), payload)
+}
+
+func (c *builtinClock) UnpackReceive(msg string, buf []byte, payload interface{}) error {
+	clock, err := decodeClockPayload(buf, payload)
+	if err != nil {
+		return err
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.vc.Tick(c.lp)
+	c.vc.Merge(clock)
+	return nil
+}
+
+// Los eventos locales avanzan el reloj sin usar el mensaje
+func (c *builtinClock) Records() bool { return false }
+
+func (c *builtinClock) Clock() map[string]uint64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.vc.Copy().GetMap()
+}
+
+// GoVector, que escribe cada evento con su reloj para ShiViz
+type goVectorClock struct {
+	lp  string
+	mux sync.Mutex // GoVector no protege la lectura de su reloj
+	log *govec.GoLog
+}
+
+func (c *goVectorClock) LogLocalEvent(msg string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.log.LogLocalEvent(msg, govec.GetDefaultLogOptions())
+}
+
+func (c *goVectorClock) PrepareSend(msg string, payload interface{}) ([]byte, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	buf := c.log.PrepareSend(describe(msg, payload), payload, govec.GetDefaultLogOptions())
+	if buf == nil {
+		return nil, fmt.Errorf("govector could not encode %v", msg)
+	}
+	return buf, nil
+}
+
+// GoVector no devuelve los errores de decodificación, así que el mensaje se
+// decodifica antes y GoVector solo lo usa para actualizar y registrar el reloj
+func (c *goVectorClock) UnpackReceive(msg string, buf []byte, payload interface{}) error {
+	if _, err := decodeClockPayload(buf, payload); err != nil {
+		return err
+	}
+	var ignored interface{}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.log.UnpackReceive(describe(msg, payload), buf, &ignored, govec.GetDefaultLogOptions())
+	return nil
+}
+
+// Añade al texto del evento la descripción del mensaje
+func describe(msg string, payload interface{}) string {
+	if s, ok := payload.(fmt.Stringer); ok {
+		return msg + " " + s.String()
+	}
+	return msg
+}
+
+func (c *goVectorClock) Records() bool { return true }
+
+func (c *goVectorClock) Clock() map[string]uint64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.log.GetCurrentVC().Copy().GetMap()
+}
@@ -0,0 +1,72 @@
+package centralsim
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+)
+
+type clockTestMessage struct {
+	MsgType string
+	Sender  int
+	Event   Event
+}
+
+// Los mensajes codificados con cualquier reloj se decodifican con los demás
+func TestVectorClocksInteroperate(t *testing.T) {
+	kinds := []string{VClockOff, VClockBuiltin, VClockGoVector}
+	sent := clockTestMessage{MsgType: "Event", Sender: 1, Event: Event{IiTiempo: 7, IiCte: 2, IiTransicion: 3}}
+	for _, from := range kinds {
+		for _, to := range kinds {
+			sender, err := NewVectorClock(from, "1", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			receiver, _ := NewVectorClock(to, "2", "")
+			buf, err := sender.PrepareSend("Send", sent)
+			if err != nil {
+				t.Fatalf("%v: %v", from, err)
+			}
+			var received clockTestMessage
+			if err := receiver.UnpackReceive("Receive", buf, &received); err != nil {
+				t.Fatalf("%v -> %v: %v", from, to, err)
+			}
+			if !reflect.DeepEqual(received, sent) {
+				t.Errorf("%v -> %v: received %+v, expected %+v", from, to, received, sent)
+			}
+			if to != VClockOff && from != VClockOff && receiver.Clock()["1"] != sender.Clock()["1"] {
+				t.Errorf("%v -> %v: clock %v not merged from %v", from, to, receiver.Clock(), sender.Clock())
+			}
+		}
+	}
+}
+
+// El formato es el de GoVector, así que se entienden con procesos que lo usan directamente
+func TestBuiltinClockReadsGoVectorMessages(t *testing.T) {
+	config := govec.GetDefaultConfig()
+	config.LogToFile = false
+	gv := govec.InitGoVector("0", "0", config)
+	gv.LogLocalEvent("Dispara transición 1", govec.GetDefaultLogOptions())
+	buf := gv.PrepareSend("Send", clockTestMessage{MsgType: "LookAhead", Sender: 0}, govec.GetDefaultLogOptions())
+
+	clock, _ := NewVectorClock(VClockBuiltin, "1", "")
+	clock.LogLocalEvent("")
+	var received clockTestMessage
+	if err := clock.UnpackReceive("Receive", buf, &received); err != nil {
+		t.Fatal(err)
+	}
+	if received.MsgType != "LookAhead" {
+		t.Errorf("unexpected message %+v", received)
+	}
+	if vc := clock.Clock(); vc["0"] != 2 || vc["1"] != 2 {
+		t.Errorf("unexpected clock %v", vc)
+	}
+
+	if err := clock.UnpackReceive("Receive", []byte("garbage"), &received); err == nil {
+		t.Error("expected error decoding garbage")
+	}
+	if _, err := NewVectorClock("lamport", "1", ""); err == nil {
+		t.Error("expected error for unknown vector clock")
+	}
+}
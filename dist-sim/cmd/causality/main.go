@@ -0,0 +1,52 @@
+package main
+
+import (
+	"centralsim"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+Checks the local causality of every logic process of a run from their logs:
+no straggler events, a non-decreasing clock and lookahead promises honored
+*/
+func main() {
+	dir := flag.String("dir", "logs", "directorio con los logs <pid>.log de los procesos (nivel debug)")
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		var err error
+		files, err = filepath.Glob(filepath.Join(*dir, "*.log"))
+		if err != nil || len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "no logs in %v\n", *dir)
+			os.Exit(2)
+		}
+	}
+
+	total := 0
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		violations, err := centralsim.CheckLog(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", name, err)
+			os.Exit(2)
+		}
+		for _, v := range violations {
+			fmt.Printf("%v: %v\n", name, v)
+		}
+		total += len(violations)
+	}
+	if total > 0 {
+		fmt.Printf("%v violaciones de causalidad\n", total)
+		os.Exit(1)
+	}
+	fmt.Println("Sin violaciones de causalidad")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"petrisim/dashboard"
+	"petrisim/helpers"
+	"time"
+)
+
+/*
+Shows in the terminal the state of every logic process listed in
+network.json, refreshed in real time, and highlights the process holding
+back global progress. The processes must run with -metrics-port.
+*/
+func main() {
+	metricsPort := flag.Int("metrics-port", 0, "puerto base de las métricas de los procesos; cada proceso escucha en puerto+pid")
+	interval := flag.Duration("interval", 500*time.Millisecond, "intervalo entre actualizaciones")
+	once := flag.Bool("once", false, "muestra el estado una sola vez, sin borrar la pantalla")
+	noColor := flag.Bool("no-color", false, "no resalta en color el proceso que frena el avance")
+	path := flag.String("path", helpers.DefaultPath, "directorio de network.json")
+	flag.Parse()
+
+	if *metricsPort <= 0 {
+		fmt.Fprintln(os.Stderr, "-metrics-port is required")
+		os.Exit(2)
+	}
+	network := helpers.ReadNetConfig(*path, "network.json")
+	if *once {
+		urls := dashboard.StatusURLs(network, *metricsPort)
+		views := dashboard.Poll(&http.Client{Timeout: *interval}, network, urls)
+		dashboard.Render(os.Stdout, views, !*noColor)
+		return
+	}
+	dashboard.Run(os.Stdout, network, *metricsPort, *interval, !*noColor)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"centralsim"
+	"centralsim/petrinet"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"petrisim/models"
+	"petrisim/process"
+)
+
+/*
+Generates a net of a parametric family (pipeline, ring, forkjoin, grid or
+random) and writes its subnets for K logic processes with their transitions
+map, to benchmark the simulator at scale
+*/
+func main() {
+	var g petrinet.Generator
+	flag.StringVar(&g.Family, "family", petrinet.FamilyRing, "familia de la red: pipeline, ring, forkjoin, grid o random")
+	flag.IntVar(&g.Size, "size", 8, "etapas, ramas, lado de la malla o lugares de la red aleatoria")
+	flag.IntVar(&g.Tokens, "tokens", 1, "marcas iniciales del anillo, la malla o la red aleatoria")
+	minDuration := flag.Int("min-duration", 1, "duración mínima de las transiciones")
+	maxDuration := flag.Int("max-duration", 1, "duración máxima de las transiciones")
+	flag.Float64Var(&g.Density, "density", 0.2, "probabilidad de un arco entre dos lugares de la red aleatoria")
+	flag.IntVar(&g.Processes, "processes", 2, "procesos lógicos entre los que se reparte la red")
+	flag.Int64Var(&g.Seed, "seed", 1, "semilla de las duraciones y de la red aleatoria")
+	subnets := flag.String("subnets", "", "prefijo de las subredes <prefijo>.subred<i>.json y del mapa <prefijo>.transitions.json")
+	netFile := flag.String("net", "", "fichero donde escribir la red de lugares y transiciones generada")
+	out := flag.String("out", "", "fichero donde escribir la Lefs de la red completa")
+	flag.Parse()
+	g.MinDuration, g.MaxDuration = centralsim.TypeClock(*minDuration), centralsim.TypeClock(*maxDuration)
+
+	net, owner, err := g.Generate()
+	if err != nil {
+		fail(err)
+	}
+	model, err := petrinet.Compile(net)
+	if err != nil {
+		fail(err)
+	}
+	if *netFile != "" {
+		data, err := json.MarshalIndent(net, "", "  ")
+		if err != nil {
+			fail(err)
+		}
+		if err := ioutil.WriteFile(*netFile, data, 0666); err != nil {
+			fail(err)
+		}
+	}
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fail(err)
+		}
+		err = model.WriteLefs(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+	if *subnets != "" {
+		parts, err := model.Partition(owner)
+		if err != nil {
+			fail(err)
+		}
+		if err := process.SaveSubnets(*subnets, parts, models.MakeTransitionMaps(parts)); err != nil {
+			fail(err)
+		}
+	}
+	fmt.Printf("%v: %v lugares, %v transiciones en %v procesos\n", g.Family, len(net.Places), len(net.Transitions), g.Processes)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"petrisim/launcher"
+	"strings"
+)
+
+/*
+Launches every logic process of a simulation in this machine and recovers
+the run from the last global snapshot when a process fails
+*/
+func main() {
+	binary := flag.String("bin", "./petrisim", "ejecutable del proceso lógico")
+	processes := flag.Int("n", 2, "número de procesos lógicos")
+	snapshotDir := flag.String("snapshots", "snapshots", "directorio de instantáneas globales")
+	snapshotInterval := flag.String("snapshot-interval", "1s", "intervalo entre instantáneas globales")
+	maxRestarts := flag.Int("max-restarts", 3, "número máximo de recuperaciones")
+	args := flag.String("args", "", "argumentos adicionales para todos los procesos")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: launcher [flags] <prefijo de red>")
+		os.Exit(2)
+	}
+
+	l := launcher.Launcher{
+		Binary:        *binary,
+		Dir:           ".",
+		Prefix:        flag.Arg(0),
+		Processes:     *processes,
+		Args:          strings.Fields(*args),
+		InitiatorArgs: []string{"-snapshot-interval", *snapshotInterval},
+		SnapshotDir:   *snapshotDir,
+		MaxRestarts:   *maxRestarts,
+		Stdout:        os.Stdout,
+		Stderr:        os.Stderr,
+	}
+	if err := l.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Simulación terminada con %v recuperaciones\n", l.Restarts)
+}
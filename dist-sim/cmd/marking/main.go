@@ -0,0 +1,49 @@
+package main
+
+import (
+	"centralsim"
+	"flag"
+	"fmt"
+	"os"
+	"petrisim/process"
+)
+
+/*
+Merges the marking logs of every logic process of a run, saved with
+-marking, and prints the time-weighted average, minimum, maximum and
+occupancy of every place; optionally writes the marking series as CSV
+*/
+func main() {
+	dir := flag.String("dir", "marking", "directorio con los registros <pid>.json del marcado")
+	csvFile := flag.String("csv", "", "fichero CSV donde escribir la evolución del marcado")
+	flag.Parse()
+
+	logs, err := process.LoadMarkingLogs(*dir)
+	if err != nil {
+		fail(err)
+	}
+	if len(logs) == 0 {
+		fail(fmt.Errorf("no marking logs in %v", *dir))
+	}
+	merged := centralsim.MergeMarkingLogs(logs)
+	centralsim.WritePlaceReport(os.Stdout, merged)
+
+	if *csvFile != "" {
+		file, err := os.Create(*csvFile)
+		if err != nil {
+			fail(err)
+		}
+		err = centralsim.WriteMarkingSeries(file, merged)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
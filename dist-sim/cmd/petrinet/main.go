@@ -0,0 +1,100 @@
+package main
+
+import (
+	"centralsim"
+	"centralsim/petrinet"
+	"flag"
+	"fmt"
+	"os"
+	"petrisim/models"
+	"petrisim/process"
+)
+
+/*
+Compiles a place/transition net, or a hierarchical net flattened from its
+module instances, to the Lefs file read by the simulator or to the subnets
+and transitions map of its partition and, given the checkpoint of a
+centralized run, reports its firings and the marking of every place
+*/
+func main() {
+	netFile := flag.String("net", "", "red de lugares y transiciones en JSON")
+	hierarchyFile := flag.String("hierarchy", "", "red jerárquica con instancias de módulos en JSON, en lugar de -net")
+	out := flag.String("out", "", "fichero donde escribir la Lefs compilada (vacío para la salida estándar)")
+	subnets := flag.String("subnets", "", "prefijo de las subredes <prefijo>.subred<i>.json y del mapa <prefijo>.transitions.json "+
+		"de la partición en los procesos de las instancias, en lugar de -out")
+	checkpoint := flag.String("checkpoint", "", "checkpoint de una simulación de la red cuyo marcado se muestra")
+	at := flag.Int("at", -1, "instante del marcado (por defecto el reloj del checkpoint)")
+	flag.Parse()
+
+	var net *petrinet.Net
+	var owner []int
+	var err error
+	if *hierarchyFile != "" {
+		h, err := petrinet.LoadHierarchy(*hierarchyFile)
+		if err != nil {
+			fail(err)
+		}
+		if net, owner, err = h.Flatten(); err != nil {
+			fail(err)
+		}
+	} else if net, err = petrinet.Load(*netFile); err != nil {
+		fail(err)
+	}
+	model, err := petrinet.Compile(net)
+	if err != nil {
+		fail(err)
+	}
+
+	if *subnets != "" {
+		if owner == nil {
+			owner = make([]int, len(net.Transitions)) // una red sin jerarquía se simula en un proceso
+		}
+		parts, err := model.Partition(owner)
+		if err != nil {
+			fail(err)
+		}
+		if err := process.SaveSubnets(*subnets, parts, models.MakeTransitionMaps(parts)); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	if *checkpoint == "" {
+		file := os.Stdout
+		if *out != "" {
+			if file, err = os.Create(*out); err != nil {
+				fail(err)
+			}
+		}
+		err = model.WriteLefs(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	cp, err := centralsim.LoadCheckpoint(*checkpoint)
+	if err != nil {
+		fail(err)
+	}
+	clock := cp.Clock
+	if *at >= 0 {
+		clock = centralsim.TypeClock(*at)
+	}
+	for _, r := range cp.Results {
+		fmt.Printf("%v dispara %v\n", r.ValorRelojDisparo, model.TransitionName(r.CodTransition))
+	}
+	marking, err := model.MarkingAt(cp.Results, clock)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("Marcado en %v: %v\n", clock, model.Format(marking))
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"centralsim"
+	"flag"
+	"fmt"
+	"os"
+	"petrisim/process"
+)
+
+/*
+Prints the efficiency report of every logic process of a run and the
+aggregate, from the profiles saved with -profile
+*/
+func main() {
+	dir := flag.String("dir", "profile", "directorio con los perfiles <pid>.json de los procesos")
+	flag.Parse()
+
+	profiles, err := process.LoadProfiles(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(profiles) == 0 {
+		fmt.Fprintf(os.Stderr, "no profiles in %v\n", *dir)
+		os.Exit(1)
+	}
+	centralsim.WriteProfileReport(os.Stdout, profiles)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"centralsim"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+Merges the Chrome traces of every logic process of a run, written with
+-trace, into a single timeline for chrome://tracing or ui.perfetto.dev
+*/
+func main() {
+	dir := flag.String("dir", "trace", "directorio con las trazas <pid>.trace.json de los procesos")
+	outName := flag.String("out", "trace.json", "fichero con la traza conjunta")
+	flag.Parse()
+
+	names, err := filepath.Glob(filepath.Join(*dir, "*.trace.json"))
+	if err != nil || len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "no traces in %v\n", *dir)
+		os.Exit(1)
+	}
+	traces := []io.Reader{}
+	for _, name := range names {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		traces = append(traces, file)
+	}
+
+	out, err := os.Create(*outName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err = centralsim.MergeTraces(out, traces...)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%v trazas unidas en %v\n", len(names), *outName)
+}
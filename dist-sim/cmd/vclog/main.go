@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"petrisim/vclog"
+)
+
+/*
+Merges the GoVector logs of every logic process of a run into a single
+ShiViz log and prints a summary of the messages and causal chains
+*/
+func main() {
+	dir := flag.String("dir", "logs/govector", "directorio con los logs de GoVector de los procesos")
+	output := flag.String("out", "shiviz.log", "fichero ShiViz a generar (vacío para solo mostrar el informe)")
+	flag.Parse()
+
+	events, err := vclog.ReadDir(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	events = vclog.Merge(events)
+
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = vclog.WriteShiViz(file, events)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Log de ShiViz: %v\n", *output)
+	}
+	vclog.Analyze(events).Print(os.Stdout)
+}
@@ -0,0 +1,231 @@
+/*
+Package dashboard muestra en el terminal el estado de los procesos lógicos de
+una simulación en marcha, consultando el estado que publica cada uno, y
+señala el proceso que frena el avance global.
+*/
+package dashboard
+
+import (
+	"centralsim"
+	"fmt"
+	"io"
+	"net/http"
+	"petrisim/models"
+	"petrisim/process"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secuencias ANSI del terminal
+const (
+	clearScreen = "\033[H\033[2J"
+	highlight   = "\033[1;31m"
+	reset       = "\033[0m"
+)
+
+// View es el último estado leído de un proceso o el error al consultarlo
+type View struct {
+	Name   string
+	Status process.Status
+	Err    error
+}
+
+// Reachable indica si se pudo leer el estado del proceso
+func (v View) Reachable() bool {
+	return v.Err == nil
+}
+
+// StatusURLs devuelve la dirección del estado de cada proceso de la red,
+// que escucha en metricsPort+pid
+func StatusURLs(network []models.ProcessInfo, metricsPort int) []string {
+	urls := make([]string, len(network))
+	for i, p := range network {
+		urls[i] = "http://" + p.Ip + ":" + strconv.Itoa(metricsPort+i) + "/status"
+	}
+	return urls
+}
+
+// Poll consulta a la vez el estado de todos los procesos
+func Poll(client *http.Client, network []models.ProcessInfo, urls []string) []View {
+	views := make([]View, len(urls))
+	var wg sync.WaitGroup
+	for i := range urls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			views[i].Name = network[i].Name
+			views[i].Status, views[i].Err = process.FetchStatus(client, urls[i])
+		}(i)
+	}
+	wg.Wait()
+	return views
+}
+
+/*
+Bottleneck devuelve el proceso que frena el avance global y el motivo: el
+proceso cuyo LookAhead esperan más procesos o, si nadie espera, el de menor
+reloj entre los que no han terminado. Devuelve -1 si no hay ninguno.
+*/
+func Bottleneck(views []View) (int, string) {
+	waiters := map[int]int{}
+	for _, v := range views {
+		if v.Reachable() && v.Status.State == centralsim.StateWaitingLookAhead && v.Status.WaitingFor >= 0 {
+			waiters[v.Status.WaitingFor]++
+		}
+	}
+	best := -1
+	for i, v := range views {
+		if !v.Reachable() || v.Status.State == centralsim.StateFinished {
+			continue
+		}
+		if best == -1 || waiters[i] > waiters[best] ||
+			(waiters[i] == waiters[best] && v.Status.Clock < views[best].Status.Clock) {
+			best = i
+		}
+	}
+	switch {
+	case best == -1:
+		return -1, ""
+	case waiters[best] == 1:
+		return best, "1 proceso espera su LookAhead"
+	case waiters[best] > 1:
+		return best, fmt.Sprintf("%v procesos esperan su LookAhead", waiters[best])
+	}
+	return best, "menor reloj local"
+}
+
+// Render escribe el panel con el estado de los procesos; color resalta el
+// proceso que frena el avance, que además se marca con >
+func Render(w io.Writer, views []View, color bool) {
+	bottleneck, reason := Bottleneck(views)
+	fmt.Fprintf(w, "Simulación distribuida  %v\n", time.Now().Format("15:04:05"))
+	if bottleneck >= 0 {
+		fmt.Fprintf(w, "Frena el avance: %v (%v)\n", views[bottleneck].Name, reason)
+	} else {
+		fmt.Fprintln(w, "Ningún proceso en marcha")
+	}
+	fmt.Fprintf(w, "\n  %-6v %-22v %8v %9v %9v %9v  %v\n",
+		"PL", "estado", "reloj", "eventos", "disparos", "mensajes", "lookaheads")
+	for i, v := range views {
+		mark, start, end := " ", "", ""
+		if i == bottleneck {
+			mark = ">"
+			if color {
+				start, end = highlight, reset
+			}
+		}
+		if !v.Reachable() {
+			fmt.Fprintf(w, "%v%v %-6v sin conexión: %v%v\n", start, mark, v.Name, v.Err, end)
+			continue
+		}
+		s := v.Status
+		fmt.Fprintf(w, "%v%v %-6v %-22v %8v %9v %9v %9v  %v%v\n", start, mark, v.Name, state(s), s.Clock,
+			s.PendingEvents, s.TransitionsFired, fmt.Sprintf("%v/%v", s.MessagesSent, s.MessagesReceived),
+			lookAheads(s), end)
+	}
+
+	fmt.Fprintln(w)
+	for _, v := range views {
+		if !v.Reachable() {
+			continue
+		}
+		fmt.Fprintf(w, "%v próximos eventos: %v\n", v.Name, nextEvents(v.Status))
+		fmt.Fprintf(w, "%v últimos disparos: %v\n", strings.Repeat(" ", len(v.Name)), lastFired(v.Status))
+	}
+}
+
+// Run muestra el panel cada interval hasta que todos los procesos terminan
+func Run(w io.Writer, network []models.ProcessInfo, metricsPort int, interval time.Duration, color bool) {
+	client := &http.Client{Timeout: interval}
+	run(w, client, network, StatusURLs(network, metricsPort), interval, color)
+}
+
+func run(w io.Writer, client *http.Client, network []models.ProcessInfo, urls []string, interval time.Duration, color bool) {
+	p := newProgress(len(urls))
+	for {
+		views := Poll(client, network, urls)
+		fmt.Fprint(w, clearScreen)
+		Render(w, views, color)
+		if p.update(views) {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Lo que se sabe de cada proceso a lo largo de las consultas: un proceso que
+// termina deja de publicar su estado, así que se da por terminado el que se
+// vio terminado alguna vez o el que ya no responde después de haber respondido
+type progress struct {
+	reached  []bool // respondió alguna vez
+	finished []bool // se vio terminado alguna vez
+}
+
+func newProgress(processes int) *progress {
+	return &progress{reached: make([]bool, processes), finished: make([]bool, processes)}
+}
+
+// Registra una consulta e indica si todos los procesos han terminado
+func (p *progress) update(views []View) bool {
+	done := true
+	for i, v := range views {
+		if v.Reachable() {
+			p.reached[i] = true
+			if v.Status.State == centralsim.StateFinished {
+				p.finished[i] = true
+			}
+		}
+		if !p.finished[i] && (v.Reachable() || !p.reached[i]) {
+			done = false
+		}
+	}
+	return done
+}
+
+func state(s process.Status) string {
+	if s.State == centralsim.StateWaitingLookAhead {
+		return fmt.Sprintf("%v PL%v", s.State, s.WaitingFor)
+	}
+	return s.State
+}
+
+func lookAheads(s process.Status) string {
+	ids := make([]int, 0, len(s.LookAheads))
+	for p := range s.LookAheads {
+		ids = append(ids, p)
+	}
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, p := range ids {
+		parts[i] = fmt.Sprintf("PL%v=%v", p, s.LookAheads[p])
+	}
+	return strings.Join(parts, " ")
+}
+
+func nextEvents(s process.Status) string {
+	if len(s.NextEvents) == 0 {
+		return "-"
+	}
+	parts := []string{}
+	for _, e := range s.NextEvents {
+		parts = append(parts, fmt.Sprintf("T%v@%v(%+d)", e.IiTransicion, e.IiTiempo, e.IiCte))
+	}
+	if more := s.PendingEvents - len(s.NextEvents); more > 0 {
+		parts = append(parts, fmt.Sprintf("… %v más", more))
+	}
+	return strings.Join(parts, " ")
+}
+
+func lastFired(s process.Status) string {
+	if len(s.LastFired) == 0 {
+		return "-"
+	}
+	parts := []string{}
+	for _, r := range s.LastFired {
+		parts = append(parts, fmt.Sprintf("T%v@%v", r.CodTransition, r.ValorRelojDisparo))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,127 @@
+package dashboard
+
+import (
+	"bytes"
+	"centralsim"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"petrisim/models"
+	"petrisim/process"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func view(name string, state string, clock centralsim.TypeClock, waitingFor int) View {
+	status := process.Status{EngineStatus: centralsim.EngineStatus{State: state, Clock: clock, WaitingFor: waitingFor}}
+	return View{Name: name, Status: status}
+}
+
+func TestBottleneck(t *testing.T) {
+	views := []View{
+		view("LP0", centralsim.StateWaitingLookAhead, 10, 2),
+		view("LP1", centralsim.StateWaitingLookAhead, 8, 2),
+		view("LP2", centralsim.StateRunning, 12, -1),
+		{Name: "LP3", Err: errors.New("connection refused")},
+	}
+	if p, reason := Bottleneck(views); p != 2 || reason != "2 procesos esperan su LookAhead" {
+		t.Errorf("bottleneck %v (%v), expected LP2", p, reason)
+	}
+
+	// sin esperas frena el de menor reloj que no ha terminado
+	views[0] = view("LP0", centralsim.StateFinished, 5, -1)
+	views[1] = view("LP1", centralsim.StateRunning, 8, -1)
+	if p, reason := Bottleneck(views); p != 1 || reason != "menor reloj local" {
+		t.Errorf("bottleneck %v (%v), expected LP1", p, reason)
+	}
+}
+
+func TestRenderPolledStatus(t *testing.T) {
+	statuses := []process.Status{
+		{Process: 0, EngineStatus: centralsim.EngineStatus{State: centralsim.StateWaitingLookAhead, WaitingFor: 1, Clock: 7,
+			LookAheads: map[int]centralsim.TypeClock{1: 7, 2: 9}, PendingEvents: 1,
+			NextEvents: []centralsim.Event{{IiTiempo: 9, IiTransicion: 3, IiCte: -1}}}},
+		{Process: 1, EngineStatus: centralsim.EngineStatus{State: centralsim.StateRunning, WaitingFor: -1, Clock: 6,
+			TransitionsFired: 4, LastFired: []centralsim.ResultadoTransition{{CodTransition: 5, ValorRelojDisparo: 6}}}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, s := range statuses {
+			if r.URL.Path == "/"+strconv.Itoa(s.Process) {
+				json.NewEncoder(w).Encode(s)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	network := []models.ProcessInfo{{Name: "LP0"}, {Name: "LP1"}, {Name: "LP2"}}
+	views := Poll(server.Client(), network, []string{server.URL + "/0", server.URL + "/1", server.URL + "/2"})
+	var out bytes.Buffer
+	Render(&out, views, false)
+	text := out.String()
+	for _, want := range []string{
+		"Frena el avance: LP1 (1 proceso espera su LookAhead)",
+		"waiting-lookahead PL1",
+		"PL1=7 PL2=9",
+		"> LP1 ",
+		"LP2    sin conexión",
+		"LP0 próximos eventos: T3@9(-1)",
+		"últimos disparos: T5@6",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("dashboard does not contain %q:\n%v", want, text)
+		}
+	}
+}
+
+// Los procesos dejan de publicar su estado al terminar: el panel acaba cuando
+// los que respondieron dejan de hacerlo, aunque no se les viera terminados
+func TestRunEndsWhenStatusServersGoAway(t *testing.T) {
+	polled := make(chan string, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := centralsim.StateRunning
+		if r.URL.Path == "/1" {
+			state = centralsim.StateFinished
+		}
+		json.NewEncoder(w).Encode(process.Status{EngineStatus: centralsim.EngineStatus{State: state}})
+		select {
+		case polled <- r.URL.Path:
+		default:
+		}
+	}))
+
+	network := []models.ProcessInfo{{Name: "LP0"}, {Name: "LP1"}}
+	ended := make(chan bool)
+	go func() {
+		var out bytes.Buffer
+		run(&out, server.Client(), network, []string{server.URL + "/0", server.URL + "/1"}, 10*time.Millisecond, false)
+		ended <- true
+	}()
+	for seen := map[string]bool{}; len(seen) < 2; { // ambos procesos respondieron
+		seen[<-polled] = true
+	}
+	server.Close()
+	select {
+	case <-ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dashboard still running after the status servers went away")
+	}
+}
+
+func TestProgressWaitsForUnreachedProcesses(t *testing.T) {
+	p := newProgress(2)
+	down := View{Name: "LP1", Err: errors.New("connection refused")}
+	if p.update([]View{view("LP0", centralsim.StateFinished, 5, -1), down}) {
+		t.Error("finished before LP1 was reached")
+	}
+	if p.update([]View{down, view("LP1", centralsim.StateRunning, 5, -1)}) {
+		t.Error("finished while LP1 is running")
+	}
+	if !p.update([]View{down, down}) {
+		t.Error("not finished after LP1 went away")
+	}
+}
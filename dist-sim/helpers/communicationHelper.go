@@ -4,9 +4,9 @@ package helpers
 import (
 	"centralsim"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
-
-	"github.com/DistributedClocks/GoVector/govec"
 )
 
 // Send any data to desired ip
@@ -16,14 +16,18 @@ func Send(data interface{}, ip string, log *centralsim.Logger) error {
 
 	conn, err = net.Dial("tcp", ip)
 
-	if err != nil {
-		panic("Client connection error")
+	if err != nil { // el proceso destino no está disponible
+		return fmt.Errorf("client connection error: %w", err)
 	}
 
-	binBuffer := log.GoVec.PrepareSend("Send", data, govec.GetDefaultLogOptions())
-
-	conn.Write(binBuffer)
 	defer conn.Close()
+
+	binBuffer, err := log.Clock.PrepareSend("Send", data)
+	if err != nil {
+		return fmt.Errorf("encode error: %w", err)
+	}
+
+	_, err = conn.Write(binBuffer)
 	return err
 }
 
@@ -31,21 +35,26 @@ func Send(data interface{}, ip string, log *centralsim.Logger) error {
 func Receive(data interface{}, listener *net.Listener, log *centralsim.Logger) error {
 	var conn net.Conn
 	var err error
-	tmp := make([]byte, 512)
 
 	conn, err = (*listener).Accept()
 	if err != nil {
 		panic("Server accept connection error")
 	}
 
-	_, err = conn.Read(tmp[0:])
+	defer conn.Close()
+
+	// cada mensaje usa su propia conexión, que el emisor cierra al terminar
+	buf, err := ioutil.ReadAll(conn)
+	if err == nil && len(buf) == 0 { // el emisor cerró la conexión sin enviar nada
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
-		panic(fmt.Sprintf("Server accept connection error: %s", err))
+		return fmt.Errorf("server read error: %w", err)
 	}
 
-	log.GoVec.UnpackReceive("Receive", tmp, data, govec.GetDefaultLogOptions())
-
-	defer conn.Close()
+	if err = log.Clock.UnpackReceive("Receive", buf, data); err != nil {
+		return fmt.Errorf("server decode error: %w", err)
+	}
 
 	return err
 }
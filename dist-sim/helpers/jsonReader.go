@@ -4,14 +4,16 @@ package helpers
 import (
 	"encoding/json"
 	"io/ioutil"
+	"path/filepath"
 	"petrisim/models"
 )
 
-// const path = "/home/a846866/Documents/"
-const path = "/home/freedy/Documents/master/RedesYDistribuidos/MiniproyectoSD/petri-net-sim/dist-sim/"
+// DefaultPath es el directorio desde el que se leen por defecto los ficheros de configuración
+// const DefaultPath = "/home/a846866/Documents/"
+const DefaultPath = "/home/freedy/Documents/master/RedesYDistribuidos/MiniproyectoSD/petri-net-sim/dist-sim/"
 
-func readFile(fileName string) []byte {
-	data, err := ioutil.ReadFile(path + fileName)
+func readFile(dir, fileName string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join(dir, fileName))
 	if err != nil {
 		panic(err)
 	}
@@ -19,8 +21,8 @@ func readFile(fileName string) []byte {
 	return data
 }
 
-func ReadNetConfig(fileName string) []models.ProcessInfo {
-	data := readFile(fileName)
+func ReadNetConfig(dir, fileName string) []models.ProcessInfo {
+	data := readFile(dir, fileName)
 
 	var myJson []models.ProcessInfo
 	err := json.Unmarshal(data, &myJson)
@@ -31,8 +33,8 @@ func ReadNetConfig(fileName string) []models.ProcessInfo {
 	return myJson
 }
 
-func ReadNetTransitions(fileName string) []models.TransitionMap {
-	data := readFile(fileName)
+func ReadNetTransitions(dir, fileName string) []models.TransitionMap {
+	data := readFile(dir, fileName)
 
 	var myJson []models.TransitionMap
 	err := json.Unmarshal(data, &myJson)
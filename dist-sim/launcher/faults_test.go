@@ -0,0 +1,80 @@
+package launcher
+
+import (
+	"centralsim"
+	"path/filepath"
+	"petrisim/process"
+	"testing"
+)
+
+const faultCycles = 300
+
+// Disparos de la simulación secuencial de la red completa anteriores al tiempo indicado
+func sequentialFirings(t *testing.T, dir string, prefix string, processes int, before centralsim.TypeClock) []centralsim.ResultadoTransition {
+	subnets, err := process.LoadSubnets(filepath.Join(dir, "tests", prefix), processes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return process.SortedFirings(process.SimulateSequential(subnets, faultCycles), before)
+}
+
+// Disparos de todos los procesos anteriores al tiempo indicado
+func distributedFirings(t *testing.T, dir string, processes int, before centralsim.TypeClock) []centralsim.ResultadoTransition {
+	all := []centralsim.ResultadoTransition{}
+	for pid := 0; pid < processes; pid++ {
+		all = append(all, firings(t, dir, pid, before)...)
+	}
+	return process.SortedFirings(all, before)
+}
+
+// Con retardos, desorden, duplicados y pérdidas la simulación distribuida
+// dispara las mismas transiciones que la secuencial
+func TestFaultyNetworkMatchesSequentialReference(t *testing.T) {
+	if testing.Short() {
+		t.Skip("launches processes on localhost")
+	}
+	bin := buildBinary(t)
+
+	for _, faults := range []string{
+		"seed=1,latency=1ms,jitter=3ms,reorder=0.3",
+		"seed=2,duplicate=0.3,drop=0.2",
+		"seed=3,latency=1ms,jitter=2ms,reorder=0.2,duplicate=0.2,drop=0.2",
+	} {
+		t.Run(faults, func(t *testing.T) {
+			checkpointDir := t.TempDir()
+			l := newLauncher(t, bin, checkpointDir, faultCycles)
+			l.Args = append(l.Args, "-faults", faults+",retransmit=10ms")
+			l.MaxRestarts = 0
+			if err := l.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			want := sequentialFirings(t, l.Dir, l.Prefix, l.Processes, faultCycles-20)
+			got := distributedFirings(t, checkpointDir, l.Processes, faultCycles-20)
+			if len(want) == 0 || len(want) != len(got) {
+				t.Fatalf("fired %v transitions, expected %v", len(got), len(want))
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Fatalf("firing %v differs: %+v, expected %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// Si un proceso queda aislado más allá del tiempo límite la simulación
+// termina con error en lugar de quedarse bloqueada
+func TestPartitionFailsLoudly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("launches processes on localhost")
+	}
+	bin := buildBinary(t)
+
+	l := newLauncher(t, bin, t.TempDir(), faultCycles)
+	l.Args = append(l.Args, "-faults", "partition=2@0s-1m", "-failure-timeout", "1s")
+	l.MaxRestarts = 0
+	if err := l.Run(); err == nil {
+		t.Fatal("expected the partitioned simulation to fail")
+	}
+}
@@ -0,0 +1,186 @@
+// This module launches the logic processes of a simulation in the local
+// machine and restarts them from the last global snapshot when one fails
+package launcher
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"petrisim/models"
+	"petrisim/process"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// Launcher arranca los procesos lógicos de una simulación y, si alguno cae,
+// detiene los demás y los vuelve a arrancar todos desde la última instantánea
+// global completa, de modo que los procesos vivos también retroceden a ella
+type Launcher struct {
+	Binary        string   // Ejecutable del proceso lógico (petrisim)
+	Dir           string   // Directorio de trabajo de los procesos
+	Prefix        string   // Prefijo de los ficheros de la red en tests/
+	Processes     int      // Número de procesos lógicos
+	Args          []string // Argumentos comunes a todos los procesos
+	InitiatorArgs []string // Argumentos solo para el proceso 0, p.ej. -snapshot-interval
+	SnapshotDir   string   // Directorio de instantáneas globales (-snapshots)
+	MaxRestarts   int      // Número máximo de recuperaciones
+	Stdout        io.Writer
+	Stderr        io.Writer
+
+	Restarts int // Recuperaciones realizadas
+
+	mux  sync.Mutex
+	cmds []*exec.Cmd
+}
+
+// Resultado de la ejecución de un proceso
+type exitStatus struct {
+	pid int
+	err error
+}
+
+// Run ejecuta la simulación hasta que todos los procesos terminan con éxito
+func (l *Launcher) Run() error {
+	resume := ""
+	for {
+		failed, err := l.runOnce(resume)
+		if err != nil {
+			return err
+		}
+		if failed == -1 {
+			return nil
+		}
+		if l.Restarts >= l.MaxRestarts {
+			return fmt.Errorf("PL%v failed and the maximum of %v restarts was reached", failed, l.MaxRestarts)
+		}
+		l.Restarts++
+
+		// Sin instantánea completa la simulación empieza de nuevo
+		resume, err = process.LatestSnapshot(l.SnapshotDir, l.Processes)
+		if err != nil {
+			resume = ""
+			continue
+		}
+		id, err := models.ParseSnapshotId(filepath.Base(resume))
+		if err != nil {
+			return err
+		}
+		if err := removeSnapshotsAfter(l.SnapshotDir, id); err != nil {
+			return err
+		}
+	}
+}
+
+// Kill termina el proceso lógico indicado, simulando su caída
+func (l *Launcher) Kill(pid int) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if pid >= len(l.cmds) || l.cmds[pid] == nil || l.cmds[pid].Process == nil {
+		return fmt.Errorf("PL%v is not running", pid)
+	}
+	return l.cmds[pid].Process.Kill()
+}
+
+// Arranca todos los procesos y espera a que terminen. Devuelve el primer
+// proceso que falló, o -1 si todos terminaron con éxito.
+func (l *Launcher) runOnce(resume string) (int, error) {
+	l.mux.Lock()
+	l.cmds = make([]*exec.Cmd, l.Processes)
+	exits := make(chan exitStatus, l.Processes)
+	for pid := 0; pid < l.Processes; pid++ {
+		cmd := exec.Command(l.Binary, l.args(pid, resume)...)
+		cmd.Dir = l.Dir
+		cmd.Stdout = l.Stdout
+		cmd.Stderr = l.Stderr
+		if err := cmd.Start(); err != nil {
+			l.mux.Unlock()
+			l.stopAll()
+			return -1, err
+		}
+		l.cmds[pid] = cmd
+		go func(pid int) {
+			exits <- exitStatus{pid, cmd.Wait()}
+		}(pid)
+	}
+	l.mux.Unlock()
+
+	failed := -1
+	var fatal error
+	for i := 0; i < l.Processes; i++ {
+		status := <-exits
+		if status.err == nil || failed != -1 || fatal != nil {
+			continue
+		}
+		if restartable(status.err) {
+			failed = status.pid
+		} else {
+			fatal = fmt.Errorf("PL%v failed: %v", status.pid, status.err)
+		}
+		l.stopAll() // los demás procesos retroceden a la instantánea
+	}
+	if fatal != nil {
+		return -1, fatal
+	}
+	return failed, nil
+}
+
+// Indica si el error de un proceso se debe a la caída de un proceso, propia
+// (terminado por una señal) o de otro, y no a un fallo de la simulación como
+// una violación de la causalidad, que se repetiría al reanudar
+func restartable(err error) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return true
+	}
+	return exitErr.ExitCode() == process.ExitPeerFailure
+}
+
+// Argumentos de un proceso
+func (l *Launcher) args(pid int, resume string) []string {
+	args := append([]string{}, l.Args...)
+	if l.SnapshotDir != "" {
+		args = append(args, "-snapshots", l.SnapshotDir)
+	}
+	if pid == 0 {
+		args = append(args, l.InitiatorArgs...)
+	}
+	if resume != "" {
+		args = append(args, "-resume", resume)
+	}
+	return append(args, strconv.Itoa(pid), l.Prefix)
+}
+
+// Termina todos los procesos que siguen en ejecución
+func (l *Launcher) stopAll() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for _, cmd := range l.cmds {
+		if cmd != nil && cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+	}
+}
+
+// Elimina las instantáneas posteriores a la elegida para reanudar, que pueden
+// estar incompletas y no deben mezclarse con las de la nueva ejecución
+func removeSnapshotsAfter(baseDir string, id models.SnapshotId) error {
+	entries, err := ioutil.ReadDir(baseDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if other, err := models.ParseSnapshotId(e.Name()); err == nil && id.Before(other) {
+			if err := os.RemoveAll(filepath.Join(baseDir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
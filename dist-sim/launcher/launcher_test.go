@@ -0,0 +1,154 @@
+package launcher
+
+import (
+	"centralsim"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"petrisim/models"
+	"petrisim/process"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const recoveryCycles = 3000
+
+// Compila petrisim y prepara un lanzador de la red special3 en localhost
+func newLauncher(t *testing.T, bin string, checkpointDir string, cycles int) *Launcher {
+	dir, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "logs", "govector"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	return &Launcher{
+		Binary:    bin,
+		Dir:       dir,
+		Prefix:    "special3",
+		Processes: 3,
+		Args: []string{"-cycles", strconv.Itoa(cycles), "-path", dir + "/",
+			"-checkpoint", checkpointDir, "-failure-timeout", "2s"},
+		SnapshotDir: t.TempDir(),
+		MaxRestarts: 1,
+	}
+}
+
+func buildBinary(t *testing.T) string {
+	bin := filepath.Join(t.TempDir(), "petrisim")
+	cmd := exec.Command("go", "build", "-o", bin, ".")
+	cmd.Dir = ".."
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build petrisim: %v\n%s", err, out)
+	}
+	return bin
+}
+
+// Disparos de un proceso anteriores al tiempo indicado, ordenados
+func firings(t *testing.T, dir string, pid int, before centralsim.TypeClock) []centralsim.ResultadoTransition {
+	cp, err := centralsim.LoadCheckpoint(filepath.Join(dir, strconv.Itoa(pid)+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return process.SortedFirings(cp.Results, before)
+}
+
+// Mata un proceso a mitad de la simulación y comprueba que, tras reanudar
+// desde la última instantánea, se disparan las mismas transiciones que en
+// una ejecución sin fallos
+func TestRecoveryAfterKillingLP(t *testing.T) {
+	if testing.Short() {
+		t.Skip("launches processes on localhost")
+	}
+	bin := buildBinary(t)
+
+	referenceDir := t.TempDir()
+	reference := newLauncher(t, bin, referenceDir, recoveryCycles)
+	if err := reference.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	recoveredDir := t.TempDir()
+	recovered := newLauncher(t, bin, recoveredDir, recoveryCycles)
+	recovered.InitiatorArgs = []string{"-snapshot-interval", "20ms"}
+	go func() {
+		// espera a la primera instantánea completa y mata PL1
+		for {
+			if _, err := process.LatestSnapshot(recovered.SnapshotDir, recovered.Processes); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := recovered.Kill(1); err != nil {
+			t.Error(err)
+		}
+	}()
+	if err := recovered.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if recovered.Restarts != 1 {
+		t.Fatalf("expected one recovery, got %v", recovered.Restarts)
+	}
+
+	// Cada proceso termina en un tiempo distinto, se comparan los primeros ciclos
+	for pid := 0; pid < 3; pid++ {
+		want := firings(t, referenceDir, pid, recoveryCycles-20)
+		got := firings(t, recoveredDir, pid, recoveryCycles-20)
+		if len(want) == 0 || len(want) != len(got) {
+			t.Fatalf("PL%v fired %v transitions, expected %v", pid, len(got), len(want))
+		}
+		for i := range want {
+			if want[i] != got[i] {
+				t.Fatalf("PL%v firing %v differs: %+v, expected %+v", pid, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// Al reanudar desde p0-1 se eliminan las instantáneas posteriores, que pueden
+// estar incompletas, y se conservan las anteriores
+func TestRemoveSnapshotsAfter(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"p1-0", "p0-1", "p1-2", "p0-3"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := removeSnapshotsAfter(dir, models.SnapshotId{Initiator: 0, Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for name, kept := range map[string]bool{"p1-0": true, "p0-1": true, "p1-2": false, "p0-3": false} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if kept != (err == nil) {
+			t.Errorf("snapshot %v: kept = %v, want %v", name, err == nil, kept)
+		}
+	}
+}
+
+// Solo se reanuda la simulación cuando un proceso cae, no cuando termina con
+// otro código de error como el de una violación de la causalidad
+func TestRestartOnlyAfterPeerFailure(t *testing.T) {
+	for _, tc := range []struct {
+		code     int
+		restarts int
+	}{
+		{process.ExitPeerFailure, 1},
+		{4, 0},
+		{5, 0},
+	} {
+		bin := filepath.Join(t.TempDir(), "petrisim")
+		script := "#!/bin/sh\nexit " + strconv.Itoa(tc.code) + "\n"
+		if err := ioutil.WriteFile(bin, []byte(script), 0777); err != nil {
+			t.Fatal(err)
+		}
+		l := &Launcher{Binary: bin, Dir: t.TempDir(), Prefix: "net", Processes: 2, MaxRestarts: 1}
+		if err := l.Run(); err == nil {
+			t.Errorf("exit code %v: expected an error", tc.code)
+		}
+		if l.Restarts != tc.restarts {
+			t.Errorf("exit code %v: restarts = %v, want %v", tc.code, l.Restarts, tc.restarts)
+		}
+	}
+}
@@ -1,39 +1,198 @@
 package main
 
 import (
+	"centralsim"
+	"centralsim/colored"
+	"centralsim/petrinet"
+	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
 	"petrisim/helpers"
 	"petrisim/process"
 	"strconv"
 	"time"
 )
 
+// Código de salida cuando se detectan violaciones de la causalidad local
+const exitCausalityViolation = 4
+
+// Código de salida cuando la simulación se detiene por un bucle de
+// transiciones inmediatas que no deja avanzar el tiempo
+const exitVanishingLoop = 5
+
 /*
 This is where the distributed simulation begins, creating the Logic Process
 */
 func main() {
+	numberofCycles := flag.Int("cycles", 15, "ciclo en el que termina la simulación")
+	restoreDir := flag.String("restore", "", "directorio con los checkpoints desde los que continuar")
+	checkpointDir := flag.String("checkpoint", "", "directorio donde guardar el checkpoint al terminar")
+	snapshotDir := flag.String("snapshots", "snapshots", "directorio donde se guardan las instantáneas globales")
+	snapshotInterval := flag.Duration("snapshot-interval", 0, "intervalo entre instantáneas globales iniciadas por este proceso (0 desactiva)")
+	resumeDir := flag.String("resume", "", "directorio de la instantánea global desde la que reanudar")
+	heartbeat := flag.Duration("heartbeat", 500*time.Millisecond, "intervalo entre latidos a los demás procesos")
+	failureTimeout := flag.Duration("failure-timeout", 3*time.Second, "tiempo sin noticias de un proceso para darlo por caído")
+	faultSpec := flag.String("faults", "", "fallos de red a inyectar y espera antes de reenviar un mensaje, p.ej. \"seed=1,latency=2ms,jitter=1ms,reorder=0.1,duplicate=0.1,drop=0.1,partition=1@1s-2s,retransmit=50ms\"")
+	logConfig := centralsim.DefaultLogConfig()
+	flag.StringVar(&logConfig.Dir, "log-dir", logConfig.Dir, "directorio de los logs (vacío para no escribir ficheros)")
+	logLevel := flag.String("log-level", logConfig.Level.String(), "nivel de log: trace, debug, info, warn, error u off")
+	flag.StringVar(&logConfig.Format, "log-format", logConfig.Format, "formato de los logs: logfmt o json")
+	logStderr := flag.Bool("log-stderr", false, "escribe también los logs en la salida de error")
+	flag.StringVar(&logConfig.VectorClock, "vclock", logConfig.VectorClock, "reloj vectorial: off, builtin o govector (logs para ShiViz)")
+	metricsPort := flag.Int("metrics-port", 0, "puerto base de las métricas de Prometheus; cada proceso escucha en puerto+pid (0 desactiva)")
+	profileDir := flag.String("profile", "", "directorio donde guardar el perfil de tiempos del proceso al terminar")
+	flag.StringVar(&logConfig.Trace.Dir, "trace", "", "directorio donde escribir la traza <pid>.trace.json para chrome://tracing o Perfetto")
+	flag.StringVar(&logConfig.Trace.Endpoint, "otlp", "", "colector OpenTelemetry (OTLP/HTTP) al que enviar la traza, p.ej. http://localhost:4318")
+	flag.StringVar(&logConfig.Trace.ID, "trace-id", "", "identificador de la ejecución en OTLP, común a todos los procesos (por defecto el prefijo de la red)")
+	placesFile := flag.String("places", "", "red de lugares y transiciones de la que se compiló la Lefs, para registrar el marcado")
+	markingDir := flag.String("marking", "", "directorio donde guardar el registro del marcado del proceso al terminar (requiere -places)")
+	intervalPolicy := flag.String("interval-policy", "", "instante de disparo de las transiciones con intervalo: earliest, latest o uniform (por defecto el de la subred)")
+	intervalSeed := flag.Int64("interval-seed", 0, "semilla de la política uniform, la misma en todos los procesos")
+	colorsFile := flag.String("colors", "", "red coloreada de la que se compiló la Lefs, para ligar las variables de sus transiciones")
+	checkCausality := flag.Bool("check-causality", false, "comprueba la causalidad local: eventos rezagados, reloj monótono y LookAheads respetados")
+	path := flag.String("path", "", "directorio de network.json y de las redes de prueba (por defecto network.json en "+helpers.DefaultPath+" y las redes en el directorio de trabajo)")
+	flag.Parse()
+
 	// Obtain process data
-	args := os.Args[1:]
+	args := flag.Args()
 	subNetId := args[0]
 	networkFile := "network.json"
 	filePrefix := args[1]
+	configDir, testsDir := helpers.DefaultPath, ""
+	if *path != "" {
+		configDir, testsDir = *path, *path
+	}
 	transitionsFile := "tests/" + filePrefix + ".transitions.json"
-	petriFile := "tests/" + filePrefix + ".subred" + subNetId + ".json"
+	petriFile := filepath.Join(testsDir, "tests", filePrefix+".subred"+subNetId+".json")
 
 	index, err := strconv.Atoi(subNetId)
 	if err != nil {
 		panic("Invalid argument when creating process")
 	}
 
-	numberofCycles := 15 // leer de args?
+	level, err := centralsim.ParseLevel(*logLevel)
+	if err != nil {
+		panic(err)
+	}
+	logConfig.Level = level
+	if logConfig.Trace.ID == "" {
+		logConfig.Trace.ID = filePrefix
+	}
+	if *logStderr {
+		logConfig.Sinks = append(logConfig.Sinks, os.Stderr)
+	}
+
+	var faults *process.FaultConfig
+	if *faultSpec != "" {
+		config, err := process.ParseFaultConfig(*faultSpec)
+		if err != nil {
+			panic(err)
+		}
+		faults = &config
+	}
+
 	killChan := make(chan bool)
 
-	network := helpers.ReadNetConfig(networkFile)
-	transitionsMap := helpers.ReadNetTransitions(transitionsFile)
+	network := helpers.ReadNetConfig(configDir, networkFile)
+	transitionsMap := helpers.ReadNetTransitions(configDir, transitionsFile)
 
 	// create LP
-	lp := process.CreateLogicProcess(index, network, petriFile, transitionsMap, killChan)
+	lp := process.CreateLogicProcess(index, network, petriFile, transitionsMap, killChan, *snapshotDir, faults, logConfig)
+	if *restoreDir != "" {
+		if err := lp.RestoreCheckpoint(*restoreDir); err != nil {
+			panic(err)
+		}
+	}
+	if *resumeDir != "" {
+		if err := lp.RestoreSnapshot(*resumeDir); err != nil {
+			panic(err)
+		}
+	}
+	if *intervalPolicy != "" {
+		if err := lp.SetIntervalPolicy(*intervalPolicy, *intervalSeed); err != nil {
+			panic(err)
+		}
+	}
+	if *colorsFile != "" {
+		net, err := colored.Load(*colorsFile)
+		if err != nil {
+			panic(err)
+		}
+		model, err := colored.Compile(net)
+		if err != nil {
+			panic(err)
+		}
+		lp.SetColorRule(model)
+	}
+	if *checkCausality {
+		lp.CheckCausality()
+	}
+	if *placesFile != "" {
+		net, err := petrinet.Load(*placesFile)
+		if err != nil {
+			panic(err)
+		}
+		model, err := petrinet.Compile(net)
+		if err != nil {
+			panic(err)
+		}
+		lp.ObservePlaces(model.PlaceModel())
+	}
+	if *metricsPort > 0 {
+		if err := lp.ServeMetrics(":" + strconv.Itoa(*metricsPort+index)); err != nil {
+			fmt.Fprintf(os.Stderr, "metrics: %v\n", err)
+		}
+	}
+	failures := lp.StartFailureDetector(*heartbeat, *failureTimeout)
 	time.Sleep(1 * time.Second) // Espera a que los otros procesos sean creados
-	go lp.RunSimulation(numberofCycles)
-	<-killChan // Espera hasta terminar la simulación
+	if *snapshotInterval > 0 {
+		lp.StartSnapshots(*snapshotInterval)
+	}
+	go lp.RunSimulation(*numberofCycles)
+
+	select {
+	case <-killChan: // Espera hasta terminar la simulación
+	case p := <-failures:
+		fmt.Fprintf(os.Stderr, "PL%v caído, se abandona la simulación\n", p)
+		closeTrace(lp)
+		os.Exit(process.ExitPeerFailure)
+	}
+	closeTrace(lp)
+
+	if *checkpointDir != "" {
+		if err := lp.SaveCheckpoint(*checkpointDir); err != nil {
+			fmt.Fprintf(os.Stderr, "save checkpoint: %v\n", err)
+		}
+	}
+	profile := lp.Profile()
+	fmt.Println()
+	centralsim.WriteProfileReport(os.Stdout, []centralsim.Profile{profile})
+	if *profileDir != "" {
+		if err := process.SaveProfile(*profileDir, profile); err != nil {
+			fmt.Fprintf(os.Stderr, "save profile: %v\n", err)
+		}
+	}
+	if *placesFile != "" && *markingDir != "" {
+		if err := process.SaveMarkingLog(*markingDir, index, lp.MarkingLog()); err != nil {
+			fmt.Fprintf(os.Stderr, "save marking: %v\n", err)
+		}
+	}
+	if violations := lp.Violations(); len(violations) > 0 {
+		for _, v := range violations {
+			fmt.Fprintf(os.Stderr, "PL%v: %v\n", index, v)
+		}
+		os.Exit(exitCausalityViolation)
+	}
+	if err := lp.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "PL%v: %v\n", index, err)
+		os.Exit(exitVanishingLoop)
+	}
+}
+
+// Escribe o envía los eventos pendientes de la traza antes de salir
+func closeTrace(lp *process.LogicProcess) {
+	if err := lp.CloseTrace(); err != nil {
+		fmt.Fprintf(os.Stderr, "trace: %v\n", err)
+	}
 }
@@ -25,7 +25,7 @@ func TestMain(m *testing.M) {
 	// crear los 3 procesos
 	numProcesses, file := specialTest()
 
-	processes := helpers.ReadNetConfig(networkFile)
+	processes := helpers.ReadNetConfig(helpers.DefaultPath, networkFile)
 	for i, proc := range processes {
 		if i == numProcesses {
 			break
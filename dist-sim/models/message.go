@@ -1,15 +1,71 @@
 package models
 
-import "centralsim"
+import (
+	"centralsim"
+	"fmt"
+)
 
 const MsgLookAheadRequest = "LookAheadReq"
 const MsgLookAhead = "LookAhead"
 const MsgEvent = "Event"
 const MsgKill = "Kill"
+const MsgMarker = "Marker"
+const MsgHeartbeat = "Heartbeat"
+const MsgAck = "Ack"
 
 type Message struct {
-	MsgType string
-	Sender  int
-	Event   centralsim.Event
-	Time    centralsim.TypeClock
+	MsgType  string
+	Sender   int
+	Event    centralsim.Event
+	Time     centralsim.TypeClock
+	Snapshot SnapshotId // Identificador de la instantánea global en los mensajes Marker
+	Seq      uint64     // Número de secuencia en el enlace con el destino, 0 si no se numera
+}
+
+// SnapshotId identifica una instantánea global por el proceso que la inicia y
+// el número de secuencia que le asigna, de modo que no se confunden las que
+// inician a la vez procesos distintos
+type SnapshotId struct {
+	Initiator int `json:"iniciador"`
+	Seq       int `json:"secuencia"`
+}
+
+// String devuelve el identificador como p<iniciador>-<secuencia>, p.ej. "p1-3"
+func (id SnapshotId) String() string {
+	return fmt.Sprintf("p%v-%v", id.Initiator, id.Seq)
+}
+
+// ParseSnapshotId lee un identificador escrito con String
+func ParseSnapshotId(s string) (SnapshotId, error) {
+	id := SnapshotId{}
+	if _, err := fmt.Sscanf(s, "p%d-%d", &id.Initiator, &id.Seq); err != nil || id.String() != s {
+		return SnapshotId{}, fmt.Errorf("invalid snapshot id %q", s)
+	}
+	return id, nil
+}
+
+// Before indica si la instantánea id es anterior a other: la de mayor
+// secuencia es la más reciente, y a igual secuencia la del mayor iniciador
+func (id SnapshotId) Before(other SnapshotId) bool {
+	if id.Seq != other.Seq {
+		return id.Seq < other.Seq
+	}
+	return id.Initiator < other.Initiator
+}
+
+// String describe el mensaje en los logs de GoVector, p.ej. "LookAhead PL1 time=5"
+func (m Message) String() string {
+	s := fmt.Sprintf("%v PL%v", m.MsgType, m.Sender)
+	switch m.MsgType {
+	case MsgEvent:
+		s += fmt.Sprintf(" transition=%v cte=%v time=%v", m.Event.IiTransicion, m.Event.IiCte, m.Event.IiTiempo)
+	case MsgLookAhead, MsgLookAheadRequest:
+		s += fmt.Sprintf(" time=%v", m.Time)
+	case MsgMarker:
+		s += fmt.Sprintf(" snapshot=%v", m.Snapshot)
+	}
+	if m.Seq > 0 {
+		s += fmt.Sprintf(" seq=%v", m.Seq)
+	}
+	return s
 }
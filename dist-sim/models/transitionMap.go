@@ -1,7 +1,80 @@
 package models
 
+import "centralsim"
+
 type TransitionMap struct {
 	Transitions []int `json:"T"` // Transiciones contenidas por el proceso
 	Ancestors   []int `json:"A"` // Lista de procesos que preceden al actual
 	MinTime     int   `json:"M"` // Tiempo mínimo estimado para atravesar la subred
 }
+
+// FindProcess devuelve el proceso que contiene la transición global indicada, o -1
+func FindProcess(transitions []TransitionMap, globalId int) int {
+	for i, p := range transitions {
+		for _, t := range p.Transitions {
+			if t == globalId {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// MakeTransitionMaps calcula el mapa de transiciones de las subredes de una
+// partición: los precedentes de cada proceso son los que le envían eventos, y
+// su tiempo para atravesar la subred el mayor tiempo hasta la marca de sus
+// transiciones de salida, al menos 1
+func MakeTransitionMaps(subnets []centralsim.Lefs) []TransitionMap {
+	transitions := make([]TransitionMap, len(subnets))
+	for p, lefs := range subnets {
+		transitions[p].Transitions = []int{}
+		for _, t := range lefs.IaRed {
+			transitions[p].Transitions = append(transitions[p].Transitions, int(t.IiIndLocal))
+		}
+	}
+	for q, lefs := range subnets {
+		minTime := 0
+		for _, t := range lefs.IaRed {
+			targets := []int{}
+			for _, trCo := range t.TransConstPul {
+				targets = append(targets, trCo[0])
+			}
+			for _, trPlCo := range t.LugaresPul {
+				targets = append(targets, trPlCo[0])
+			}
+			for _, target := range targets {
+				if target >= 0 {
+					continue
+				}
+				p := FindProcess(transitions, -(target + 1))
+				if p >= 0 && p != q && !contains(transitions[p].Ancestors, q) {
+					transitions[p].Ancestors = append(transitions[p].Ancestors, q)
+				}
+			}
+			for _, l := range t.TiempoHastaMarca.LiTiempos {
+				if l > minTime {
+					minTime = l
+				}
+			}
+		}
+		if minTime < 1 {
+			minTime = 1
+		}
+		transitions[q].MinTime = minTime
+	}
+	for p := range transitions {
+		if transitions[p].Ancestors == nil {
+			transitions[p].Ancestors = []int{}
+		}
+	}
+	return transitions
+}
+
+func contains(list []int, x int) bool {
+	for _, y := range list {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
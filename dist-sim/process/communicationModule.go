@@ -2,106 +2,180 @@ package process
 
 import (
 	"centralsim"
-	"fmt"
-	"net"
-	"petrisim/helpers"
+	"errors"
+	"io"
 	"petrisim/models"
+	"sync"
+	"time"
 )
 
+// Tiempo máximo que se espera a que los demás procesos confirmen el fin
+const killFlushTimeout = 2 * time.Second
+
+// Transporte que puede esperar a que se confirmen los mensajes enviados
+type flusher interface {
+	Flush(timeout time.Duration) bool
+}
+
 type CommunicationModule struct {
 	pId                   int                    // Id del proceso
-	networkInfo           []models.ProcessInfo   // Información de toda la red de procesos
 	transitionsMap        []models.TransitionMap // Información de las transiciones en cada nodo
-	listener              net.Listener
+	transport             Transport              // Envío y recepción de mensajes
 	logger                *centralsim.Logger
 	outgoingEventCh       chan centralsim.Event          // Canal para envío de Eventos
 	incomingEventCh       chan centralsim.IncommingEvent // Canal para recibir eventos generados en otros procesos
+	incomingEventAckCh    chan bool                      // Confirma que el simulador insertó el evento recibido
 	reqLookAheadCh        chan centralsim.LookAhead      // Canal para solicitar LookAhead a proceso precedente
 	receiveLookAheadCh    chan centralsim.LookAhead      // Canal para recibir LookAhead de proceso precedente
 	receiveLookAheadReqCh chan centralsim.LookAhead      // Recibe solicitud de LookAhead de proceso posterior
 	sendLookAheadCh       chan centralsim.LookAhead      // Envía LookAhead propio a proceso posterior
 	killChan              chan bool
+	takeSnapshot          func(func(centralsim.Checkpoint)) // Toma el estado local del motor
+	partnerFinished       func(int)                         // Avisa al motor de que otro proceso terminó su simulación
+	markerCh              chan models.SnapshotId            // Marcadores que hay que enviar, en orden con los eventos
+	snapshots             snapshotTable                     // Instantáneas globales en curso
+	snapshotDir           string                            // Directorio donde se guardan las instantáneas
+	detector              failureDetector                   // Detector de fallos de los demás procesos
+	traffic               trafficCounters                   // Mensajes enviados y recibidos, para las métricas
+	termination           termination                       // Procesos que han terminado su simulación
+	activity              centralsim.Activity               // Rutinas del proceso con trabajo
+	done                  chan struct{}                     // Se cierra al detener el módulo
+	routines              sync.WaitGroup                    // Rutinas de envío y recepción
+}
+
+// Fin de la simulación: el proceso acaba cuando ha terminado su simulación y
+// todos los demás han avisado de que terminaron la suya, porque hasta entonces
+// pueden pedirle LookAheads
+type termination struct {
+	mux      sync.Mutex
+	finished map[int]bool // procesos que han terminado, incluido este
+	notified bool         // ya se avisó del fin por killChan
 }
 
 func CreateCommunicationModule(
 	pid int,
-	network []models.ProcessInfo,
+	transport Transport,
 	transitions []models.TransitionMap,
 	logger *centralsim.Logger,
 	sendEventCh chan centralsim.Event,
 	incomingEventCh chan centralsim.IncommingEvent,
+	incomingEventAckCh chan bool,
 	reqLookAheadCh chan centralsim.LookAhead,
 	receiveLACh chan centralsim.LookAhead,
 	receiveLAReqCh chan centralsim.LookAhead,
 	sendLookAheadCh chan centralsim.LookAhead,
 	killChan chan bool,
+	takeSnapshot func(func(centralsim.Checkpoint)),
+	partnerFinished func(int),
+	snapshotDir string,
+	activity centralsim.Activity,
 ) *CommunicationModule {
 
-	process := network[pid]
-	listener, err := net.Listen("tcp", ":"+process.Port)
-	if err != nil {
-		panic(fmt.Sprintf("Server listen error %v", err))
-	}
-
 	cm := CommunicationModule{
 		pId:                   pid,
-		networkInfo:           network,
 		transitionsMap:        transitions,
-		listener:              listener,
+		transport:             transport,
 		logger:                logger,
 		outgoingEventCh:       sendEventCh,
 		incomingEventCh:       incomingEventCh,
+		incomingEventAckCh:    incomingEventAckCh,
 		reqLookAheadCh:        reqLookAheadCh,
 		receiveLookAheadCh:    receiveLACh,
 		receiveLookAheadReqCh: receiveLAReqCh,
 		sendLookAheadCh:       sendLookAheadCh,
 		killChan:              killChan,
+		takeSnapshot:          takeSnapshot,
+		partnerFinished:       partnerFinished,
+		markerCh:              make(chan models.SnapshotId),
+		snapshots:             snapshotTable{recorders: make(map[models.SnapshotId]*snapshotRecorder)},
+		snapshotDir:           snapshotDir,
+		detector:              failureDetector{lastSeen: make(map[int]time.Time)},
+		traffic:               newTrafficCounters(),
+		termination:           termination{finished: make(map[int]bool)},
+		activity:              activity,
+		done:                  make(chan struct{}),
 	}
 
 	// Se lanzan rutinas para enviar y recibir mensajes
+	cm.routines.Add(2)
 	go cm.sender()
 	go cm.receiver()
 	return &cm
 }
 
+// Detiene las rutinas de envío y recepción, y las del transporte si se puede
+// cerrar. La recepción termina cuando el transporte devuelve ErrTransportClosed.
+func (comMod *CommunicationModule) stop() {
+	close(comMod.done)
+	comMod.routines.Wait()
+	if c, ok := comMod.transport.(io.Closer); ok {
+		c.Close()
+	}
+}
+
 // Rutina encargada de los mensajes que entran
 func (comMod *CommunicationModule) receiver() {
+	defer comMod.routines.Done()
 	for {
-		data := new(models.Message)
-		err := helpers.Receive(data, &comMod.listener, comMod.logger)
+		data, err := comMod.transport.Receive()
+		if errors.Is(err, ErrTransportClosed) {
+			return
+		}
 		if err != nil {
-			panic(err)
+			comMod.logger.Warn("Mensaje descartado", "err", err)
+			continue
 		}
 
+		comMod.detector.seen(data.Sender)
+		comMod.traffic.countReceived(data.MsgType, data.Sender)
+		comMod.logger.Tracer.Instant(centralsim.TraceCommunication, "Recibe "+data.MsgType, messageClock(data),
+			"process", data.Sender)
+
 		switch data.MsgType {
 		case models.MsgEvent: // Evento generado en otro proceso
-			comMod.logger.Event.Println(
-				fmt.Sprintf("EVENTO ENTRANTE DESDE PL%v, TRANSICIÓN: %v CTE: %v, TIEMPO: %v", data.Sender, data.Event.IiTransicion, data.Event.IiCte, data.Event.IiTiempo))
-			comMod.logger.GoVectLog(
-				fmt.Sprintf("EVENTO ENTRANTE DESDE PL%v, TRANSICIÓN: %v CTE: %v, TIEMPO: %v", data.Sender, data.Event.IiTransicion, data.Event.IiCte, data.Event.IiTiempo))
+			comMod.logger.Debug("Evento entrante", "process", data.Sender,
+				"transition", data.Event.IiTransicion, "cte", data.Event.IiCte, "time", data.Event.IiTiempo)
+			comMod.logger.GoVectLog("EVENTO ENTRANTE DESDE PL%v, TRANSICIÓN: %v CTE: %v, TIEMPO: %v",
+				data.Sender, data.Event.IiTransicion, data.Event.IiCte, data.Event.IiTiempo)
 			incommingEv := centralsim.IncommingEvent{Event: data.Event, ProcessId: data.Sender}
-			comMod.incomingEventCh <- incommingEv
+			comMod.deliverEvent(incommingEv)
 
 		case models.MsgLookAheadRequest: // Otro proceso solicita Lookhead
-			comMod.logger.Mark.Println(fmt.Sprintf("PL%v SOLICITA LOOKAHEAD", data.Sender))
-			comMod.logger.GoVectLog(fmt.Sprintf("PL%v Solicita LookAhead", data.Sender))
-			comMod.receiveLookAheadReqCh <- centralsim.LookAhead{Process: data.Sender, Time: data.Time}
+			comMod.logger.Debug("Solicitud de LookAhead", "process", data.Sender)
+			comMod.logger.GoVectLog("PL%v Solicita LookAhead", data.Sender)
+			comMod.activity.Add(1) // la rutina del motor que calcula el LookAhead
+			select {
+			case comMod.receiveLookAheadReqCh <- centralsim.LookAhead{Process: data.Sender, Time: data.Time}:
+			case <-comMod.done:
+				return
+			}
 
 		case models.MsgLookAhead: // Recibe el LookAhead de otro proceso
-			comMod.logger.GoVectLog(
-				fmt.Sprintf("Recibe LookAhead de PL%v, TIEMPO: %v", data.Sender, data.Time))
-			comMod.logger.Mark.Println(
-				fmt.Sprintf("Recibe LookAhead de PL%v, TIEMPO: %v", data.Sender, data.Time))
-			comMod.receiveLookAheadCh <- centralsim.LookAhead{Process: data.Sender, Time: data.Time}
-
-		case models.MsgKill: // Mensaje de que la simulación ha terminado
-			comMod.killChan <- true
+			comMod.logger.GoVectLog("Recibe LookAhead de PL%v, TIEMPO: %v", data.Sender, data.Time)
+			comMod.logger.Debug("Recibe LookAhead", "process", data.Sender, "lookahead", data.Time)
+			comMod.activity.Add(1) // la rutina del motor que lo espera
+			select {
+			case comMod.receiveLookAheadCh <- centralsim.LookAhead{Process: data.Sender, Time: data.Time}:
+			case <-comMod.done:
+				return
+			}
+
+		case models.MsgMarker: // Marcador de una instantánea global
+			comMod.logger.GoVectLog("Recibe marcador %v de PL%v", data.Snapshot, data.Sender)
+			comMod.receiveMarker(data.Snapshot, data.Sender)
+
+		case models.MsgHeartbeat: // Latido de otro proceso, ya registrado
+
+		case models.MsgKill: // Mensaje de que el emisor ha terminado su simulación
+			comMod.partnerFinished(data.Sender) // ya no enviará eventos
+			comMod.finish(data.Sender)
 		}
 	}
 }
 
 // Rutina encargada de enviar mensajes a los otros procesos
 func (comMod *CommunicationModule) sender() {
+	defer comMod.routines.Done()
 	for {
 		select {
 		case event := <-comMod.outgoingEventCh: // Evento que se debe propagar a otro PL
@@ -112,29 +186,33 @@ func (comMod *CommunicationModule) sender() {
 				panic("process not found")
 			}
 
-			proc := comMod.networkInfo[processId]
-			comMod.logger.Event.Println(
-				fmt.Sprintf("ENVIAR EVENTO A PL%v, TRANSICIÓN: %v CTE: %v, TIEMPO: %v", processId, event.IiTransicion, event.IiCte, event.IiTiempo))
-			comMod.logger.GoVectLog(
-				fmt.Sprintf("ENVIAR EVENTO A PL%v, TRANSICIÓN: %v CTE: %v, TIEMPO: %v", processId, event.IiTransicion, event.IiCte, event.IiTiempo))
-			helpers.Send(msg, proc.Ip+":"+proc.Port, comMod.logger)
+			comMod.logger.Debug("Envía evento", "process", processId,
+				"transition", event.IiTransicion, "cte", event.IiCte, "time", event.IiTiempo)
+			comMod.logger.GoVectLog("ENVIAR EVENTO A PL%v, TRANSICIÓN: %v CTE: %v, TIEMPO: %v",
+				processId, event.IiTransicion, event.IiCte, event.IiTiempo)
+			comMod.sendTo(processId, msg)
 
 		case la := <-comMod.reqLookAheadCh: // El simulador solicita un LookAhead a otro proceso
-			comMod.logger.Mark.Println(fmt.Sprintf("Solicita LookAhead a P%v", la.Process))
-			comMod.logger.GoVectLog(fmt.Sprintf("Solicita LookAhead a P%v", la.Process))
+			comMod.logger.Debug("Solicita LookAhead", "process", la.Process)
+			comMod.logger.GoVectLog("Solicita LookAhead a P%v", la.Process)
 			msg := models.Message{MsgType: models.MsgLookAheadRequest, Sender: comMod.pId, Time: la.Time}
 
 			// Enviar solicitud al proceso precedente
-			proc := comMod.networkInfo[la.Process]
-			helpers.Send(msg, proc.Ip+":"+proc.Port, comMod.logger)
+			comMod.sendTo(la.Process, msg)
 
 		case la := <-comMod.sendLookAheadCh: // El proceso envía LookAhead calculado al proceso que lo solicita
-			comMod.logger.GoVectLog(fmt.Sprintf("Envía LookAhead a P%v, con tiempo %v", la.Process, la.Time))
-			comMod.logger.Mark.Println(fmt.Sprintf("Envía LookAhead a P%v, con tiempo %v", la.Process, la.Time))
+			comMod.logger.GoVectLog("Envía LookAhead a P%v, con tiempo %v", la.Process, la.Time)
+			comMod.logger.Debug("Envía LookAhead", "process", la.Process, "lookahead", la.Time)
 			msg := models.Message{MsgType: models.MsgLookAhead, Time: la.Time, Sender: comMod.pId}
-			proc := comMod.networkInfo[la.Process]
-			helpers.Send(msg, proc.Ip+":"+proc.Port, comMod.logger)
+			comMod.sendTo(la.Process, msg)
+
+		case id := <-comMod.markerCh: // Se registró el estado local de una instantánea
+			comMod.sendMarker(id)
+
+		case <-comMod.done:
+			return
 		}
+		comMod.activity.Add(-1) // quien lo despertó sumó su actividad
 	}
 }
 
@@ -142,23 +220,65 @@ func (comMod *CommunicationModule) sender() {
 func (comMod *CommunicationModule) findProcessId(event *centralsim.Event) int {
 	globalId := -1 * (int(event.IiTransicion) + 1)
 	event.IiTransicion = centralsim.IndLocalTrans(globalId)
-	for i, p := range comMod.transitionsMap {
-		for _, t := range p.Transitions {
-			if t == globalId {
-				return i
-			}
-		}
-	}
-	return -1
+	return models.FindProcess(comMod.transitionsMap, globalId)
 }
 
-// Envía mensaje a otros procesos para terminar la tarea
+// Avisa a los demás procesos de que la simulación local ha terminado
 func (comMod *CommunicationModule) killProcesses() {
-	for i, proc := range comMod.networkInfo {
+	for i := range comMod.transitionsMap { // solo los procesos que participan en la simulación
 		if i != comMod.pId {
-			msg := models.Message{MsgType: models.MsgKill}
-			helpers.Send(msg, proc.Ip+":"+proc.Port, comMod.logger)
+			msg := models.Message{MsgType: models.MsgKill, Sender: comMod.pId}
+			comMod.sendTo(i, msg)
+		}
+	}
+	// el proceso puede terminar en cuanto avise por killChan, antes deben llegar los avisos
+	if f, ok := comMod.transport.(flusher); ok && !f.Flush(killFlushTimeout) {
+		comMod.logger.Warn("No se confirmó el aviso de fin a todos los procesos")
+	}
+	comMod.finish(comMod.pId)
+}
+
+// Registra que el proceso pid ha terminado su simulación. Cuando han
+// terminado todos deja de vigilar a los demás, que van a terminar y no es un
+// fallo, y avisa del fin por killChan.
+func (comMod *CommunicationModule) finish(pid int) {
+	t := &comMod.termination
+	t.mux.Lock()
+	t.finished[pid] = true
+	all := !t.notified
+	for i := range comMod.transitionsMap {
+		if !t.finished[i] {
+			all = false
 		}
 	}
-	comMod.killChan <- true
+	t.notified = t.notified || all
+	t.mux.Unlock()
+
+	if all {
+		comMod.logger.Info("Todos los procesos han terminado")
+		comMod.detector.stop()
+		comMod.killChan <- true
+	}
+}
+
+// Envía un mensaje al proceso indicado. El transporte reenvía los mensajes
+// hasta que se confirman; el detector de fallos decide si el proceso ha caído.
+func (comMod *CommunicationModule) sendTo(processId int, msg models.Message) error {
+	start := time.Now()
+	err := comMod.transport.Send(msg, processId)
+	comMod.traffic.countSent(msg.MsgType, processId, err)
+	comMod.logger.Tracer.Span(centralsim.TraceCommunication, "Envía "+msg.MsgType, start, messageClock(msg),
+		"process", processId)
+	if err != nil {
+		comMod.logger.Warn("No se pudo enviar", "type", msg.MsgType, "process", processId, "err", err)
+	}
+	return err
+}
+
+// Tiempo simulado que lleva un mensaje: el del evento o el del LookAhead
+func messageClock(msg models.Message) centralsim.TypeClock {
+	if msg.MsgType == models.MsgEvent {
+		return msg.Event.IiTiempo
+	}
+	return msg.Time
 }
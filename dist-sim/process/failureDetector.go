@@ -0,0 +1,99 @@
+package process
+
+import (
+	"petrisim/models"
+	"sync"
+	"time"
+)
+
+// Código de salida cuando otro proceso lógico ha caído; el lanzador reanuda
+// entonces la simulación desde la última instantánea global
+const ExitPeerFailure = 3
+
+// Detector de fallos basado en latidos: cualquier mensaje recibido de un
+// proceso cuenta como latido, y si un proceso no da señales durante el
+// tiempo límite se considera caído
+type failureDetector struct {
+	mux      sync.Mutex
+	lastSeen map[int]time.Time // último mensaje recibido de cada proceso
+	stopped  bool              // la simulación terminó, ya no se vigila
+}
+
+// Registra que el proceso sigue vivo
+func (fd *failureDetector) seen(processId int) {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+	if _, ok := fd.lastSeen[processId]; ok {
+		fd.lastSeen[processId] = time.Now()
+	}
+}
+
+// Deja de vigilar a los demás procesos
+func (fd *failureDetector) stop() {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+	fd.stopped = true
+}
+
+// Indica si ya no se vigila a los demás procesos
+func (fd *failureDetector) isStopped() bool {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+	return fd.stopped
+}
+
+// Devuelve un proceso que no ha dado señales desde hace más de timeout, o -1
+func (fd *failureDetector) suspect(timeout time.Duration) int {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+	if fd.stopped {
+		return -1
+	}
+	for p, t := range fd.lastSeen {
+		if time.Since(t) > timeout {
+			return p
+		}
+	}
+	return -1
+}
+
+// StartFailureDetector envía un latido a los demás procesos cada interval y
+// notifica por el canal devuelto el primer proceso que no responde en timeout
+func (comMod *CommunicationModule) StartFailureDetector(interval, timeout time.Duration) <-chan int {
+	failures := make(chan int, 1)
+
+	comMod.detector.mux.Lock()
+	for i := range comMod.transitionsMap {
+		if i != comMod.pId {
+			comMod.detector.lastSeen[i] = time.Now()
+		}
+	}
+	comMod.detector.mux.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-comMod.done:
+				return
+			}
+			if comMod.detector.isStopped() { // la simulación terminó
+				return
+			}
+			if p := comMod.detector.suspect(timeout); p != -1 {
+				comMod.logger.Error("Proceso caído", "process", p, "timeout", timeout)
+				comMod.detector.stop()
+				failures <- p
+				return
+			}
+			for i := range comMod.transitionsMap {
+				if i != comMod.pId {
+					comMod.sendTo(i, models.Message{MsgType: models.MsgHeartbeat, Sender: comMod.pId})
+				}
+			}
+		}
+	}()
+	return failures
+}
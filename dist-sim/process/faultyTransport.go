@@ -0,0 +1,203 @@
+package process
+
+import (
+	"fmt"
+	"math/rand"
+	"petrisim/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Partition separa a un grupo de procesos del resto durante un intervalo,
+// medido desde que se crea el transporte
+type Partition struct {
+	Processes []int
+	From      time.Duration
+	Until     time.Duration
+}
+
+// FaultConfig describe los fallos que inyecta el transporte de pruebas
+type FaultConfig struct {
+	Seed       int64         // semilla de las decisiones aleatorias
+	Latency    time.Duration // retardo de todos los mensajes
+	Jitter     time.Duration // retardo adicional aleatorio entre 0 y Jitter
+	Reorder    float64       // probabilidad de retener un mensaje para que lo adelanten los siguientes
+	Duplicate  float64       // probabilidad de enviar un mensaje dos veces
+	Drop       float64       // probabilidad de perder un mensaje
+	Partitions []Partition
+	Retransmit time.Duration // espera de la confirmación antes de reenviar, DefaultRetransmitInterval si es 0
+}
+
+// Transporte que envuelve a otro e inyecta retardos, desorden, duplicados,
+// pérdidas y particiones de red según una FaultConfig
+type faultyTransport struct {
+	pId     int
+	inner   Transport
+	config  FaultConfig
+	started time.Time
+	mux     sync.Mutex
+	random  *rand.Rand
+}
+
+// NewFaultyTransport envuelve inner, el transporte del proceso pid, con los
+// fallos indicados en config
+func NewFaultyTransport(pid int, inner Transport, config FaultConfig) Transport {
+	return &faultyTransport{
+		pId:     pid,
+		inner:   inner,
+		config:  config,
+		started: time.Now(),
+		random:  rand.New(rand.NewSource(config.Seed + int64(pid))),
+	}
+}
+
+// Indica si la comunicación con el proceso indicado está cortada
+func (t *faultyTransport) partitioned(processId int) bool {
+	elapsed := time.Since(t.started)
+	for _, p := range t.config.Partitions {
+		if elapsed < p.From || elapsed >= p.Until {
+			continue
+		}
+		if containsProcess(p.Processes, t.pId) != containsProcess(p.Processes, processId) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsProcess(processes []int, pid int) bool {
+	for _, p := range processes {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// Decide el destino de un mensaje: cuántas copias se envían y con qué retardo
+func (t *faultyTransport) plan() []time.Duration {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.random.Float64() < t.config.Drop {
+		return nil
+	}
+	copies := 1
+	if t.random.Float64() < t.config.Duplicate {
+		copies = 2
+	}
+	delays := make([]time.Duration, copies)
+	for i := range delays {
+		delays[i] = t.config.Latency
+		if t.config.Jitter > 0 {
+			delays[i] += time.Duration(t.random.Int63n(int64(t.config.Jitter)))
+		}
+		if t.random.Float64() < t.config.Reorder {
+			delays[i] += 2*(t.config.Latency+t.config.Jitter) + time.Millisecond
+		}
+	}
+	return delays
+}
+
+// Send aplica los fallos al mensaje. Los mensajes perdidos o retrasados no
+// devuelven error, igual que en una red real.
+func (t *faultyTransport) Send(msg models.Message, to int) error {
+	if t.partitioned(to) {
+		return nil
+	}
+	for _, delay := range t.plan() {
+		if delay == 0 {
+			if err := t.inner.Send(msg, to); err != nil {
+				return err
+			}
+			continue
+		}
+		go func(delay time.Duration) {
+			time.Sleep(delay)
+			t.inner.Send(msg, to)
+		}(delay)
+	}
+	return nil
+}
+
+// Receive descarta los mensajes que llegan desde el otro lado de una partición
+func (t *faultyTransport) Receive() (models.Message, error) {
+	for {
+		msg, err := t.inner.Receive()
+		if err != nil || !t.partitioned(msg.Sender) {
+			return msg, err
+		}
+	}
+}
+
+// ParseFaultConfig lee una configuración de fallos con el formato
+// "clave=valor,..." con las claves seed, latency, jitter, reorder, duplicate,
+// drop y partition. Una partición se escribe como procesos@desde-hasta, con
+// los procesos separados por '+', p.ej. "drop=0.1,partition=1+2@1s-3s".
+func ParseFaultConfig(spec string) (FaultConfig, error) {
+	config := FaultConfig{}
+	if spec == "" {
+		return config, nil
+	}
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return config, fmt.Errorf("invalid fault %q", field)
+		}
+		var err error
+		switch kv[0] {
+		case "seed":
+			config.Seed, err = strconv.ParseInt(kv[1], 10, 64)
+		case "latency":
+			config.Latency, err = time.ParseDuration(kv[1])
+		case "jitter":
+			config.Jitter, err = time.ParseDuration(kv[1])
+		case "reorder":
+			config.Reorder, err = strconv.ParseFloat(kv[1], 64)
+		case "duplicate":
+			config.Duplicate, err = strconv.ParseFloat(kv[1], 64)
+		case "drop":
+			config.Drop, err = strconv.ParseFloat(kv[1], 64)
+		case "retransmit":
+			config.Retransmit, err = time.ParseDuration(kv[1])
+		case "partition":
+			var p Partition
+			p, err = parsePartition(kv[1])
+			config.Partitions = append(config.Partitions, p)
+		default:
+			err = fmt.Errorf("unknown fault %q", kv[0])
+		}
+		if err != nil {
+			return config, fmt.Errorf("parse fault %q: %w", field, err)
+		}
+	}
+	return config, nil
+}
+
+// Lee una partición con el formato procesos@desde-hasta
+func parsePartition(spec string) (Partition, error) {
+	p := Partition{}
+	parts := strings.SplitN(spec, "@", 2)
+	if len(parts) != 2 {
+		return p, fmt.Errorf("expected processes@from-until")
+	}
+	for _, s := range strings.Split(parts[0], "+") {
+		pid, err := strconv.Atoi(s)
+		if err != nil {
+			return p, err
+		}
+		p.Processes = append(p.Processes, pid)
+	}
+	interval := strings.SplitN(parts[1], "-", 2)
+	if len(interval) != 2 {
+		return p, fmt.Errorf("expected processes@from-until")
+	}
+	var err error
+	if p.From, err = time.ParseDuration(interval[0]); err != nil {
+		return p, err
+	}
+	p.Until, err = time.ParseDuration(interval[1])
+	return p, err
+}
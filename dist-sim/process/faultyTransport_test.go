@@ -0,0 +1,72 @@
+package process
+
+import (
+	"petrisim/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFaultConfig(t *testing.T) {
+	config, err := ParseFaultConfig("seed=7,latency=2ms,jitter=1ms,reorder=0.1,duplicate=0.2,drop=0.3,partition=1+2@1s-3s,retransmit=20ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := FaultConfig{
+		Seed: 7, Latency: 2 * time.Millisecond, Jitter: time.Millisecond,
+		Reorder: 0.1, Duplicate: 0.2, Drop: 0.3,
+		Partitions: []Partition{{Processes: []int{1, 2}, From: time.Second, Until: 3 * time.Second}},
+		Retransmit: 20 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("got %+v, expected %+v", config, want)
+	}
+	for _, spec := range []string{"drop", "loss=0.1", "partition=1@1s", "latency=fast"} {
+		if _, err := ParseFaultConfig(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+// Transporte que solo guarda los mensajes enviados
+type recordingTransport struct {
+	sent chan models.Message
+}
+
+func (r recordingTransport) Send(msg models.Message, to int) error {
+	r.sent <- msg
+	return nil
+}
+
+func (r recordingTransport) Receive() (models.Message, error) {
+	select {}
+}
+
+func TestFaultyTransportIsSeeded(t *testing.T) {
+	config := FaultConfig{Seed: 5, Duplicate: 0.5, Drop: 0.3}
+	count := func() int {
+		inner := recordingTransport{sent: make(chan models.Message, 200)}
+		faulty := NewFaultyTransport(0, inner, config)
+		for i := 0; i < 100; i++ {
+			faulty.Send(models.Message{MsgType: models.MsgEvent}, 1)
+		}
+		return len(inner.sent)
+	}
+	first := count()
+	if first == 100 {
+		t.Fatal("no fault was injected")
+	}
+	if second := count(); second != first {
+		t.Errorf("same seed delivered %v and %v messages", first, second)
+	}
+}
+
+func TestPartitionDropsMessages(t *testing.T) {
+	inner := recordingTransport{sent: make(chan models.Message, 10)}
+	faulty := NewFaultyTransport(0, inner, FaultConfig{Partitions: []Partition{{Processes: []int{1}, Until: time.Hour}}})
+	faulty.Send(models.Message{MsgType: models.MsgEvent}, 1)
+	faulty.Send(models.Message{MsgType: models.MsgEvent}, 2)
+	if len(inner.sent) != 1 {
+		t.Errorf("expected only the message to PL2, %v were sent", len(inner.sent))
+	}
+}
@@ -2,69 +2,213 @@ package process
 
 import (
 	"centralsim"
+	"path/filepath"
 	"petrisim/models"
 	"strconv"
+	"time"
 )
 
 // This is the main structure
 type LogicProcess struct {
+	pId              int
 	simEngine        *centralsim.SimulationEngine
 	communicationMod *CommunicationModule
 }
 
-// Crea el contenedor del simulador y el módulo de comunicación
-func CreateLogicProcess(pid int, network []models.ProcessInfo, netFileName string, transitions []models.TransitionMap, killChan chan bool) *LogicProcess {
+// Crea el contenedor del simulador y el módulo de comunicación. Si faults no
+// es nil, la red inyecta los fallos indicados bajo la capa de retransmisión.
+func CreateLogicProcess(pid int, network []models.ProcessInfo, netFileName string, transitions []models.TransitionMap, killChan chan bool, snapshotDir string, faults *FaultConfig, logConfig centralsim.LogConfig) *LogicProcess {
 	lefs, err := centralsim.Load(netFileName)
 	if err != nil {
 		println("Couldn't load the Petri Net file !")
 	}
 
+	logger, err := centralsim.NewLogger(strconv.Itoa(pid), logConfig)
+	if err != nil {
+		panic(err)
+	}
+	transport, err := NewTCPTransport(pid, network, logger)
+	if err != nil {
+		panic(err)
+	}
+	retransmit := DefaultRetransmitInterval
+	if faults != nil {
+		transport = NewFaultyTransport(pid, transport, *faults)
+		if faults.Retransmit > 0 {
+			retransmit = faults.Retransmit
+		}
+	}
+	transport = NewReliableTransport(pid, transport, logger, retransmit)
+	return newLogicProcess(pid, lefs, transport, transitions, logger, killChan, snapshotDir, centralsim.NopActivity())
+}
+
+// Crea el proceso lógico pid de la subred lefs, comunicado por transport, que
+// cuenta sus rutinas con trabajo en activity
+func newLogicProcess(pid int, lefs centralsim.Lefs, transport Transport, transitions []models.TransitionMap, logger *centralsim.Logger, killChan chan bool, snapshotDir string, activity centralsim.Activity) *LogicProcess {
 	sendEventCh := make(chan centralsim.Event)              // Canal para enviar eventos
 	incomingEventCh := make(chan centralsim.IncommingEvent) // Canal para recibir eventos
+	incomingEventAckCh := make(chan bool)                   // Canal para confirmar la inserción de eventos recibidos
 	requestLookAheadCh := make(chan centralsim.LookAhead)   // Canal para enviar solicitud de LA
 	receiveLACh := make(chan centralsim.LookAhead)          // Canal para recibir LA solicitado a otro proceso
 	receiveLAReqCh := make(chan centralsim.LookAhead)       // Canal para recibir solicitud de LA
 	sendLookAheadCh := make(chan centralsim.LookAhead)      // Canal para enviar LA a otro proceso
 	maxLookAhead := centralsim.TypeClock(transitions[pid].MinTime)
 
+	// LookAhead de cada proceso posterior según las transiciones que le envían eventos
+	outLinks := lefs.OutboundLinks(func(globalId int) int {
+		return models.FindProcess(transitions, globalId)
+	}, maxLookAhead)
+
 	partnersLookAheads := make(map[int]centralsim.TypeClock)
 	for _, a := range transitions[pid].Ancestors { // crea el mapa de LookAheads solo con los predecesores
 		partnersLookAheads[a] = centralsim.TypeClock(0) // LookAheads se inicializan en cero
 	}
 
-	logger := centralsim.CreateLogger(strconv.Itoa(pid))
 	simEngine := centralsim.MakeSimulationEngine(
 		lefs,
 		logger,
 		sendEventCh,
 		incomingEventCh,
+		incomingEventAckCh,
 		requestLookAheadCh,
 		receiveLACh,
 		receiveLAReqCh,
 		sendLookAheadCh,
 		partnersLookAheads,
-		maxLookAhead)
+		maxLookAhead,
+		outLinks)
+	simEngine.SetActivity(activity)
 	comMod := CreateCommunicationModule(
 		pid,
-		network,
+		transport,
 		transitions,
 		logger,
 		sendEventCh,
 		incomingEventCh,
+		incomingEventAckCh,
 		requestLookAheadCh,
 		receiveLACh,
 		receiveLAReqCh,
 		sendLookAheadCh,
-		killChan)
+		killChan,
+		simEngine.Snapshot,
+		simEngine.AncestorFinished,
+		snapshotDir,
+		activity)
 	lp := LogicProcess{
+		pId:              pid,
 		simEngine:        simEngine,
 		communicationMod: comMod,
 	}
 	return &lp
 }
 
-// Here we run the local simulation
+// Here we run the local simulation, from the current clock until numberOfCycles
 func (LP *LogicProcess) RunSimulation(numberOfCycles int) {
-	LP.simEngine.SimularPeriodo(0, centralsim.TypeClock(numberOfCycles))
+	LP.simEngine.SimularPeriodo(LP.simEngine.Clock(), centralsim.TypeClock(numberOfCycles))
 	LP.communicationMod.killProcesses() // al terminar avisa a los demás procesos
 }
+
+// Detiene el motor y el módulo de comunicación. El transporte debe devolver
+// ErrTransportClosed para que termine la recepción.
+func (LP *LogicProcess) stop() {
+	LP.simEngine.Stop()
+	LP.communicationMod.stop()
+}
+
+// Fichero de checkpoint de este proceso dentro del directorio indicado
+func (LP *LogicProcess) checkpointFile(dir string) string {
+	return filepath.Join(dir, strconv.Itoa(LP.pId)+".json")
+}
+
+// SaveCheckpoint guarda el estado del simulador en dir/<pid>.json
+func (LP *LogicProcess) SaveCheckpoint(dir string) error {
+	return centralsim.SaveCheckpoint(LP.checkpointFile(dir), LP.simEngine.Checkpoint())
+}
+
+// RestoreCheckpoint carga el estado del simulador desde dir/<pid>.json
+func (LP *LogicProcess) RestoreCheckpoint(dir string) error {
+	cp, err := centralsim.LoadCheckpoint(LP.checkpointFile(dir))
+	if err != nil {
+		return err
+	}
+	return LP.simEngine.Restore(cp)
+}
+
+// RestoreSnapshot carga el estado del simulador desde la instantánea global del
+// directorio indicado y vuelve a entregar los eventos que estaban en tránsito
+func (LP *LogicProcess) RestoreSnapshot(dir string) error {
+	part, err := LoadSnapshotPart(dir, LP.pId)
+	if err != nil {
+		return err
+	}
+	if err := LP.simEngine.Restore(part.State); err != nil {
+		return err
+	}
+	// las siguientes instantáneas continúan la numeración, también la de las
+	// que no llegaron a completarse, para no mezclarse con sus partes
+	LP.communicationMod.snapshots.lastSeq = part.Snapshot.Seq
+	if ids, err := savedSnapshots(LP.communicationMod.snapshotDir); err == nil && len(ids) > 0 && ids[0].Seq > part.Snapshot.Seq {
+		LP.communicationMod.snapshots.lastSeq = ids[0].Seq
+	}
+	for _, ev := range part.Channels {
+		LP.communicationMod.deliverEvent(ev)
+	}
+	return nil
+}
+
+// CheckCausality activa la comprobación de la causalidad local del simulador
+func (LP *LogicProcess) CheckCausality() {
+	LP.simEngine.CheckCausality()
+}
+
+// Violations devuelve las violaciones de la causalidad local detectadas
+func (LP *LogicProcess) Violations() []centralsim.Violation {
+	return LP.simEngine.Violations()
+}
+
+// Err devuelve el error que detuvo la simulación, p.ej. un bucle de
+// transiciones inmediatas; nil si terminó con normalidad
+func (LP *LogicProcess) Err() error {
+	return LP.simEngine.Err()
+}
+
+// SetIntervalPolicy cambia la política de elección del instante de disparo de
+// las transiciones con intervalo, que debe ser la misma en todos los procesos
+func (LP *LogicProcess) SetIntervalPolicy(policy string, seed int64) error {
+	return LP.simEngine.SetIntervalPolicy(policy, seed)
+}
+
+// SetColorRule indica la regla que liga las variables de las transiciones
+// coloreadas de la subred, que no se guarda con la Lefs
+func (LP *LogicProcess) SetColorRule(rule centralsim.ColorRule) {
+	LP.simEngine.SetColorRule(rule)
+}
+
+// CloseTrace termina la traza del proceso, enviando los eventos pendientes
+func (LP *LogicProcess) CloseTrace() error {
+	return LP.simEngine.Log.Close()
+}
+
+// StartFailureDetector vigila a los demás procesos mediante latidos y devuelve
+// un canal por el que se notifica el primer proceso caído
+func (LP *LogicProcess) StartFailureDetector(interval, timeout time.Duration) <-chan int {
+	return LP.communicationMod.StartFailureDetector(interval, timeout)
+}
+
+// StartSnapshots inicia una instantánea global cada interval hasta que se
+// detiene el proceso
+func (LP *LogicProcess) StartSnapshots(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				LP.communicationMod.StartSnapshot()
+			case <-LP.communicationMod.done:
+				return
+			}
+		}
+	}()
+}
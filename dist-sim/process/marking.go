@@ -0,0 +1,53 @@
+package process
+
+import (
+	"centralsim"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ObservePlaces activa el registro del marcado de los lugares del modelo
+func (LP *LogicProcess) ObservePlaces(model centralsim.PlaceModel) {
+	LP.simEngine.ObservePlaces(model)
+}
+
+// MarkingLog devuelve los cambios del marcado debidos a los disparos del proceso
+func (LP *LogicProcess) MarkingLog() centralsim.MarkingLog {
+	return LP.simEngine.MarkingLog()
+}
+
+// SaveMarkingLog guarda el registro del marcado del proceso pid en dir/<pid>.json
+func SaveMarkingLog(dir string, pid int, log centralsim.MarkingLog) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(pid)+".json"), data, 0666)
+}
+
+// LoadMarkingLogs lee los registros del marcado de todos los procesos guardados en dir
+func LoadMarkingLogs(dir string) ([]centralsim.MarkingLog, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	logs := []centralsim.MarkingLog{}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var log centralsim.MarkingLog
+		if err := json.Unmarshal(data, &log); err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
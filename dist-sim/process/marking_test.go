@@ -0,0 +1,164 @@
+package process
+
+import (
+	"bytes"
+	"centralsim"
+	"centralsim/petrinet"
+	"fmt"
+	"petrisim/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Red de 2sub: una marca recorre t0, t2, t3 y t1; t0 y t1 están en PL0
+const ringOf2sub = `{
+  "places": [{"name": "p0", "initial": 1}, {"name": "p1"}, {"name": "p2"}, {"name": "p3"}],
+  "transitions": [
+    {"name": "t0", "duration": 1, "inputs": [{"place": "p0"}], "outputs": [{"place": "p2"}]},
+    {"name": "t1", "duration": 1, "inputs": [{"place": "p1"}], "outputs": [{"place": "p0"}]},
+    {"name": "t2", "duration": 1, "inputs": [{"place": "p2"}], "outputs": [{"place": "p3"}]},
+    {"name": "t3", "duration": 1, "inputs": [{"place": "p3"}], "outputs": [{"place": "p1"}]}
+  ]
+}`
+
+func TestDistributedMarking(t *testing.T) {
+	net, err := petrinet.Decode(strings.NewReader(ringOf2sub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := petrinet.Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vn, subnets := newVirtualNetwork(t, "2sub")
+
+	// la red compilada es la de las subredes unidas
+	merged := mergeSubnets(subnets)
+	for i, tr := range model.Lefs.IaRed {
+		want := merged.IaRed[i]
+		if tr.IiValorLef != want.IiValorLef || !reflect.DeepEqual(tr.TransConstIul, want.TransConstIul) ||
+			!reflect.DeepEqual(tr.TransConstPul, want.TransConstPul) {
+			t.Errorf("compiled %+v, expected %+v", tr, want)
+		}
+	}
+
+	for _, lp := range vn.processes {
+		lp.ObservePlaces(model.PlaceModel())
+	}
+	if err := vn.Run(virtualCycles, RandomOrder(7)); err != nil {
+		t.Fatal(err)
+	}
+	logs := []centralsim.MarkingLog{}
+	for _, lp := range vn.processes {
+		logs = append(logs, lp.MarkingLog())
+	}
+	log := centralsim.MergeMarkingLogs(logs)
+
+	// la marca está en un lugar o en tránsito, nunca hay más de una
+	log.Series(func(time centralsim.TypeClock, marking []int) {
+		total := 0
+		for _, tokens := range marking {
+			if tokens < 0 {
+				t.Fatalf("negative marking %v at %v", marking, time)
+			}
+			total += tokens
+		}
+		if total > 1 {
+			t.Fatalf("marking %v at %v has %v tokens", marking, time, total)
+		}
+	})
+	// cada disparo consume la marca; al estar siempre en tránsito, ningún
+	// lugar la mantiene durante un tiempo
+	consumed := 0
+	for _, c := range log.Changes {
+		if c.Delta < 0 {
+			consumed++
+		}
+	}
+	if fired := len(vn.Results()); consumed != fired {
+		t.Errorf("%v tokens consumed by %v firings", consumed, fired)
+	}
+	for _, s := range log.Statistics() {
+		if s.Average != 0 || s.Histogram[0] != log.End-log.Start {
+			t.Errorf("unexpected statistics %+v", s)
+		}
+	}
+}
+
+// Un generador en PL1 deja cada 2 ciclos una pieza en el almacén de PL0, que
+// se vacía cada 8 ciclos, y otra que PL0 le devuelve; el vigilante de PL0
+// trabaja cada ciclo salvo con 3 o más piezas en el almacén
+const inhibitedAcrossProcesses = `{
+  "places": [{"name": "fuente", "initial": 1}, {"name": "almacen"}, {"name": "turno", "initial": 1},
+    {"name": "reloj", "initial": 1}, {"name": "pieza"}],
+  "transitions": [
+    {"name": "genera", "duration": 1, "inputs": [{"place": "fuente"}], "outputs": [{"place": "almacen"}, {"place": "pieza"}]},
+    {"name": "vigila", "duration": 1, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}],
+      "inhibitors": [{"place": "almacen", "weight": 3}]},
+    {"name": "limpia", "duration": 8, "inputs": [{"place": "reloj"}], "outputs": [{"place": "reloj"}], "resets": ["almacen"]},
+    {"name": "devuelve", "duration": 1, "inputs": [{"place": "pieza"}], "outputs": [{"place": "fuente"}]}
+  ]
+}`
+
+func TestDistributedInhibitorAndResetArcs(t *testing.T) {
+	net, err := petrinet.Decode(strings.NewReader(inhibitedAcrossProcesses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := petrinet.Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subnets, err := model.Partition([]int{1, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// las piezas llegan a PL0 como eventos del lugar dirigidos a vigila
+	if genera := subnets[1].IaRed[0]; !genera.EsSalida || !reflect.DeepEqual(genera.LugaresPul, [][3]int{{-2, 1, 1}}) {
+		t.Errorf("unexpected outbound transition %+v", genera)
+	}
+	transitions := []models.TransitionMap{
+		{Transitions: []int{1, 2, 3}, Ancestors: []int{1}, MinTime: 2},
+		{Transitions: []int{0}, Ancestors: []int{0}, MinTime: 2},
+	}
+	vn := NewVirtualNetwork(subnets, transitions)
+	defer vn.Close()
+	vn.MaxSteps = 20000
+	for _, lp := range vn.processes {
+		lp.ObservePlaces(model.PlaceModel())
+	}
+	if err := vn.Run(virtualCycles, RandomOrder(3)); err != nil {
+		t.Fatal(err)
+	}
+	if violations := vn.Violations(); len(violations) > 0 {
+		t.Fatalf("causality violations %v", violations)
+	}
+
+	want := SortedFirings(SimulateSequential(subnets, virtualCycles), virtualCycles-20)
+	got := SortedFirings(vn.Results(), virtualCycles-20)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("distributed firings %v, expected %v", got, want)
+	}
+	if counts := model.Firings(got); counts["vigila"] != 50 || counts["limpia"] != 10 {
+		t.Errorf("unexpected firings %v", counts)
+	}
+
+	// el almacén llega a 4 piezas antes de vaciarse, con el vigilante inhibido
+	logs := []centralsim.MarkingLog{}
+	for _, lp := range vn.processes {
+		logs = append(logs, lp.MarkingLog())
+	}
+	if s := centralsim.MergeMarkingLogs(logs).Statistics()[1]; s.Min != 0 || s.Max != 4 {
+		t.Errorf("unexpected statistics of almacen %+v", s)
+	}
+
+	// PL0 solo publica el marcado de los lugares que no toca PL1
+	var metrics bytes.Buffer
+	vn.processes[0].WriteMetrics(&metrics)
+	for place, exported := range map[string]bool{"turno": true, "reloj": true, "almacen": false, "pieza": false, "fuente": false} {
+		if got := strings.Contains(metrics.String(), fmt.Sprintf("petrisim_place_tokens{place=%q}", place)); got != exported {
+			t.Errorf("place %v exported = %v, want %v", place, got, exported)
+		}
+	}
+}
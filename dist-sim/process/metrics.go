@@ -0,0 +1,150 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Mensajes de un tipo intercambiados con otro proceso
+type trafficKey struct {
+	msgType string
+	peer    int
+}
+
+// Contadores de los mensajes enviados y recibidos por el módulo de comunicación
+type trafficCounters struct {
+	mux        sync.Mutex
+	sent       map[trafficKey]uint64
+	received   map[trafficKey]uint64
+	sendErrors map[int]uint64 // envíos fallidos por proceso destino
+}
+
+func newTrafficCounters() trafficCounters {
+	return trafficCounters{
+		sent:       make(map[trafficKey]uint64),
+		received:   make(map[trafficKey]uint64),
+		sendErrors: make(map[int]uint64),
+	}
+}
+
+func (tc *trafficCounters) countSent(msgType string, peer int, err error) {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+	tc.sent[trafficKey{msgType, peer}]++
+	if err != nil {
+		tc.sendErrors[peer]++
+	}
+}
+
+func (tc *trafficCounters) countReceived(msgType string, peer int) {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+	tc.received[trafficKey{msgType, peer}]++
+}
+
+// ServeMetrics publica las métricas del proceso en http://addr/metrics con el
+// formato de texto de Prometheus, y su estado en http://addr/status para el
+// panel de control. Devuelve error si no puede escuchar en addr.
+func (LP *LogicProcess) ServeMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", LP.MetricsHandler())
+	mux.Handle("/status", LP.StatusHandler())
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// MetricsHandler responde con las métricas actuales del proceso
+func (LP *LogicProcess) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		LP.WriteMetrics(w)
+	})
+}
+
+// WriteMetrics escribe las métricas actuales del simulador y de la comunicación
+func (LP *LogicProcess) WriteMetrics(w io.Writer) {
+	stats := LP.simEngine.Stats()
+	m := metricWriter{w}
+	m.single("petrisim_clock", "gauge", "Reloj local simulado", float64(stats.Clock))
+	m.single("petrisim_events_processed_total", "counter", "Eventos tratados por el simulador", stats.EventsProcessed)
+	m.single("petrisim_transitions_fired_total", "counter", "Transiciones disparadas", float64(stats.TransitionsFired))
+	m.single("petrisim_event_list_length", "gauge", "Eventos pendientes en la lista de eventos", float64(stats.EventListLength))
+	m.header("petrisim_blocked_seconds_total", "counter", "Tiempo bloqueado esperando eventos o LookAheads de otros procesos")
+	m.sample("petrisim_blocked_seconds_total", `reason="event"`, stats.BlockedEvent.Seconds())
+	m.sample("petrisim_blocked_seconds_total", `reason="lookahead"`, stats.BlockedLookAhead.Seconds())
+
+	// Solo se publican los lugares que no tocan otros procesos, del resto el
+	// proceso solo conoce los cambios debidos a sus propios disparos
+	if marking := LP.simEngine.Marking(); marking != nil {
+		places := LP.simEngine.MarkingLog().Places
+		local := LP.simEngine.LocalPlaces()
+		m.header("petrisim_place_tokens", "gauge", "Marcas de cada lugar que solo tocan transiciones de este proceso")
+		for p, tokens := range marking {
+			if local[p] {
+				m.sample("petrisim_place_tokens", fmt.Sprintf("place=%q", places[p]), float64(tokens))
+			}
+		}
+	}
+
+	traffic := &LP.communicationMod.traffic
+	traffic.mux.Lock()
+	defer traffic.mux.Unlock()
+	m.traffic("petrisim_messages_sent_total", "Mensajes enviados por tipo y proceso destino", traffic.sent)
+	m.traffic("petrisim_messages_received_total", "Mensajes recibidos por tipo y proceso origen", traffic.received)
+	m.header("petrisim_send_errors_total", "counter", "Envíos fallidos por proceso destino")
+	peers := make([]int, 0, len(traffic.sendErrors))
+	for p := range traffic.sendErrors {
+		peers = append(peers, p)
+	}
+	sort.Ints(peers)
+	for _, p := range peers {
+		m.sample("petrisim_send_errors_total", fmt.Sprintf(`peer="%v"`, p), float64(traffic.sendErrors[p]))
+	}
+}
+
+// Escribe métricas en el formato de texto de Prometheus
+type metricWriter struct {
+	w io.Writer
+}
+
+func (m metricWriter) header(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+func (m metricWriter) sample(name, labels string, value float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	fmt.Fprintf(m.w, "%v %v\n", name, value)
+}
+
+func (m metricWriter) single(name, kind, help string, value float64) {
+	m.header(name, kind, help)
+	m.sample(name, "", value)
+}
+
+// Contadores de mensajes ordenados por tipo y proceso
+func (m metricWriter) traffic(name, help string, counters map[trafficKey]uint64) {
+	m.header(name, "counter", help)
+	keys := make([]trafficKey, 0, len(counters))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].msgType != keys[j].msgType {
+			return keys[i].msgType < keys[j].msgType
+		}
+		return keys[i].peer < keys[j].peer
+	})
+	for _, k := range keys {
+		m.sample(name, fmt.Sprintf(`type=%q,peer="%v"`, k.msgType, k.peer), float64(counters[k]))
+	}
+}
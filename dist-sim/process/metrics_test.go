@@ -0,0 +1,51 @@
+package process
+
+import (
+	"centralsim"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	vn, _ := newVirtualNetwork(t, "2sub")
+	if err := vn.Run(virtualCycles, RoundRobinOrder()); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(vn.processes[0].MetricsHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := string(body)
+
+	for _, want := range []string{
+		"# TYPE petrisim_clock gauge\npetrisim_clock 100\n",
+		"# TYPE petrisim_transitions_fired_total counter\npetrisim_transitions_fired_total ",
+		`petrisim_blocked_seconds_total{reason="lookahead"} `,
+		`petrisim_messages_sent_total{type="Event",peer="1"} `,
+		`petrisim_messages_sent_total{type="Kill",peer="1"} 1`,
+		`petrisim_messages_received_total{type="LookAheadReq",peer="1"} `,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics do not contain %q:\n%v", want, metrics)
+		}
+	}
+	if strings.Contains(metrics, "petrisim_transitions_fired_total 0\n") {
+		t.Errorf("no transitions fired:\n%v", metrics)
+	}
+
+	status := vn.processes[0].Status()
+	if status.State != centralsim.StateFinished || status.Clock != virtualCycles || status.MessagesSent == 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
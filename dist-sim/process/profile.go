@@ -0,0 +1,52 @@
+package process
+
+import (
+	"centralsim"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Profile devuelve el reparto del tiempo del proceso entre cálculo y sincronización
+func (LP *LogicProcess) Profile() centralsim.Profile {
+	p := LP.simEngine.Profile()
+	p.Process = LP.pId
+	return p
+}
+
+// SaveProfile guarda el perfil en dir/<pid>.json
+func SaveProfile(dir string, p centralsim.Profile) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(p.Process)+".json"), data, 0666)
+}
+
+// LoadProfiles lee los perfiles de todos los procesos guardados en dir
+func LoadProfiles(dir string) ([]centralsim.Profile, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	profiles := []centralsim.Profile{}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var p centralsim.Profile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Process < profiles[j].Process })
+	return profiles, nil
+}
@@ -0,0 +1,50 @@
+package process
+
+import (
+	"bytes"
+	"centralsim"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfileReport(t *testing.T) {
+	vn, _ := newVirtualNetwork(t, "3sub")
+	if err := vn.Run(virtualCycles, RoundRobinOrder()); err != nil {
+		t.Fatal(err)
+	}
+	profiles := vn.Profiles()
+	for pid, p := range profiles {
+		if p.Process != pid || p.Requests() == 0 || p.ClockAdvances == 0 || p.TransitionsFired == 0 {
+			t.Errorf("unexpected profile %+v", p)
+		}
+		if p.Synchronization() > p.Wall || p.Efficiency() < 0 || p.Efficiency() > 1 {
+			t.Errorf("PL%v: synchronization %v over wall time %v", pid, p.Synchronization(), p.Wall)
+		}
+	}
+
+	total := centralsim.AggregateProfiles(profiles)
+	if total.Requests() != profiles[0].Requests()+profiles[1].Requests()+profiles[2].Requests() {
+		t.Errorf("aggregated %v requests", total.Requests())
+	}
+	var out bytes.Buffer
+	centralsim.WriteProfileReport(&out, profiles)
+	if !strings.Contains(out.String(), "total") || !strings.Contains(out.String(), "peticiones de LookAhead") {
+		t.Errorf("unexpected report:\n%v", out.String())
+	}
+
+	// Los perfiles guardados se leen ordenados por proceso
+	dir := t.TempDir()
+	for i := len(profiles) - 1; i >= 0; i-- {
+		if err := SaveProfile(dir, profiles[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, profiles) {
+		t.Errorf("loaded %+v, expected %+v", loaded, profiles)
+	}
+}
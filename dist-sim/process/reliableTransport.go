@@ -0,0 +1,220 @@
+package process
+
+import (
+	"centralsim"
+	"petrisim/models"
+	"sync"
+	"time"
+)
+
+// DefaultRetransmitInterval es el tiempo que se espera la confirmación de un
+// mensaje antes de volver a enviarlo
+const DefaultRetransmitInterval = 200 * time.Millisecond
+
+// Mensaje enviado del que aún no ha llegado la confirmación
+type pendingMessage struct {
+	msg    models.Message
+	to     int
+	sentAt time.Time
+}
+
+// Estado de la comunicación con otro proceso
+type reliableLink struct {
+	nextSeq   uint64                     // último número de secuencia asignado a un envío
+	unacked   map[uint64]*pendingMessage // mensajes enviados sin confirmar
+	delivered uint64                     // último número de secuencia entregado en orden
+	early     map[uint64]models.Message  // mensajes recibidos antes que alguno anterior
+}
+
+// Transporte que numera los mensajes de cada enlace, confirma su recepción,
+// reenvía los que no se confirman y descarta los duplicados, de modo que cada
+// mensaje se entrega exactamente una vez y en el orden en que se envió aunque
+// el transporte subyacente pierda, duplique o desordene mensajes.
+// Los latidos y las confirmaciones no se numeran.
+type reliableTransport struct {
+	pId        int
+	inner      Transport
+	logger     *centralsim.Logger
+	retransmit time.Duration
+	mux        sync.Mutex
+	links      map[int]*reliableLink
+	ready      []models.Message // mensajes listos para entregar, en orden
+	done       chan struct{}    // Se cierra al cerrar el transporte
+	closeOnce  sync.Once
+	routines   sync.WaitGroup
+}
+
+// NewReliableTransport envuelve inner para el proceso pid y reenvía cada
+// retransmit los mensajes no confirmados
+func NewReliableTransport(pid int, inner Transport, logger *centralsim.Logger, retransmit time.Duration) Transport {
+	t := &reliableTransport{
+		pId:        pid,
+		inner:      inner,
+		logger:     logger,
+		retransmit: retransmit,
+		links:      make(map[int]*reliableLink),
+		done:       make(chan struct{}),
+	}
+	t.routines.Add(1)
+	go t.retransmitter()
+	return t
+}
+
+// Close detiene los reenvíos y espera a que termine la rutina que los hace.
+// El transporte subyacente no se cierra.
+func (t *reliableTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.routines.Wait()
+	})
+	return nil
+}
+
+// Los mensajes que se pueden perder sin consecuencias no se numeran
+func sequenced(msg models.Message) bool {
+	return msg.MsgType != models.MsgAck && msg.MsgType != models.MsgHeartbeat
+}
+
+// Estado del enlace con el proceso indicado. Se llama con el mutex bloqueado.
+func (t *reliableTransport) link(processId int) *reliableLink {
+	l, ok := t.links[processId]
+	if !ok {
+		l = &reliableLink{
+			unacked: make(map[uint64]*pendingMessage),
+			early:   make(map[uint64]models.Message),
+		}
+		t.links[processId] = l
+	}
+	return l
+}
+
+// Send numera el mensaje y lo envía. Si el envío falla no se devuelve error,
+// el mensaje se reenviará hasta que el destino lo confirme.
+func (t *reliableTransport) Send(msg models.Message, to int) error {
+	if !sequenced(msg) {
+		return t.inner.Send(msg, to)
+	}
+	t.mux.Lock()
+	l := t.link(to)
+	l.nextSeq++
+	msg.Seq = l.nextSeq
+	l.unacked[msg.Seq] = &pendingMessage{msg: msg, to: to, sentAt: time.Now()}
+	t.mux.Unlock()
+
+	if err := t.inner.Send(msg, to); err != nil {
+		t.logger.Warn("Se reenviará el mensaje", "seq", msg.Seq, "process", to, "err", err)
+	}
+	return nil
+}
+
+// Receive devuelve el siguiente mensaje en orden, confirmando los numerados y
+// descartando los duplicados
+func (t *reliableTransport) Receive() (models.Message, error) {
+	for {
+		t.mux.Lock()
+		if len(t.ready) > 0 {
+			msg := t.ready[0]
+			t.ready = t.ready[1:]
+			t.mux.Unlock()
+			return msg, nil
+		}
+		t.mux.Unlock()
+
+		msg, err := t.inner.Receive()
+		if err != nil {
+			return msg, err
+		}
+		if msg.MsgType == models.MsgAck {
+			t.acknowledged(msg.Sender, msg.Seq)
+			continue
+		}
+		if !sequenced(msg) {
+			return msg, nil
+		}
+		// Se confirma también un duplicado, la confirmación anterior pudo perderse
+		ack := models.Message{MsgType: models.MsgAck, Sender: t.pId, Seq: msg.Seq}
+		if err := t.inner.Send(ack, msg.Sender); err != nil {
+			t.logger.Warn("No se pudo confirmar el mensaje", "seq", msg.Seq, "process", msg.Sender, "err", err)
+		}
+		t.accept(msg)
+	}
+}
+
+// Elimina un mensaje confirmado de los pendientes
+func (t *reliableTransport) acknowledged(processId int, seq uint64) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	delete(t.link(processId).unacked, seq)
+}
+
+// Guarda un mensaje numerado y pasa a la cola de entrega los que ya están en orden
+func (t *reliableTransport) accept(msg models.Message) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	l := t.link(msg.Sender)
+	if _, ok := l.early[msg.Seq]; ok || msg.Seq <= l.delivered {
+		t.logger.Debug("Mensaje duplicado descartado", "seq", msg.Seq, "process", msg.Sender)
+		return
+	}
+	l.early[msg.Seq] = msg
+	for {
+		next, ok := l.early[l.delivered+1]
+		if !ok {
+			return
+		}
+		delete(l.early, next.Seq)
+		l.delivered++
+		t.ready = append(t.ready, next)
+	}
+}
+
+// Flush espera a que se confirmen todos los mensajes enviados, como mucho
+// durante timeout. Devuelve false si quedan mensajes sin confirmar.
+func (t *reliableTransport) Flush(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		t.mux.Lock()
+		pending := 0
+		for _, l := range t.links {
+			pending += len(l.unacked)
+		}
+		t.mux.Unlock()
+		if pending == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Rutina que reenvía los mensajes que no se han confirmado a tiempo
+func (t *reliableTransport) retransmitter() {
+	defer t.routines.Done()
+	ticker := time.NewTicker(t.retransmit / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.done:
+			return
+		}
+		t.mux.Lock()
+		late := []pendingMessage{}
+		for _, l := range t.links {
+			for _, p := range l.unacked {
+				if time.Since(p.sentAt) >= t.retransmit {
+					p.sentAt = time.Now()
+					late = append(late, *p)
+				}
+			}
+		}
+		t.mux.Unlock()
+
+		for _, p := range late {
+			t.inner.Send(p.msg, p.to)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package process
+
+import (
+	"centralsim"
+	"io"
+	"petrisim/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Red en memoria que pierde el primer envío de cada mensaje numerado y
+// duplica los demás, invirtiendo el orden de entrega de las copias
+type lossyNetwork struct {
+	mux    sync.Mutex
+	inbox  map[int]chan models.Message
+	copies map[uint64]int
+}
+
+type lossyEndpoint struct {
+	net *lossyNetwork
+	pid int
+}
+
+func (e lossyEndpoint) Send(msg models.Message, to int) error {
+	e.net.mux.Lock()
+	defer e.net.mux.Unlock()
+	if msg.Seq != 0 && msg.MsgType != models.MsgAck {
+		e.net.copies[msg.Seq]++
+		if e.net.copies[msg.Seq] == 1 {
+			return nil // se pierde
+		}
+		go func() {
+			time.Sleep(time.Millisecond)
+			e.net.inbox[to] <- msg
+		}()
+	}
+	e.net.inbox[to] <- msg
+	return nil
+}
+
+func (e lossyEndpoint) Receive() (models.Message, error) {
+	return <-e.net.inbox[e.pid], nil
+}
+
+func TestReliableTransportDeliversExactlyOnceInOrder(t *testing.T) {
+	network := &lossyNetwork{
+		inbox:  map[int]chan models.Message{0: make(chan models.Message, 100), 1: make(chan models.Message, 100)},
+		copies: make(map[uint64]int),
+	}
+	sender := NewReliableTransport(0, lossyEndpoint{network, 0}, centralsim.NopLogger("0"), 10*time.Millisecond)
+	receiver := NewReliableTransport(1, lossyEndpoint{network, 1}, centralsim.NopLogger("0"), 10*time.Millisecond)
+	go func() { // el emisor necesita recibir las confirmaciones
+		for {
+			sender.Receive()
+		}
+	}()
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		sender.Send(models.Message{MsgType: models.MsgEvent, Sender: 0, Time: centralsim.TypeClock(i)}, 1)
+	}
+	for i := 0; i < n; i++ {
+		msg, err := receiver.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Time != centralsim.TypeClock(i) {
+			t.Fatalf("message %v delivered with time %v", i, msg.Time)
+		}
+	}
+
+	// Ningún duplicado llega después de los mensajes esperados
+	extra := make(chan models.Message)
+	go func() {
+		msg, _ := receiver.Receive()
+		extra <- msg
+	}()
+	select {
+	case msg := <-extra:
+		t.Fatalf("unexpected duplicate %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHeartbeatsAreNotSequenced(t *testing.T) {
+	network := &lossyNetwork{
+		inbox:  map[int]chan models.Message{1: make(chan models.Message, 10)},
+		copies: make(map[uint64]int),
+	}
+	sender := NewReliableTransport(0, lossyEndpoint{network, 0}, centralsim.NopLogger("0"), time.Hour)
+	sender.Send(models.Message{MsgType: models.MsgHeartbeat, Sender: 0}, 1)
+	if msg := <-network.inbox[1]; msg.Seq != 0 {
+		t.Errorf("heartbeat sent with sequence number %v", msg.Seq)
+	}
+}
+
+// Transporte que pierde todos los mensajes y cuenta los envíos
+type countingEndpoint struct {
+	mux   sync.Mutex
+	sends int
+}
+
+func (e *countingEndpoint) Send(msg models.Message, to int) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.sends++
+	return nil
+}
+
+func (e *countingEndpoint) Receive() (models.Message, error) {
+	return models.Message{}, ErrTransportClosed
+}
+
+func (e *countingEndpoint) count() int {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.sends
+}
+
+func TestCloseStopsRetransmissions(t *testing.T) {
+	inner := &countingEndpoint{}
+	sender := NewReliableTransport(0, inner, centralsim.NopLogger("0"), 2*time.Millisecond)
+	sender.Send(models.Message{MsgType: models.MsgEvent, Sender: 0}, 1)
+	time.Sleep(20 * time.Millisecond)
+	if inner.count() < 2 {
+		t.Fatalf("message sent %v times, expected retransmissions", inner.count())
+	}
+
+	sender.(io.Closer).Close()
+	sends := inner.count()
+	time.Sleep(20 * time.Millisecond)
+	if inner.count() != sends {
+		t.Errorf("%v retransmissions after Close", inner.count()-sends)
+	}
+}
@@ -0,0 +1,117 @@
+package process
+
+import (
+	"centralsim"
+	"encoding/json"
+	"io/ioutil"
+	"petrisim/models"
+	"sort"
+	"strconv"
+)
+
+// LoadSubnets lee las subredes <prefix>.subred<i>.json de los procesos 0..processes-1
+func LoadSubnets(prefix string, processes int) ([]centralsim.Lefs, error) {
+	subnets := make([]centralsim.Lefs, processes)
+	for pid := range subnets {
+		lefs, err := centralsim.Load(prefix + ".subred" + strconv.Itoa(pid) + ".json")
+		if err != nil {
+			return nil, err
+		}
+		subnets[pid] = lefs
+	}
+	return subnets, nil
+}
+
+// SaveSubnets escribe las subredes de una partición como <prefix>.subred<i>.json
+// y su mapa de transiciones como <prefix>.transitions.json
+func SaveSubnets(prefix string, subnets []centralsim.Lefs, transitions []models.TransitionMap) error {
+	for pid, lefs := range subnets {
+		data, err := json.MarshalIndent(lefs, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(prefix+".subred"+strconv.Itoa(pid)+".json", data, 0666); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(transitions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(prefix+".transitions.json", data, 0666)
+}
+
+// Une las subredes en una sola red: los eventos hacia transiciones de otra
+// subred pasan a ser eventos locales. La política de los intervalos y la regla
+// de las transiciones coloreadas son las de la primera subred
+func mergeSubnets(subnets []centralsim.Lefs) centralsim.Lefs {
+	net := centralsim.Lefs{IsTransSensib: centralsim.MakeTransitionStack()}
+	if len(subnets) > 0 {
+		net.PoliticaIntervalos, net.SemillaIntervalos = subnets[0].PoliticaIntervalos, subnets[0].SemillaIntervalos
+		net.Colores = subnets[0].Colores
+	}
+	for _, lefs := range subnets {
+		for _, tr := range lefs.IaRed {
+			pul := make([][2]int, len(tr.TransConstPul))
+			for i, p := range tr.TransConstPul {
+				if p[0] < 0 {
+					p[0] = -(p[0] + 1)
+				}
+				pul[i] = p
+			}
+			tr.TransConstPul = pul
+			places := make([][3]int, len(tr.LugaresPul))
+			for i, p := range tr.LugaresPul {
+				if p[0] < 0 {
+					p[0] = -(p[0] + 1)
+				}
+				places[i] = p
+			}
+			tr.LugaresPul = places
+			net.IaRed = append(net.IaRed, tr)
+		}
+		net.IaLugares = append(net.IaLugares, lefs.IaLugares...)
+	}
+	return net
+}
+
+// SimulateSequential simula la red completa formada por las subredes en un
+// único motor hasta el ciclo indicado y devuelve los disparos. Sirve de
+// referencia para comprobar la simulación distribuida.
+func SimulateSequential(subnets []centralsim.Lefs, cycles int) []centralsim.ResultadoTransition {
+	engine := centralsim.MakeSimulationEngine(
+		mergeSubnets(subnets),
+		centralsim.NopLogger("sequential"),
+		make(chan centralsim.Event),
+		make(chan centralsim.IncommingEvent),
+		make(chan bool),
+		make(chan centralsim.LookAhead),
+		make(chan centralsim.LookAhead),
+		make(chan centralsim.LookAhead),
+		make(chan centralsim.LookAhead),
+		map[int]centralsim.TypeClock{},
+		0,
+		nil)
+	defer engine.Stop()
+	engine.SimularPeriodo(0, centralsim.TypeClock(cycles))
+	return engine.Checkpoint().Results
+}
+
+// SortedFirings devuelve los disparos anteriores al tiempo indicado ordenados
+// por tiempo y transición, para comparar ejecuciones que registran en distinto
+// orden los disparos de un mismo instante
+func SortedFirings(results []centralsim.ResultadoTransition, before centralsim.TypeClock) []centralsim.ResultadoTransition {
+	sorted := []centralsim.ResultadoTransition{}
+	for _, r := range results {
+		if r.ValorRelojDisparo < before {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ValorRelojDisparo != sorted[j].ValorRelojDisparo {
+			return sorted[i].ValorRelojDisparo < sorted[j].ValorRelojDisparo
+		}
+		return sorted[i].CodTransition < sorted[j].CodTransition
+	})
+	return sorted
+}
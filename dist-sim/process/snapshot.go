@@ -0,0 +1,220 @@
+package process
+
+import (
+	"centralsim"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"petrisim/models"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// SnapshotPart es la parte de una instantánea global que guarda cada proceso:
+// el estado de su motor y los eventos que estaban en tránsito hacia él
+type SnapshotPart struct {
+	Snapshot models.SnapshotId           `json:"snapshot"`
+	Process  int                         `json:"proceso"`
+	State    centralsim.Checkpoint       `json:"estado"`
+	Channels []centralsim.IncommingEvent `json:"canales"`
+}
+
+// Instantánea en curso en este proceso
+type snapshotRecorder struct {
+	part    SnapshotPart
+	pending map[int]bool // canales de entrada de los que aún no ha llegado el marcador
+}
+
+// Tabla de instantáneas en curso (algoritmo de Chandy-Lamport).
+// El mutex se mantiene mientras se entrega un evento al motor, de modo que
+// cada evento queda o bien en el estado local o bien en el estado del canal.
+type snapshotTable struct {
+	mux       sync.Mutex
+	recorders map[models.SnapshotId]*snapshotRecorder
+	lastSeq   int // mayor secuencia de las instantáneas conocidas por este proceso
+}
+
+// Toma el estado local de la instantánea id y pide al emisor que envíe el
+// marcador por todos los canales de salida. from es el proceso cuyo marcador
+// la inicia, -1 si es local. Se llama con el mutex de la tabla bloqueado.
+func (comMod *CommunicationModule) recordLocalState(id models.SnapshotId, from int) *snapshotRecorder {
+	rec := &snapshotRecorder{
+		part:    SnapshotPart{Snapshot: id, Process: comMod.pId},
+		pending: make(map[int]bool),
+	}
+	for i := range comMod.transitionsMap {
+		if i != comMod.pId && i != from {
+			rec.pending[i] = true
+		}
+	}
+	// El motor entrega los eventos al emisor con su mutex bloqueado, igual que
+	// al registrar el estado, así que el marcador que se entrega al emisor en
+	// ese momento va detrás de los eventos anteriores al estado y delante de
+	// los que el motor genera después
+	comMod.takeSnapshot(func(cp centralsim.Checkpoint) {
+		rec.part.State = cp
+		comMod.activity.Add(1) // el emisor envía el marcador
+		select {
+		case comMod.markerCh <- id:
+		case <-comMod.done:
+		}
+	})
+	if id.Seq > comMod.snapshots.lastSeq {
+		comMod.snapshots.lastSeq = id.Seq
+	}
+	comMod.snapshots.recorders[id] = rec
+	comMod.logger.Info("Estado local registrado", "snapshot", id)
+	return rec
+}
+
+// StartSnapshot inicia una instantánea global desde este proceso y devuelve su
+// identificador, o false si este proceso aún está registrando otra instantánea.
+// La secuencia sigue a la mayor conocida, así que las más recientes tienen
+// mayor secuencia aunque las inicien procesos distintos.
+func (comMod *CommunicationModule) StartSnapshot() (models.SnapshotId, bool) {
+	comMod.snapshots.mux.Lock()
+	defer comMod.snapshots.mux.Unlock()
+
+	if len(comMod.snapshots.recorders) > 0 {
+		return models.SnapshotId{}, false
+	}
+	id := models.SnapshotId{Initiator: comMod.pId, Seq: comMod.snapshots.lastSeq + 1}
+	rec := comMod.recordLocalState(id, -1)
+	comMod.completeSnapshot(rec)
+	return id, true
+}
+
+// Trata el marcador de la instantánea id recibido desde el proceso from
+func (comMod *CommunicationModule) receiveMarker(id models.SnapshotId, from int) {
+	comMod.snapshots.mux.Lock()
+	defer comMod.snapshots.mux.Unlock()
+
+	rec, ok := comMod.snapshots.recorders[id]
+	if !ok { // primer marcador: el canal de entrada queda vacío
+		rec = comMod.recordLocalState(id, from)
+	}
+	delete(rec.pending, from)
+	comMod.completeSnapshot(rec)
+}
+
+// Entrega un evento al motor y lo registra en los canales aún abiertos
+func (comMod *CommunicationModule) deliverEvent(ev centralsim.IncommingEvent) {
+	comMod.snapshots.mux.Lock()
+	defer comMod.snapshots.mux.Unlock()
+
+	comMod.activity.Add(1) // la rutina del motor que inserta el evento
+	select {
+	case comMod.incomingEventCh <- ev:
+	case <-comMod.done:
+		return
+	}
+	select {
+	case <-comMod.incomingEventAckCh:
+	case <-comMod.done:
+		return
+	}
+	for _, rec := range comMod.snapshots.recorders {
+		if rec.pending[ev.ProcessId] {
+			rec.part.Channels = append(rec.part.Channels, ev)
+		}
+	}
+}
+
+// Escribe la parte local cuando han llegado los marcadores de todos los canales.
+// Se llama con el mutex de la tabla bloqueado.
+func (comMod *CommunicationModule) completeSnapshot(rec *snapshotRecorder) {
+	if len(rec.pending) > 0 {
+		return
+	}
+	delete(comMod.snapshots.recorders, rec.part.Snapshot)
+
+	if err := SaveSnapshotPart(comMod.snapshotDir, rec.part); err != nil {
+		comMod.logger.Error("No se pudo guardar la instantánea", "snapshot", rec.part.Snapshot, "err", err)
+		return
+	}
+	comMod.logger.Info("Instantánea completa", "snapshot", rec.part.Snapshot)
+}
+
+// Envía el marcador de la instantánea id por todos los canales de salida
+func (comMod *CommunicationModule) sendMarker(id models.SnapshotId) {
+	comMod.logger.GoVectLog("Envía marcador %v", id)
+	for i := range comMod.transitionsMap {
+		if i != comMod.pId {
+			msg := models.Message{MsgType: models.MsgMarker, Sender: comMod.pId, Snapshot: id}
+			comMod.sendTo(i, msg)
+		}
+	}
+}
+
+// Directorio de una instantánea dentro del directorio base
+func snapshotSetDir(baseDir string, id models.SnapshotId) string {
+	return filepath.Join(baseDir, id.String())
+}
+
+// Instantáneas guardadas en el directorio base, de la más reciente a la más antigua
+func savedSnapshots(baseDir string) ([]models.SnapshotId, error) {
+	entries, err := ioutil.ReadDir(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	ids := []models.SnapshotId{}
+	for _, e := range entries {
+		if id, err := models.ParseSnapshotId(e.Name()); err == nil && e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[j].Before(ids[i]) })
+	return ids, nil
+}
+
+// SaveSnapshotPart escribe la parte de un proceso en <baseDir>/<snapshot>/<pid>.json
+func SaveSnapshotPart(baseDir string, part SnapshotPart) error {
+	dir := snapshotSetDir(baseDir, part.Snapshot)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(part, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(part.Process)+".json"), data, 0666)
+}
+
+// LoadSnapshotPart lee la parte del proceso pid del directorio de una instantánea
+func LoadSnapshotPart(dir string, pid int) (SnapshotPart, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, strconv.Itoa(pid)+".json"))
+	if err != nil {
+		return SnapshotPart{}, err
+	}
+	part := SnapshotPart{}
+	if err := json.Unmarshal(data, &part); err != nil {
+		return SnapshotPart{}, fmt.Errorf("decode snapshot part: %w", err)
+	}
+	return part, nil
+}
+
+// LatestSnapshot devuelve el directorio de la última instantánea completa,
+// es decir, la de mayor secuencia con la parte de todos los procesos
+func LatestSnapshot(baseDir string, processes int) (string, error) {
+	ids, err := savedSnapshots(baseDir)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range ids {
+		dir := snapshotSetDir(baseDir, id)
+		complete := true
+		for pid := 0; pid < processes; pid++ {
+			if _, err := os.Stat(filepath.Join(dir, strconv.Itoa(pid)+".json")); err != nil {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no complete snapshot in %v", baseDir)
+}
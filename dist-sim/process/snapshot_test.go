@@ -0,0 +1,76 @@
+package process
+
+import (
+	"centralsim"
+	"path/filepath"
+	"petrisim/models"
+	"testing"
+)
+
+func TestLatestSnapshotSkipsIncompleteSets(t *testing.T) {
+	base := t.TempDir()
+	first, second, third := models.SnapshotId{Initiator: 0, Seq: 1}, models.SnapshotId{Initiator: 1, Seq: 2}, models.SnapshotId{Initiator: 0, Seq: 3}
+	for _, part := range []SnapshotPart{
+		{Snapshot: first, Process: 0}, {Snapshot: first, Process: 1},
+		{Snapshot: second, Process: 0}, {Snapshot: second, Process: 1},
+		{Snapshot: third, Process: 0}, // el proceso 1 no llegó a completar la tercera
+	} {
+		if err := SaveSnapshotPart(base, part); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, err := LatestSnapshot(base, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(base, "p1-2") {
+		t.Errorf("expected snapshot p1-2, got %v", dir)
+	}
+	if _, err := LatestSnapshot(base, 3); err == nil {
+		t.Error("expected error when no snapshot has every process")
+	}
+}
+
+// Las instantáneas que inician a la vez dos procesos no se mezclan
+func TestSnapshotsOfDifferentInitiators(t *testing.T) {
+	base := t.TempDir()
+	for _, part := range []SnapshotPart{
+		{Snapshot: models.SnapshotId{Initiator: 0, Seq: 1}, Process: 0, State: centralsim.Checkpoint{Clock: 4}},
+		{Snapshot: models.SnapshotId{Initiator: 1, Seq: 1}, Process: 0, State: centralsim.Checkpoint{Clock: 5}},
+	} {
+		if err := SaveSnapshotPart(base, part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for dir, clock := range map[string]centralsim.TypeClock{"p0-1": 4, "p1-1": 5} {
+		part, err := LoadSnapshotPart(filepath.Join(base, dir), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.State.Clock != clock {
+			t.Errorf("%v: clock %v, expected %v", dir, part.State.Clock, clock)
+		}
+	}
+}
+
+func TestSnapshotPartRoundTrip(t *testing.T) {
+	base := t.TempDir()
+	part := SnapshotPart{
+		Snapshot: models.SnapshotId{Initiator: 0, Seq: 4},
+		Process:  1,
+		State:    centralsim.Checkpoint{Version: centralsim.CheckpointVersion, Clock: 12},
+		Channels: []centralsim.IncommingEvent{{Event: centralsim.Event{IiTiempo: 13, IiTransicion: 2, IiCte: -1}, ProcessId: 0}},
+	}
+	if err := SaveSnapshotPart(base, part); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSnapshotPart(filepath.Join(base, "p0-4"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.State.Clock != 12 || len(loaded.Channels) != 1 || loaded.Channels[0].Event.IiTiempo != 13 {
+		t.Errorf("unexpected snapshot part: %+v", loaded)
+	}
+}
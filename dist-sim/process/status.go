@@ -0,0 +1,54 @@
+package process
+
+import (
+	"centralsim"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Status es el estado de un proceso lógico que consulta el panel de control
+type Status struct {
+	Process int
+	centralsim.EngineStatus
+	MessagesSent     uint64
+	MessagesReceived uint64
+}
+
+// Status devuelve el estado actual del simulador y el total de mensajes
+func (LP *LogicProcess) Status() Status {
+	status := Status{Process: LP.pId, EngineStatus: LP.simEngine.Status()}
+	traffic := &LP.communicationMod.traffic
+	traffic.mux.Lock()
+	defer traffic.mux.Unlock()
+	for _, n := range traffic.sent {
+		status.MessagesSent += n
+	}
+	for _, n := range traffic.received {
+		status.MessagesReceived += n
+	}
+	return status
+}
+
+// StatusHandler responde con el estado del proceso en JSON
+func (LP *LogicProcess) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(LP.Status())
+	})
+}
+
+// FetchStatus consulta el estado publicado por un proceso en url
+func FetchStatus(client *http.Client, url string) (Status, error) {
+	var status Status
+	resp, err := client.Get(url)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("status: %v", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return status, err
+}
@@ -0,0 +1,48 @@
+package process
+
+import (
+	"centralsim"
+	"errors"
+	"fmt"
+	"net"
+	"petrisim/helpers"
+	"petrisim/models"
+)
+
+// ErrTransportClosed es el error de Receive cuando el transporte se ha cerrado
+// y ya no recibirá más mensajes
+var ErrTransportClosed = errors.New("transport closed")
+
+// Transport es el medio por el que el módulo de comunicación intercambia
+// mensajes con los demás procesos lógicos
+type Transport interface {
+	Send(msg models.Message, to int) error // envía msg al proceso to
+	Receive() (models.Message, error)      // espera el siguiente mensaje
+}
+
+// Transporte TCP: una conexión por mensaje, con el reloj vectorial del logger
+type tcpTransport struct {
+	networkInfo []models.ProcessInfo // Información de toda la red de procesos
+	listener    net.Listener
+	logger      *centralsim.Logger
+}
+
+// NewTCPTransport escucha en el puerto del proceso pid definido en network
+func NewTCPTransport(pid int, network []models.ProcessInfo, logger *centralsim.Logger) (Transport, error) {
+	listener, err := net.Listen("tcp", ":"+network[pid].Port)
+	if err != nil {
+		return nil, fmt.Errorf("server listen error: %w", err)
+	}
+	return &tcpTransport{networkInfo: network, listener: listener, logger: logger}, nil
+}
+
+func (t *tcpTransport) Send(msg models.Message, to int) error {
+	proc := t.networkInfo[to]
+	return helpers.Send(msg, proc.Ip+":"+proc.Port, t.logger)
+}
+
+func (t *tcpTransport) Receive() (models.Message, error) {
+	msg := models.Message{}
+	err := helpers.Receive(&msg, &t.listener, t.logger)
+	return msg, err
+}
@@ -0,0 +1,44 @@
+package process
+
+import (
+	"centralsim"
+	"fmt"
+	"net"
+	"petrisim/models"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Un evento con muchas fichas coloreadas, mayor que cualquier lectura del
+// socket, llega entero por TCP
+func TestTCPTransportCarriesLargeEvents(t *testing.T) {
+	network := []models.ProcessInfo{{Name: "0", Ip: "127.0.0.1", Port: "0"}}
+	transport, err := NewTCPTransport(0, network, centralsim.NopLogger("0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := transport.(*tcpTransport).listener
+	defer listener.Close()
+	network[0].Port = strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	tokens := make([]centralsim.Ficha, 2000)
+	for i := range tokens {
+		tokens[i] = centralsim.Ficha(fmt.Sprintf(`[%v,"A",0]`, i))
+	}
+	sent := models.Message{MsgType: models.MsgEvent, Sender: 0, Seq: 7,
+		Event: centralsim.Event{IiTiempo: 3, IiTransicion: -2, IiCte: 1, IaFichas: tokens}}
+	errs := make(chan error, 1)
+	go func() { errs <- transport.Send(sent, 0) }()
+
+	got, err := transport.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, sent) {
+		t.Errorf("received %v with %v tokens, expected %v tokens", got, len(got.Event.IaFichas), len(tokens))
+	}
+}
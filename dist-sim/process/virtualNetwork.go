@@ -0,0 +1,349 @@
+package process
+
+import (
+	"centralsim"
+	"fmt"
+	"math/rand"
+	"petrisim/models"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PendingMessage es un mensaje enviado por la red virtual que aún no se ha entregado
+type PendingMessage struct {
+	From  int
+	To    int
+	Index int // posición del mensaje entre los enviados por el enlace From -> To
+	Msg   models.Message
+}
+
+// DeliveryOrder elige cuál de los mensajes candidatos se entrega en el
+// siguiente paso. Los candidatos son el primer mensaje pendiente de cada
+// enlace, ordenados por origen y destino, porque los enlaces son FIFO.
+type DeliveryOrder func(candidates []PendingMessage) int
+
+// RoundRobinOrder entrega por turnos un mensaje de cada enlace con mensajes pendientes
+func RoundRobinOrder() DeliveryOrder {
+	last := [2]int{-1, -1}
+	return func(candidates []PendingMessage) int {
+		next := 0
+		for i, m := range candidates {
+			if m.From > last[0] || (m.From == last[0] && m.To > last[1]) {
+				next = i
+				break
+			}
+		}
+		last = [2]int{candidates[next].From, candidates[next].To}
+		return next
+	}
+}
+
+// RandomOrder entrega un candidato al azar según la semilla indicada
+func RandomOrder(seed int64) DeliveryOrder {
+	random := rand.New(rand.NewSource(seed))
+	return func(candidates []PendingMessage) int {
+		return random.Intn(len(candidates))
+	}
+}
+
+// VirtualNetwork ejecuta todos los procesos lógicos de una simulación dentro
+// de este programa, comunicados por una red simulada. Un único planificador
+// entrega los mensajes de uno en uno, y solo cuando todos los procesos esperan
+// mensajes nuevos: los motores y los módulos de comunicación cuentan sus
+// rutinas con trabajo, y los extremos de la red restan la rutina receptora
+// mientras espera. Así el orden de entrega, elegido por un DeliveryOrder,
+// determina por completo la ejecución y una misma semilla reproduce la misma
+// intercalación. Los procesos comprueban la causalidad local durante la
+// ejecución.
+type VirtualNetwork struct {
+	MaxSteps    int              // número máximo de mensajes entregados, 0 sin límite
+	IdleTimeout time.Duration    // tiempo real máximo que se espera a que los procesos queden a la espera
+	Trace       []PendingMessage // mensajes entregados, en orden
+
+	processes []*LogicProcess
+	killChans []chan bool
+	inboxes   []chan models.Message
+	stopped   []bool
+	activity  *activityCounter
+	running   sync.WaitGroup // simulaciones en curso
+	closed    chan struct{}  // se cierra con Close
+	closeOnce sync.Once
+	mux       sync.Mutex
+	pending   []PendingMessage
+	sent      map[[2]int]int // mensajes enviados por cada enlace
+}
+
+// Tiempo por defecto que se espera a que los procesos queden a la espera
+const defaultIdleTimeout = 10 * time.Second
+
+// Cuenta de las rutinas con trabajo de todos los procesos de la red virtual
+type activityCounter struct {
+	mux   sync.Mutex
+	idle  *sync.Cond
+	count int
+}
+
+func newActivityCounter() *activityCounter {
+	a := &activityCounter{}
+	a.idle = sync.NewCond(&a.mux)
+	return a
+}
+
+func (a *activityCounter) Add(delta int) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.count += delta
+	if a.count <= 0 {
+		a.idle.Broadcast()
+	}
+}
+
+// Espera a que ninguna rutina tenga trabajo; devuelve false si no ocurre en timeout
+func (a *activityCounter) wait(timeout time.Duration) bool {
+	expired := false
+	timer := time.AfterFunc(timeout, func() {
+		a.mux.Lock()
+		defer a.mux.Unlock()
+		expired = true
+		a.idle.Broadcast()
+	})
+	defer timer.Stop()
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	for a.count > 0 && !expired {
+		a.idle.Wait()
+	}
+	return a.count <= 0
+}
+
+// Extremo de la red virtual de un proceso. Solo lo usa la rutina receptora
+type virtualEndpoint struct {
+	network  *VirtualNetwork
+	pid      int
+	handling bool // la rutina receptora está tratando un mensaje entregado
+}
+
+// Send deja el mensaje pendiente hasta que el planificador lo entregue
+func (e *virtualEndpoint) Send(msg models.Message, to int) error {
+	e.network.mux.Lock()
+	defer e.network.mux.Unlock()
+	link := [2]int{e.pid, to}
+	e.network.sent[link]++
+	e.network.pending = append(e.network.pending,
+		PendingMessage{From: e.pid, To: to, Index: e.network.sent[link], Msg: msg})
+	return nil
+}
+
+// Receive avisa de que la rutina receptora terminó de tratar el mensaje
+// anterior y espera al siguiente; la actividad de la rutina la suma el
+// planificador al entregarlo
+func (e *virtualEndpoint) Receive() (models.Message, error) {
+	if e.handling {
+		e.handling = false
+		e.network.activity.Add(-1)
+	}
+	select {
+	case msg := <-e.network.inboxes[e.pid]:
+		e.handling = true
+		return msg, nil
+	case <-e.network.closed:
+		return models.Message{}, ErrTransportClosed
+	}
+}
+
+// NewVirtualNetwork crea un proceso lógico por cada subred, con el mapa de
+// transiciones indicado, conectados por la red virtual. Las subredes no se
+// modifican, así que pueden usarse también para la simulación secuencial.
+// Los procesos se detienen con Close.
+func NewVirtualNetwork(subnets []centralsim.Lefs, transitions []models.TransitionMap) *VirtualNetwork {
+	vn := &VirtualNetwork{
+		IdleTimeout: defaultIdleTimeout,
+		processes:   make([]*LogicProcess, len(subnets)),
+		killChans:   make([]chan bool, len(subnets)),
+		inboxes:     make([]chan models.Message, len(subnets)),
+		stopped:     make([]bool, len(subnets)),
+		activity:    newActivityCounter(),
+		closed:      make(chan struct{}),
+		sent:        make(map[[2]int]int),
+	}
+	for pid, lefs := range subnets {
+		// el motor modifica las transiciones, las subredes recibidas no cambian
+		lefs.IaRed = append(centralsim.TransitionList{}, lefs.IaRed...)
+		lefs.IaLugares = append([]centralsim.Lugar{}, lefs.IaLugares...)
+		// el proceso avisa una vez del fin, cuando han terminado todos
+		vn.killChans[pid] = make(chan bool, 1)
+		vn.inboxes[pid] = make(chan models.Message, 1)
+		vn.processes[pid] = newLogicProcess(pid, lefs, &virtualEndpoint{network: vn, pid: pid}, transitions,
+			centralsim.NopLogger(strconv.Itoa(pid)), vn.killChans[pid], "", vn.activity)
+		vn.processes[pid].CheckCausality()
+	}
+	return vn
+}
+
+// Run simula hasta el ciclo indicado entregando los mensajes en el orden que
+// elige order. Termina cuando todos los procesos han acabado, o con error si
+// alguno acaba antes del ciclo indicado, si los procesos quedan bloqueados sin
+// mensajes que entregar o si se supera MaxSteps.
+func (vn *VirtualNetwork) Run(cycles int, order DeliveryOrder) error {
+	for _, lp := range vn.processes {
+		vn.activity.Add(1) // la simulación del proceso
+		vn.running.Add(1)
+		go func(lp *LogicProcess) {
+			defer vn.running.Done()
+			lp.RunSimulation(cycles)
+			vn.activity.Add(-1)
+		}(lp)
+	}
+	for {
+		if !vn.activity.wait(vn.IdleTimeout) {
+			return fmt.Errorf("processes still busy after %v, running processes: %v", vn.IdleTimeout, vn.runningProcesses())
+		}
+		vn.updateStopped()
+		if vn.allStopped() {
+			return vn.checkFinished(cycles)
+		}
+
+		candidates := vn.candidates()
+		if len(candidates) == 0 {
+			return fmt.Errorf("deadlock after %v deliveries, running processes: %v", len(vn.Trace), vn.runningProcesses())
+		}
+		if vn.MaxSteps > 0 && len(vn.Trace) >= vn.MaxSteps {
+			return fmt.Errorf("no termination after %v deliveries, running processes: %v", len(vn.Trace), vn.runningProcesses())
+		}
+
+		next := candidates[order(candidates)]
+		vn.remove(next)
+		vn.Trace = append(vn.Trace, next)
+		vn.activity.Add(1) // la rutina receptora del destino
+		vn.inboxes[next.To] <- next.Msg
+	}
+}
+
+// Close detiene las rutinas de todos los procesos y espera a que terminen.
+// Los resultados se pueden seguir consultando.
+func (vn *VirtualNetwork) Close() {
+	vn.closeOnce.Do(func() {
+		close(vn.closed)
+		for _, lp := range vn.processes {
+			lp.stop()
+		}
+		vn.running.Wait()
+	})
+}
+
+// Comprueba que todos los procesos han simulado hasta el ciclo indicado
+func (vn *VirtualNetwork) checkFinished(cycles int) error {
+	for pid, lp := range vn.processes {
+		if clock := lp.simEngine.Clock(); clock < centralsim.TypeClock(cycles) {
+			if err := lp.simEngine.Err(); err != nil {
+				return fmt.Errorf("process %v stopped at clock %v: %w", pid, clock, err)
+			}
+			return fmt.Errorf("process %v stopped at clock %v before cycle %v", pid, clock, cycles)
+		}
+	}
+	return nil
+}
+
+// Results devuelve los disparos de todos los procesos
+func (vn *VirtualNetwork) Results() []centralsim.ResultadoTransition {
+	results := []centralsim.ResultadoTransition{}
+	for _, lp := range vn.processes {
+		results = append(results, lp.simEngine.Checkpoint().Results...)
+	}
+	return results
+}
+
+// Profiles devuelve el perfil de cada proceso
+func (vn *VirtualNetwork) Profiles() []centralsim.Profile {
+	profiles := make([]centralsim.Profile, len(vn.processes))
+	for pid, lp := range vn.processes {
+		profiles[pid] = lp.Profile()
+	}
+	return profiles
+}
+
+// Violations devuelve las violaciones de la causalidad local de todos los procesos
+func (vn *VirtualNetwork) Violations() []centralsim.Violation {
+	violations := []centralsim.Violation{}
+	for _, lp := range vn.processes {
+		violations = append(violations, lp.Violations()...)
+	}
+	return violations
+}
+
+// Marca como terminados los procesos que han avisado del fin
+func (vn *VirtualNetwork) updateStopped() {
+	for pid, kill := range vn.killChans {
+		select {
+		case <-kill:
+			vn.stopped[pid] = true
+		default:
+		}
+	}
+}
+
+func (vn *VirtualNetwork) allStopped() bool {
+	return len(vn.runningProcesses()) == 0
+}
+
+// Procesos que aún no han terminado
+func (vn *VirtualNetwork) runningProcesses() []int {
+	running := []int{}
+	for pid, stopped := range vn.stopped {
+		if !stopped {
+			running = append(running, pid)
+		}
+	}
+	return running
+}
+
+// Primer mensaje pendiente de cada enlace hacia procesos que no han terminado,
+// ordenados por origen y destino. Los mensajes dirigidos a un proceso
+// terminado se pierden como en la red real; los que envió antes de terminar,
+// como el aviso de fin, sí se entregan.
+func (vn *VirtualNetwork) candidates() []PendingMessage {
+	vn.mux.Lock()
+	defer vn.mux.Unlock()
+
+	alive := vn.pending[:0]
+	for _, m := range vn.pending {
+		if !vn.stopped[m.To] {
+			alive = append(alive, m)
+		}
+	}
+	vn.pending = alive
+
+	heads := map[[2]int]PendingMessage{}
+	for _, m := range vn.pending {
+		link := [2]int{m.From, m.To}
+		if head, ok := heads[link]; !ok || m.Index < head.Index {
+			heads[link] = m
+		}
+	}
+	candidates := make([]PendingMessage, 0, len(heads))
+	for _, m := range heads {
+		candidates = append(candidates, m)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].From != candidates[j].From {
+			return candidates[i].From < candidates[j].From
+		}
+		return candidates[i].To < candidates[j].To
+	})
+	return candidates
+}
+
+// Quita un mensaje de los pendientes
+func (vn *VirtualNetwork) remove(msg PendingMessage) {
+	vn.mux.Lock()
+	defer vn.mux.Unlock()
+	for i, m := range vn.pending {
+		if m.From == msg.From && m.To == msg.To && m.Index == msg.Index {
+			vn.pending = append(vn.pending[:i], vn.pending[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package process
+
+import (
+	"centralsim"
+	"centralsim/colored"
+	"centralsim/petrinet"
+	"petrisim/helpers"
+	"petrisim/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const virtualCycles = 100
+
+// Lee las subredes y el mapa de transiciones de la red de pruebas indicada
+func loadTestNet(t *testing.T, prefix string) ([]centralsim.Lefs, []models.TransitionMap) {
+	transitions := helpers.ReadNetTransitions("..", "tests/"+prefix+".transitions.json")
+	subnets, err := LoadSubnets("../tests/"+prefix, len(transitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subnets, transitions
+}
+
+// Crea la red virtual de la red de pruebas indicada
+func newVirtualNetwork(t *testing.T, prefix string) (*VirtualNetwork, []centralsim.Lefs) {
+	subnets, transitions := loadTestNet(t, prefix)
+	vn := NewVirtualNetwork(subnets, transitions)
+	t.Cleanup(vn.Close)
+	vn.MaxSteps = 20000
+	return vn, subnets
+}
+
+// Simula las subredes en la red virtual entregando los mensajes por turnos
+// (semilla 0) y al azar con las semillas 1..seeds, y comprueba que no viola
+// la causalidad y dispara lo mismo que la simulación secuencial en los
+// primeros ciclos, porque cada proceso termina en un tiempo distinto.
+// Devuelve la red de la última semilla, ya cerrada.
+func assertMatchesSequential(t *testing.T, subnets []centralsim.Lefs, maps []models.TransitionMap, seeds int) *VirtualNetwork {
+	t.Helper()
+	want := SortedFirings(SimulateSequential(subnets, virtualCycles), virtualCycles-20)
+	var vn *VirtualNetwork
+	for seed := int64(0); seed <= int64(seeds); seed++ {
+		order := RandomOrder(seed)
+		if seed == 0 {
+			order = RoundRobinOrder()
+		}
+		vn = NewVirtualNetwork(subnets, maps)
+		vn.MaxSteps = 20000
+		err := vn.Run(virtualCycles, order)
+		vn.Close()
+		if err != nil {
+			t.Fatalf("seed %v: %v", seed, err)
+		}
+		if violations := vn.Violations(); len(violations) > 0 {
+			t.Fatalf("seed %v: causality violations %v", seed, violations)
+		}
+		if got := SortedFirings(vn.Results(), virtualCycles-20); !reflect.DeepEqual(got, want) {
+			t.Fatalf("seed %v fired %v, expected %v", seed, got, want)
+		}
+	}
+	return vn
+}
+
+// Una misma semilla reproduce la misma secuencia de mensajes
+func TestVirtualNetworkIsDeterministic(t *testing.T) {
+	traces := [2][]PendingMessage{}
+	for i := range traces {
+		vn, _ := newVirtualNetwork(t, "special3")
+		if err := vn.Run(virtualCycles, RandomOrder(42)); err != nil {
+			t.Fatal(err)
+		}
+		traces[i] = vn.Trace
+	}
+	if len(traces[0]) == 0 {
+		t.Fatal("no message was delivered")
+	}
+	if !reflect.DeepEqual(traces[0], traces[1]) {
+		t.Errorf("traces differ: %v and %v deliveries", len(traces[0]), len(traces[1]))
+	}
+}
+
+// En cualquier orden de entrega de los mensajes la simulación distribuida
+// dispara las mismas transiciones que la secuencial
+func TestVirtualNetworkMatchesSequential(t *testing.T) {
+	for _, prefix := range []string{"2sub", "3sub", "special3"} {
+		t.Run(prefix, func(t *testing.T) {
+			subnets, transitions := loadTestNet(t, prefix)
+			assertMatchesSequential(t, subnets, transitions, 2)
+		})
+	}
+}
+
+// PL1 pasa cada pedido de inmediato a PL0, donde recibe, inmediata, debe
+// tomar la mesa antes que la transición temporizada toma del mismo instante
+const immediateAcrossProcesses = `{
+  "places": [{"name": "fuente", "initial": 1}, {"name": "cola"}, {"name": "buzon"}, {"name": "mesa", "initial": 1},
+    {"name": "ocupada"}, {"name": "hecho"}],
+  "transitions": [
+    {"name": "llega", "duration": 2, "inputs": [{"place": "fuente"}], "outputs": [{"place": "cola"}]},
+    {"name": "envia", "duration": 0, "inputs": [{"place": "cola"}], "outputs": [{"place": "buzon"}]},
+    {"name": "recibe", "duration": 0, "inputs": [{"place": "buzon"}, {"place": "mesa"}], "outputs": [{"place": "mesa"}, {"place": "hecho"}]},
+    {"name": "toma", "duration": 1, "inputs": [{"place": "mesa"}], "outputs": [{"place": "ocupada"}]},
+    {"name": "deja", "duration": 1, "inputs": [{"place": "ocupada"}], "outputs": [{"place": "mesa"}]},
+    {"name": "devuelve", "duration": 1, "inputs": [{"place": "hecho"}], "outputs": [{"place": "fuente"}]}
+  ]
+}`
+
+// PL1 produce cada pieza entre 1 y 3 después de recuperar la fuente y PL0 la
+// procesa o la descarta, en carrera, antes de devolverla
+const intervalsAcrossProcesses = `{
+  "places": [{"name": "fuente", "initial": 1}, {"name": "buzon"}, {"name": "hecho"}],
+  "transitions": [
+    {"name": "produce", "duration": 1, "interval": [1, 3], "inputs": [{"place": "fuente"}], "outputs": [{"place": "buzon"}]},
+    {"name": "procesa", "duration": 2, "interval": [1, 4], "inputs": [{"place": "buzon"}], "outputs": [{"place": "hecho"}]},
+    {"name": "descarta", "duration": 0, "interval": [2, 3], "inputs": [{"place": "buzon"}], "outputs": [{"place": "hecho"}]},
+    {"name": "devuelve", "duration": 1, "interval": [0, 2], "inputs": [{"place": "hecho"}], "outputs": [{"place": "fuente"}]}
+  ]
+}`
+
+// PL1 entrega una pieza en cada ciclo a un grupo de dos máquinas de PL0, que
+// tardan 3 en procesarla, y recoge las ya procesadas
+const serversAcrossProcesses = `{
+  "places": [{"name": "fuente", "initial": 1}, {"name": "cola"}, {"name": "hecha"}, {"name": "vuelta"}],
+  "transitions": [
+    {"name": "llega", "duration": 1, "inputs": [{"place": "fuente"}], "outputs": [{"place": "fuente"}, {"place": "cola"}]},
+    {"name": "procesa", "duration": 3, "servers": 2, "inputs": [{"place": "cola"}], "outputs": [{"place": "hecha"}]},
+    {"name": "retira", "duration": 1, "servers": -1, "inputs": [{"place": "hecha"}], "outputs": [{"place": "vuelta"}]},
+    {"name": "recoge", "duration": 1, "inputs": [{"place": "vuelta"}]}
+  ]
+}`
+
+// PL0 trabaja cada pieza 4 con memoria de edad, pero el ladrón que envía PL1
+// cada 5 se la lleva durante 2; PL1 recoge las piezas hechas y las devuelve
+const preemptionAcrossProcesses = `{
+  "places": [{"name": "pieza", "initial": 1}, {"name": "ladron"}, {"name": "salida"}, {"name": "calle", "initial": 1}],
+  "transitions": [
+    {"name": "trabaja", "duration": 4, "memory": "age", "inputs": [{"place": "pieza"}], "outputs": [{"place": "salida"}]},
+    {"name": "roba", "duration": 2, "inputs": [{"place": "ladron"}, {"place": "pieza"}], "outputs": [{"place": "pieza"}]},
+    {"name": "recoge", "duration": 1, "inputs": [{"place": "salida"}], "outputs": [{"place": "pieza"}]},
+    {"name": "ronda", "duration": 5, "inputs": [{"place": "calle"}], "outputs": [{"place": "calle"}, {"place": "ladron"}]}
+  ]
+}`
+
+// PL0 pinta hasta tres veces los pedidos del producto A y PL1 se los devuelve,
+// de modo que las fichas viajan en los eventos entre ambos procesos
+const coloredAcrossProcesses = `{
+  "colors": [{"name": "Producto", "enum": ["A", "B"]}, {"name": "Pedido", "tuple": ["int", "Producto", "int"]}],
+  "places": [
+    {"name": "pedidos", "color": "Pedido", "tokens": [[1, "A", 0], [2, "B", 0], [3, "A", 0]]},
+    {"name": "maquina", "initial": 1},
+    {"name": "pintados", "color": "Pedido"},
+    {"name": "turno", "initial": 1}
+  ],
+  "transitions": [
+    {"name": "pinta", "duration": 2, "guard": "n < 3 && p == \"A\"",
+      "inputs": [{"place": "pedidos", "expr": "(id, p, n)"}, {"place": "maquina"}],
+      "outputs": [{"place": "pintados", "expr": "(id, p, n + 1)"}, {"place": "maquina"}]},
+    {"name": "devuelve", "duration": 1, "inputs": [{"place": "pintados", "expr": "(id, p, n)"}],
+      "outputs": [{"place": "pedidos", "expr": "(id, p, n)"}]},
+    {"name": "cambia", "duration": 20, "inputs": [{"place": "turno"}], "outputs": [{"place": "turno"}]}
+  ]
+}`
+
+// Aplica a una red la política de los intervalos de disparo
+func withPolicy(policy string) func(*petrinet.Net) {
+	return func(net *petrinet.Net) { net.Policy, net.Seed = policy, 7 }
+}
+
+// Aplica a trabaja la memoria indicada
+func withMemory(memory string) func(*petrinet.Net) {
+	return func(net *petrinet.Net) { net.Transitions[0].Memory = memory }
+}
+
+// Red de prueba repartida entre procesos y simulada en la red virtual
+type fixtureRun struct {
+	model   *petrinet.Model
+	colored *colored.Model // nil si la red no es coloreada
+	subnets []centralsim.Lefs
+	want    []centralsim.ResultadoTransition // disparos de la simulación secuencial
+	vn      *VirtualNetwork                  // red virtual de la última semilla
+}
+
+// Compila la red de prueba, coloreada o no, y la reparte según owner
+func partitionFixture(t *testing.T, text string, isColored bool, edit func(*petrinet.Net), owner []int) fixtureRun {
+	t.Helper()
+	r := fixtureRun{}
+	if isColored {
+		net, err := colored.Decode(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.colored, err = colored.Compile(net); err != nil {
+			t.Fatal(err)
+		}
+		r.model = r.colored.Model
+	} else {
+		net, err := petrinet.Decode(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edit != nil {
+			edit(net)
+		}
+		if r.model, err = petrinet.Compile(net); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var err error
+	if r.subnets, err = r.model.Partition(owner); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// Cada red de prueba se simula repartida igual que en un único motor, y
+// además cumple lo que comprueba check
+func TestNetsAcrossProcesses(t *testing.T) {
+	for _, f := range []struct {
+		name    string
+		net     string
+		colored bool
+		edit    func(*petrinet.Net) // variante de la red, puede ser nil
+		owner   []int               // proceso de cada transición
+		check   func(t *testing.T, r fixtureRun)
+	}{
+		{name: "immediate", net: immediateAcrossProcesses, owner: []int{1, 1, 0, 0, 0, 0},
+			check: func(t *testing.T, r fixtureRun) {
+				if !reflect.DeepEqual(r.subnets[0].IaPrecedentesInmediatos, []int{1}) || r.subnets[1].IaPrecedentesInmediatos != nil {
+					t.Errorf("immediate predecessors %v and %v, expected PL1 before PL0",
+						r.subnets[0].IaPrecedentesInmediatos, r.subnets[1].IaPrecedentesInmediatos)
+				}
+				// en 2 llega el primer pedido y recibe toma la mesa antes que toma
+				if !reflect.DeepEqual(r.want[3:6], []centralsim.ResultadoTransition{{CodTransition: 1, ValorRelojDisparo: 2},
+					{CodTransition: 2, ValorRelojDisparo: 2}, {CodTransition: 3, ValorRelojDisparo: 2}}) {
+					t.Errorf("sequential firings %v", r.want[:6])
+				}
+			}},
+		{name: "intervals-" + centralsim.IntervalEarliest, net: intervalsAcrossProcesses,
+			edit: withPolicy(centralsim.IntervalEarliest), owner: []int{1, 0, 0, 0}, check: checkIntervals},
+		{name: "intervals-" + centralsim.IntervalLatest, net: intervalsAcrossProcesses,
+			edit: withPolicy(centralsim.IntervalLatest), owner: []int{1, 0, 0, 0}, check: checkIntervals},
+		{name: "intervals-" + centralsim.IntervalUniform, net: intervalsAcrossProcesses,
+			edit: withPolicy(centralsim.IntervalUniform), owner: []int{1, 0, 0, 0}, check: checkIntervals},
+		{name: "servers", net: serversAcrossProcesses, owner: []int{1, 0, 0, 1},
+			check: func(t *testing.T, r fixtureRun) {
+				// las dos máquinas procesan a la vez las piezas que llegan en 1 y 2
+				if !reflect.DeepEqual(r.want[:5], []centralsim.ResultadoTransition{{CodTransition: 0, ValorRelojDisparo: 0},
+					{CodTransition: 0, ValorRelojDisparo: 1}, {CodTransition: 1, ValorRelojDisparo: 1},
+					{CodTransition: 0, ValorRelojDisparo: 2}, {CodTransition: 1, ValorRelojDisparo: 2}}) {
+					t.Errorf("sequential firings %v", r.want[:5])
+				}
+			}},
+		{name: "preemption-" + centralsim.MemoriaSensibilizacion, net: preemptionAcrossProcesses,
+			edit: withMemory(centralsim.MemoriaSensibilizacion), owner: []int{0, 0, 1, 1}, check: checkPreemption},
+		{name: "preemption-" + centralsim.MemoriaEdad, net: preemptionAcrossProcesses,
+			edit: withMemory(centralsim.MemoriaEdad), owner: []int{0, 0, 1, 1}, check: checkPreemption},
+		{name: "colored", net: coloredAcrossProcesses, colored: true, owner: []int{0, 1, 1},
+			check: func(t *testing.T, r fixtureRun) {
+				if r.model.Firings(r.want)["pinta"] != 6 || r.model.Firings(r.want)["devuelve"] != 6 {
+					t.Errorf("sequential firings %v", r.model.Firings(r.want))
+				}
+				tokens := r.colored.Tokens(r.vn.processes[0].simEngine.Checkpoint())
+				if want := []centralsim.Ficha{`[1,"A",3]`, `[2,"B",0]`, `[3,"A",3]`}; !reflect.DeepEqual(tokens["pedidos"], want) {
+					t.Errorf("orders %v, expected %v", tokens["pedidos"], want)
+				}
+			}},
+	} {
+		t.Run(f.name, func(t *testing.T) {
+			r := partitionFixture(t, f.net, f.colored, f.edit, f.owner)
+			r.want = SortedFirings(SimulateSequential(r.subnets, virtualCycles), virtualCycles-20)
+			r.vn = assertMatchesSequential(t, r.subnets, models.MakeTransitionMaps(r.subnets), 2)
+			f.check(t, r)
+		})
+	}
+}
+
+func checkIntervals(t *testing.T, r fixtureRun) {
+	// el LookAhead de produce cuenta desde el inicio de su intervalo
+	if got := r.subnets[1].IaRed[0].TiempoHastaMarca.LiTiempos; !reflect.DeepEqual(got, []int{2, 2}) {
+		t.Errorf("time to mark %v, expected [2 2]", got)
+	}
+	if len(r.want) == 0 {
+		t.Error("no sequential firings")
+	}
+}
+
+func checkPreemption(t *testing.T, r fixtureRun) {
+	if firings := r.model.Firings(r.want); firings["roba"] == 0 || firings["trabaja"] == 0 {
+		t.Errorf("sequential firings %v", firings)
+	}
+}
+
+// Tres estaciones de un mismo módulo en anillo, cada una en su proceso
+const hierarchyAcrossProcesses = `{
+  "modules": [
+    {"name": "estacion", "params": {"t": 1}, "ports": ["entrada", "salida"], "places": [{"name": "hechas"}],
+      "transitions": [{"name": "procesa", "duration": "$t", "inputs": [{"place": "entrada"}],
+        "outputs": [{"place": "salida"}, {"place": "hechas"}]}]}
+  ],
+  "places": [{"name": "a", "initial": 2}, {"name": "b"}, {"name": "c"}],
+  "instances": [
+    {"name": "s0", "module": "estacion", "args": {"t": 2}, "bind": {"entrada": "a", "salida": "b"}},
+    {"name": "s1", "module": "estacion", "bind": {"entrada": "b", "salida": "c"}, "process": 1},
+    {"name": "s2", "module": "estacion", "args": {"t": 3}, "bind": {"entrada": "c", "salida": "a"}, "process": 2}
+  ]
+}`
+
+func TestHierarchyAcrossProcesses(t *testing.T) {
+	h, err := petrinet.DecodeHierarchy(strings.NewReader(hierarchyAcrossProcesses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, owner, err := h.Flatten()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := petrinet.Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partition, err := model.Partition(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// las subredes guardadas se leen como las de los escenarios de prueba
+	prefix := t.TempDir() + "/anillo"
+	if err := SaveSubnets(prefix, partition, models.MakeTransitionMaps(partition)); err != nil {
+		t.Fatal(err)
+	}
+	transitions := helpers.ReadNetTransitions("", prefix+".transitions.json")
+	if want := []models.TransitionMap{{Transitions: []int{0}, Ancestors: []int{2}, MinTime: 2},
+		{Transitions: []int{1}, Ancestors: []int{0}, MinTime: 1},
+		{Transitions: []int{2}, Ancestors: []int{1}, MinTime: 3}}; !reflect.DeepEqual(transitions, want) {
+		t.Fatalf("transitions map %v, expected %v", transitions, want)
+	}
+	subnets, err := LoadSubnets(prefix, len(transitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firings := model.Firings(SimulateSequential(subnets, virtualCycles)); firings["s1.procesa"] == 0 {
+		t.Fatalf("sequential firings %v", firings)
+	}
+	assertMatchesSequential(t, subnets, transitions, 2)
+}
+
+// Un proceso que se detiene por un error antes del ciclo final hace fallar la
+// ejecución aunque los demás terminen
+func TestRunReportsEarlyStop(t *testing.T) {
+	net, err := petrinet.Decode(strings.NewReader(`{
+  "places": [{"name": "p", "initial": 1}, {"name": "q"}, {"name": "r", "initial": 1}],
+  "transitions": [
+    {"name": "ida", "duration": 0, "inputs": [{"place": "p"}], "outputs": [{"place": "q"}]},
+    {"name": "vuelta", "duration": 0, "inputs": [{"place": "q"}], "outputs": [{"place": "p"}]},
+    {"name": "espera", "duration": 3, "inputs": [{"place": "r"}], "outputs": [{"place": "r"}]}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := petrinet.Compile(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subnets, err := model.Partition([]int{0, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vn := NewVirtualNetwork(subnets, models.MakeTransitionMaps(subnets))
+	defer vn.Close()
+	err = vn.Run(virtualCycles, RandomOrder(1))
+	if err == nil || !strings.Contains(err.Error(), "process 0 stopped at clock 0: vanishing loop") {
+		t.Fatalf("error %v, expected process 0 to stop in a vanishing loop", err)
+	}
+	if clock := vn.processes[1].simEngine.Clock(); clock < virtualCycles {
+		t.Errorf("process 1 stopped at %v, expected it to finish", clock)
+	}
+}
+
+// El mapa calculado de las subredes coincide con el de los escenarios de prueba
+func TestMakeTransitionMaps(t *testing.T) {
+	for _, prefix := range []string{"2sub", "3sub", "4sub3node", "special3"} {
+		want := helpers.ReadNetTransitions("..", "tests/"+prefix+".transitions.json")
+		subnets, err := LoadSubnets("../tests/"+prefix, len(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := models.MakeTransitionMaps(subnets); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: transitions map %v, expected %v", prefix, got, want)
+		}
+	}
+}
+
+// Las redes generadas se simulan repartidas igual que en un único motor
+func TestGeneratedNetsAcrossProcesses(t *testing.T) {
+	for _, g := range []petrinet.Generator{
+		{Family: petrinet.FamilyPipeline, Size: 4, Processes: 3},
+		{Family: petrinet.FamilyRing, Size: 4, Tokens: 2, Processes: 2},
+		{Family: petrinet.FamilyForkJoin, Size: 2, Processes: 2},
+		{Family: petrinet.FamilyGrid, Size: 3, Tokens: 3, Processes: 3},
+		{Family: petrinet.FamilyRandom, Size: 5, Tokens: 2, Density: 0.3, Processes: 3},
+	} {
+		t.Run(g.Family, func(t *testing.T) {
+			g.MinDuration, g.MaxDuration, g.Seed = 1, 3, 5
+			net, owner, err := g.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			model, err := petrinet.Compile(net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			subnets, err := model.Partition(owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertMatchesSequential(t, subnets, models.MakeTransitionMaps(subnets), 1)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package vclog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Flow cuenta los mensajes de un tipo entregados de un proceso a otro
+type Flow struct {
+	From  string
+	To    string
+	Type  string // MsgType del mensaje
+	Count int
+}
+
+// Message es un mensaje entregado: su envío y su recepción
+type Message struct {
+	Send    Event
+	Receive Event
+}
+
+// Report resume una ejecución a partir de sus logs de GoVector
+type Report struct {
+	Events             map[string]int // eventos de cada proceso
+	Sends              int            // eventos de envío
+	Messages           []Message      // mensajes entregados, en el orden de recepción
+	UnmatchedReceives  int            // recepciones cuyo envío no aparece en los logs
+	Flows              []Flow         // mensajes entregados por enlace y tipo
+	LookAheadRequests  int            // solicitudes de LookAhead entregadas
+	LookAheadResponses int            // LookAheads entregados
+	LongestChain       []Event        // cadena causal más larga, del primer al último evento
+}
+
+// Analyze empareja envíos y recepciones y busca la cadena causal más larga.
+// Los eventos deben estar ordenados por Merge.
+func Analyze(events []Event) Report {
+	report := Report{Events: map[string]int{}}
+	for _, e := range events {
+		report.Events[e.Process]++
+		if kind, _ := messageKind(e); kind == "Send" {
+			report.Sends++
+		}
+	}
+
+	// cadena más larga que termina en cada evento y evento anterior en ella
+	length := make([]int, len(events))
+	previous := make([]int, len(events))
+	last := map[string]int{}
+	sends := map[string][]int{} // envíos aún sin recepción de cada proceso
+	flows := map[Flow]int{}
+	for i, e := range events {
+		length[i], previous[i] = 1, -1
+		if j, ok := last[e.Process]; ok {
+			length[i], previous[i] = length[j]+1, j
+		}
+		last[e.Process] = i
+
+		kind, description := messageKind(e)
+		switch kind {
+		case "Send":
+			sends[e.Process] = append(sends[e.Process], i)
+		case "Receive":
+			j := matchSend(events, sends, e)
+			if j < 0 {
+				report.UnmatchedReceives++
+				continue
+			}
+			report.Messages = append(report.Messages, Message{events[j], e})
+			msgType := strings.Fields(description)[0]
+			flows[Flow{From: events[j].Process, To: e.Process, Type: msgType}]++
+			if length[j]+1 > length[i] {
+				length[i], previous[i] = length[j]+1, j
+			}
+		}
+	}
+
+	for flow, count := range flows {
+		flow.Count = count
+		report.Flows = append(report.Flows, flow)
+		switch flow.Type {
+		case "LookAheadReq":
+			report.LookAheadRequests += count
+		case "LookAhead":
+			report.LookAheadResponses += count
+		}
+	}
+	sort.Slice(report.Flows, func(i, j int) bool {
+		a, b := report.Flows[i], report.Flows[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Type < b.Type
+	})
+
+	end := -1
+	for i := range events {
+		if end == -1 || length[i] > length[end] {
+			end = i
+		}
+	}
+	for i := end; i >= 0; i = previous[i] {
+		report.LongestChain = append([]Event{events[i]}, report.LongestChain...)
+	}
+	return report
+}
+
+/*
+Busca y retira de sends el envío del mensaje recibido en el evento e. El
+emisor es el proceso PL<n> de la descripción y el envío tiene la misma
+descripción. Si la recepción avanza la componente del emisor, el envío es
+el que tiene ese tick; si no, el mensaje adelantó a otros posteriores del
+emisor (cada mensaje usa su propia conexión) y se toma el envío pendiente
+más antiguo que el reloj de la recepción ya conocía.
+*/
+func matchSend(events []Event, sends map[string][]int, e Event) int {
+	_, description := messageKind(e)
+	fields := strings.Fields(description)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "PL") {
+		return -1
+	}
+	sender := strings.TrimPrefix(fields[1], "PL")
+	tick := e.Clock[sender]
+
+	found := -1
+	for k, j := range sends[sender] {
+		send := events[j]
+		if _, d := messageKind(send); d != description || send.Tick() > tick {
+			continue
+		}
+		if found == -1 || send.Tick() == tick {
+			found = k
+		}
+	}
+	if found == -1 {
+		return -1
+	}
+	j := sends[sender][found]
+	sends[sender] = append(sends[sender][:found], sends[sender][found+1:]...)
+	return j
+}
+
+// Obtiene si el evento es un envío o una recepción y la descripción del
+// mensaje, p.ej. "LookAhead PL0 time=5" en "INFO Send LookAhead PL0 time=5"
+func messageKind(e Event) (kind string, description string) {
+	fields := strings.Fields(e.Text)
+	for i, f := range fields {
+		if f == "Send" || f == "Receive" {
+			return f, strings.Join(fields[i+1:], " ")
+		}
+	}
+	return "", ""
+}
+
+// Procesos de la cadena causal más larga que se muestran
+const maxPrintedHops = 20
+
+// Print escribe el resumen del informe
+func (r Report) Print(w io.Writer) {
+	processes := make([]string, 0, len(r.Events))
+	total := 0
+	for p, n := range r.Events {
+		processes = append(processes, p)
+		total += n
+	}
+	sort.Strings(processes)
+	counts := make([]string, len(processes))
+	for i, p := range processes {
+		counts[i] = fmt.Sprintf("PL%v %v", p, r.Events[p])
+	}
+	fmt.Fprintf(w, "Eventos: %v (%v)\n", total, strings.Join(counts, ", "))
+	fmt.Fprintf(w, "Mensajes: %v enviados, %v entregados, %v recepciones sin envío\n",
+		r.Sends, len(r.Messages), r.UnmatchedReceives)
+
+	fmt.Fprintln(w, "Flujos entre procesos:")
+	for _, f := range r.Flows {
+		fmt.Fprintf(w, "  PL%v -> PL%v %-12v %v\n", f.From, f.To, f.Type, f.Count)
+	}
+	fmt.Fprintf(w, "LookAhead: %v solicitudes, %v respuestas\n", r.LookAheadRequests, r.LookAheadResponses)
+
+	hops := []string{}
+	for i, e := range r.LongestChain {
+		if i == 0 || e.Process != r.LongestChain[i-1].Process {
+			hops = append(hops, "PL"+e.Process)
+		}
+	}
+	if len(hops) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Cadena causal más larga: %v eventos, %v mensajes entre procesos\n",
+		len(r.LongestChain), len(hops)-1)
+	if len(hops) > maxPrintedHops {
+		hops = append(append(hops[:maxPrintedHops/2:maxPrintedHops/2], "..."), hops[len(hops)-maxPrintedHops/2:]...)
+	}
+	fmt.Fprintf(w, "  %v\n", strings.Join(hops, " -> "))
+}
@@ -0,0 +1,183 @@
+// This module reads the GoVector logs of the logic processes, merges them
+// into a single ShiViz log and summarizes the messages and causal chains
+package vclog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Expresiones de ShiViz para los logs con y sin marca de tiempo
+const (
+	shivizRegex          = `(?<host>\S*) (?<clock>{.*})\n(?<event>.*)`
+	shivizTimestampRegex = `(?<timestamp>\d+) (?<host>\S*) (?<clock>{.*})\n(?<event>.*)`
+)
+
+// Event es un evento de un log de GoVector
+type Event struct {
+	Process   string            // identificador del proceso en GoVector, "0", "1"...
+	Timestamp int64             // nanosegundos Unix, 0 si el log no tiene marca de tiempo
+	Clock     map[string]uint64 // reloj vectorial tras el evento
+	Text      string            // descripción, p.ej. "INFO Send LookAhead PL0 time=5"
+}
+
+// Tick devuelve la componente propia del reloj, que numera los eventos del proceso
+func (e Event) Tick() uint64 {
+	return e.Clock[e.Process]
+}
+
+// Cabecera de un evento: [timestamp] pid {"pid":n, ...}
+var headerRegex = regexp.MustCompile(`^(?:(\d+) )?(\S+) (\{.*\})$`)
+var clockEntryRegex = regexp.MustCompile(`"([^"]*)":\s*(\d+)`)
+
+// ReadLog lee el log de GoVector de un proceso
+func ReadLog(r io.Reader) ([]Event, error) {
+	events := []Event{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		header := scanner.Text()
+		if header == "" {
+			continue
+		}
+		match := headerRegex.FindStringSubmatch(header)
+		if match == nil {
+			return nil, fmt.Errorf("line %v: invalid event header %q", line, header)
+		}
+		event := Event{Process: match[2], Clock: map[string]uint64{}}
+		if match[1] != "" {
+			event.Timestamp, _ = strconv.ParseInt(match[1], 10, 64)
+		}
+		for _, entry := range clockEntryRegex.FindAllStringSubmatch(match[3], -1) {
+			event.Clock[entry[1]], _ = strconv.ParseUint(entry[2], 10, 64)
+		}
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("line %v: event without description", line)
+		}
+		line++
+		event.Text = scanner.Text()
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ReadDir lee los logs de todos los procesos de un directorio (*-Log.txt)
+func ReadDir(dir string) ([]Event, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*-Log.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no GoVector logs in %v", dir)
+	}
+	sort.Strings(files)
+	events := []Event{}
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		read, err := ReadLog(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+		events = append(events, read...)
+	}
+	return events, nil
+}
+
+/*
+Merge ordena los eventos de todos los procesos de forma compatible con la
+relación ocurrió-antes: un evento solo se coloca cuando ya se han colocado
+los eventos de otros procesos que conoce su reloj. Entre los eventos
+disponibles se elige el de menor marca de tiempo. Si los logs están
+incompletos y ningún evento está disponible se toma el de menor marca.
+*/
+func Merge(events []Event) []Event {
+	queues := map[string][]Event{}
+	for _, e := range events {
+		queues[e.Process] = append(queues[e.Process], e)
+	}
+	processes := make([]string, 0, len(queues))
+	for p, q := range queues {
+		sort.SliceStable(q, func(i, j int) bool { return q[i].Tick() < q[j].Tick() })
+		processes = append(processes, p)
+	}
+	sort.Strings(processes)
+
+	placed := map[string]uint64{} // último tick colocado de cada proceso
+	ready := func(e Event) bool {
+		for p, tick := range e.Clock {
+			if p != e.Process && tick > placed[p] && len(queues[p]) > 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	merged := make([]Event, 0, len(events))
+	for len(merged) < len(events) {
+		next, fallback := "", ""
+		for _, p := range processes {
+			if len(queues[p]) == 0 {
+				continue
+			}
+			head := queues[p][0]
+			if fallback == "" || head.Timestamp < queues[fallback][0].Timestamp {
+				fallback = p
+			}
+			if ready(head) && (next == "" || head.Timestamp < queues[next][0].Timestamp) {
+				next = p
+			}
+		}
+		if next == "" {
+			next = fallback
+		}
+		e := queues[next][0]
+		queues[next] = queues[next][1:]
+		placed[next] = e.Tick()
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// WriteShiViz escribe los eventos en un único log que ShiViz puede visualizar
+func WriteShiViz(w io.Writer, events []Event) error {
+	regex := shivizRegex
+	if len(events) > 0 && events[0].Timestamp != 0 {
+		regex = shivizTimestampRegex
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "%v\n\n", regex)
+	for _, e := range events {
+		if e.Timestamp != 0 {
+			fmt.Fprintf(out, "%v ", e.Timestamp)
+		}
+		fmt.Fprintf(out, "%v %v\n%v\n", e.Process, formatClock(e.Clock), e.Text)
+	}
+	return out.Flush()
+}
+
+// Reloj en el formato de GoVector, con las entradas ordenadas
+func formatClock(clock map[string]uint64) string {
+	ids := make([]string, 0, len(clock))
+	for id := range clock {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]string, len(ids))
+	for i, id := range ids {
+		entries[i] = fmt.Sprintf("%q:%v", id, clock[id])
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
@@ -0,0 +1,97 @@
+package vclog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// PL1 registra la recepción del latido antes (según su reloj físico) de que
+// PL0 registre el envío, y la solicitud de LookAhead llega después del latido
+// aunque se envió antes
+const (
+	log0 = `100 0 {"0":1}
+Initialization Complete
+300 0 {"0":2}
+INFO Send LookAheadReq PL0 time=1
+310 0 {"0":3}
+INFO Send Heartbeat PL0
+500 0 {"0":4, "1":4}
+INFO Receive LookAhead PL1 time=3
+`
+	log1 = `100 1 {"1":1}
+Initialization Complete
+200 1 {"0":3, "1":2}
+INFO Receive Heartbeat PL0
+210 1 {"0":3, "1":3}
+INFO Receive LookAheadReq PL0 time=1
+220 1 {"0":3, "1":4}
+INFO Send LookAhead PL1 time=3
+`
+)
+
+func readTestLogs(t *testing.T) []Event {
+	events := []Event{}
+	for _, log := range []string{log1, log0} {
+		read, err := ReadLog(strings.NewReader(log))
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, read...)
+	}
+	return events
+}
+
+func TestMergeRespectsHappensBefore(t *testing.T) {
+	merged := Merge(readTestLogs(t))
+	order := ""
+	for _, e := range merged {
+		order += e.Process
+	}
+	if order != "01001110" {
+		t.Errorf("merged order %v, expected 01001110", order)
+	}
+
+	var out bytes.Buffer
+	if err := WriteShiViz(&out, merged); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != shivizTimestampRegex || lines[1] != "" || lines[6] != `300 0 {"0":2}` {
+		t.Errorf("unexpected ShiViz log:\n%v", out.String())
+	}
+	reread, err := ReadLog(strings.NewReader(strings.Join(lines[2:], "\n")))
+	if err != nil || len(reread) != len(merged) {
+		t.Errorf("ShiViz log not readable: %v events, %v", len(reread), err)
+	}
+}
+
+func TestAnalyzeMatchesMessages(t *testing.T) {
+	report := Analyze(Merge(readTestLogs(t)))
+	if report.Sends != 3 || len(report.Messages) != 3 || report.UnmatchedReceives != 0 {
+		t.Fatalf("%v sends, %v messages, %v unmatched", report.Sends, len(report.Messages), report.UnmatchedReceives)
+	}
+	request := report.Messages[1]
+	if request.Send.Tick() != 2 || request.Receive.Tick() != 3 {
+		t.Errorf("overtaken request matched %+v", request)
+	}
+	want := []Flow{
+		{From: "0", To: "1", Type: "Heartbeat", Count: 1},
+		{From: "0", To: "1", Type: "LookAheadReq", Count: 1},
+		{From: "1", To: "0", Type: "LookAhead", Count: 1},
+	}
+	if len(report.Flows) != len(want) {
+		t.Fatalf("flows %+v, expected %+v", report.Flows, want)
+	}
+	for i := range want {
+		if report.Flows[i] != want[i] {
+			t.Errorf("flow %+v, expected %+v", report.Flows[i], want[i])
+		}
+	}
+	if report.LookAheadRequests != 1 || report.LookAheadResponses != 1 {
+		t.Errorf("%v lookahead requests, %v responses", report.LookAheadRequests, report.LookAheadResponses)
+	}
+	if len(report.LongestChain) != 7 {
+		t.Errorf("longest chain has %v events, expected 7", len(report.LongestChain))
+	}
+}